@@ -0,0 +1,208 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/rishirishhh/vought/src/cmd/api/metrics"
+	"github.com/rishirishhh/vought/src/pkg/clients"
+)
+
+// S3 prefix of the transformed segments
+const TransformedPrefix string = "transformed"
+
+var errTransformCanceled = errors.New("transformation canceled")
+
+// SegmentCache keeps transformed HLS segments, so that a segment is transformed once for a given
+// list of transformers. Segments are looked up in memory first, then on S3 if an S3 client is
+// given. Concurrent requests for the same segment and transformers share one transformation.
+type SegmentCache struct {
+	local    *lru
+	s3Client clients.IS3Client
+	group    singleflight.Group
+
+	// Incremented on every invalidation of a video, so that transformations started before are
+	// not cached
+	mu          sync.Mutex
+	generations map[string]uint64
+	// Transformers whose segments depend on their viewer, they are transformed for every request
+	perViewer sync.Map
+}
+
+// Transform is called on cache miss to transform a segment, written to w as it is transformed. It
+// tells whether the segment depends on the viewer it is transformed for.
+type Transform func(ctx context.Context, w io.Writer) (perViewer bool, err error)
+
+// Result of a transformation shared by concurrent requests
+type transformed struct {
+	segment   []byte
+	perViewer bool
+}
+
+// Create a segment cache holding up to maxBytes of segments in memory. The S3 tier is disabled
+// with a nil s3Client.
+func NewSegmentCache(maxBytes int64, s3Client clients.IS3Client) *SegmentCache {
+	return &SegmentCache{
+		local:       newLRU(maxBytes),
+		s3Client:    s3Client,
+		generations: map[string]uint64{},
+	}
+}
+
+// Write to w the segment at segmentPath transformed by the ordered list of transformers. On cache
+// miss the segment is transformed by transform and written to w while it is transformed.
+// Concurrent requests for the same segment wait for the running transformation and get its result.
+// Segments depending on their viewer are neither cached nor shared, each request transforms its own.
+func (c *SegmentCache) Get(ctx context.Context, segmentPath string, transformers []string, w io.Writer, transform Transform) error {
+	key := cacheKey(segmentPath, transformers)
+
+	chain := strings.Join(transformers, "/")
+	if _, ok := c.perViewer.Load(chain); ok {
+		_, err := transform(ctx, w)
+		return err
+	}
+
+	if segment, ok := c.local.get(key); ok {
+		metrics.CounterTransformCache.WithLabelValues("local", "hit").Inc()
+		_, err := w.Write(segment)
+		return err
+	}
+	metrics.CounterTransformCache.WithLabelValues("local", "miss").Inc()
+
+	for {
+		// Only run for the request starting the transformation, which streams it to its client
+		streamed := false
+		segment, err, shared := c.group.Do(key, func() (interface{}, error) {
+			videoID := videoIDOf(segmentPath)
+			generation := c.generation(videoID)
+
+			if segment, ok := c.getS3(ctx, key); ok {
+				c.local.add(key, segment)
+				return transformed{segment: segment}, nil
+			}
+
+			streamed = true
+			var segment bytes.Buffer
+			perViewer, err := transform(ctx, io.MultiWriter(&segment, w))
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, fmt.Errorf("%w : %w", errTransformCanceled, err)
+				}
+				return nil, err
+			}
+
+			if perViewer {
+				log.Debug("Segments transformed by ", chain, " depend on their viewer, ", key, " is not cached")
+				c.perViewer.Store(chain, struct{}{})
+				return transformed{perViewer: true}, nil
+			}
+			if c.generation(videoID) != generation {
+				log.Debug("Video ", videoID, " invalidated during transformation, ", key, " is not cached")
+				return transformed{segment: segment.Bytes()}, nil
+			}
+			c.local.add(key, segment.Bytes())
+			// The segment is complete, it is worth keeping even if the client left meanwhile
+			c.putS3(context.WithoutCancel(ctx), key, segment.Bytes())
+			return transformed{segment: segment.Bytes()}, nil
+		})
+
+		// The client which started the transformation left, it is started again for the others
+		if errors.Is(err, errTransformCanceled) && shared && !streamed && ctx.Err() == nil {
+			log.Debug("Transformation of ", key, " canceled by another request, starting it again")
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if streamed {
+			return nil
+		}
+
+		result := segment.(transformed)
+		if result.perViewer {
+			// The segment was transformed for the viewer of another request
+			_, err = transform(ctx, w)
+			return err
+		}
+		_, err = w.Write(result.segment)
+		return err
+	}
+}
+
+// Drop every cached segment of a video, after it has been deleted or encoded again
+func (c *SegmentCache) Invalidate(ctx context.Context, videoID string) error {
+	c.mu.Lock()
+	c.generations[videoID]++
+	c.mu.Unlock()
+
+	c.local.removePrefix(videoID + "/")
+
+	if c.s3Client == nil {
+		return nil
+	}
+	return c.s3Client.RemoveObject(ctx, path.Join(TransformedPrefix, videoID)+"/")
+}
+
+func (c *SegmentCache) generation(videoID string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generations[videoID]
+}
+
+func (c *SegmentCache) getS3(ctx context.Context, key string) ([]byte, bool) {
+	if c.s3Client == nil {
+		return nil, false
+	}
+
+	object, err := c.s3Client.GetObject(ctx, path.Join(TransformedPrefix, key))
+	if err != nil {
+		metrics.CounterTransformCache.WithLabelValues("s3", "miss").Inc()
+		return nil, false
+	}
+	defer object.Close()
+
+	segment, err := io.ReadAll(object)
+	if err != nil {
+		log.Error("Cannot read cached segment ", key, " : ", err)
+		metrics.CounterTransformCache.WithLabelValues("s3", "miss").Inc()
+		return nil, false
+	}
+
+	metrics.CounterTransformCache.WithLabelValues("s3", "hit").Inc()
+	return segment, true
+}
+
+func (c *SegmentCache) putS3(ctx context.Context, key string, segment []byte) {
+	if c.s3Client == nil {
+		return
+	}
+
+	if err := c.s3Client.PutObjectInput(ctx, bytes.NewReader(segment), path.Join(TransformedPrefix, key)); err != nil {
+		log.Error("Cannot cache segment ", key, " on S3 : ", err)
+	}
+}
+
+// The key is the segment path followed by the transformers, in order since applying them in
+// another order gives another segment (e.g. <id>/v0/segment1.ts/gray,flip)
+func cacheKey(segmentPath string, transformers []string) string {
+	escaped := make([]string, len(transformers))
+	for i, transformer := range transformers {
+		escaped[i] = url.PathEscape(transformer)
+	}
+	return path.Join(segmentPath, strings.Join(escaped, ","))
+}
+
+func videoIDOf(segmentPath string) string {
+	videoID, _, _ := strings.Cut(segmentPath, "/")
+	return videoID
+}
@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LRUEviction(t *testing.T) {
+	c := newLRU(10)
+	c.add("a", []byte("aaaa"))
+	c.add("b", []byte("bbbb"))
+
+	// a becomes the most recently used, b is evicted to make room for c
+	_, ok := c.get("a")
+	require.True(t, ok)
+	c.add("c", []byte("cccc"))
+
+	_, ok = c.get("b")
+	require.False(t, ok)
+	_, ok = c.get("a")
+	require.True(t, ok)
+	require.Equal(t, int64(8), c.size)
+
+	// Too large to be cached
+	c.add("d", []byte("ddddddddddd"))
+	_, ok = c.get("d")
+	require.False(t, ok)
+}
+
+func Test_SegmentCacheGet(t *testing.T) {
+	cache := NewSegmentCache(1<<20, nil)
+
+	var calls atomic.Int32
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	transform := func(ctx context.Context, w io.Writer) (bool, error) {
+		calls.Add(1)
+		started <- struct{}{}
+		if _, err := w.Write([]byte("gray ")); err != nil {
+			return false, err
+		}
+		<-release
+		_, err := w.Write([]byte("segment"))
+		return false, err
+	}
+	get := func(transformers ...string) string {
+		var segment bytes.Buffer
+		require.NoError(t, cache.Get(context.Background(), "id/v0/segment0.ts", transformers, &segment, transform))
+		return segment.String()
+	}
+
+	// Concurrent identical requests share one transformation
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.Equal(t, "gray segment", get("gray"))
+		}()
+	}
+	// Let the other requests wait for the running transformation before releasing it
+	<-started
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	require.Equal(t, int32(1), calls.Load())
+
+	// Cached once transformed
+	require.Equal(t, "gray segment", get("gray"))
+	require.Equal(t, int32(1), calls.Load())
+
+	// Transformers order matters
+	get("gray", "flip")
+	get("flip", "gray")
+	require.Equal(t, int32(3), calls.Load())
+
+	// Invalidated with its video
+	require.NoError(t, cache.Invalidate(context.Background(), "id"))
+	get("gray")
+	require.Equal(t, int32(4), calls.Load())
+}
+
+func Test_SegmentCacheCanceledTransformation(t *testing.T) {
+	cache := NewSegmentCache(1<<20, nil)
+
+	started := make(chan struct{}, 10)
+	transform := func(ctx context.Context, w io.Writer) (bool, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return false, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderDone := make(chan error)
+	go func() {
+		leaderDone <- cache.Get(ctx, "id/v0/segment0.ts", []string{"gray"}, io.Discard, transform)
+	}()
+	<-started
+
+	// The waiting request starts the transformation again when the first client leaves
+	followerCtx, followerCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer followerCancel()
+	followerDone := make(chan error)
+	go func() {
+		followerDone <- cache.Get(followerCtx, "id/v0/segment0.ts", []string{"gray"}, io.Discard, func(ctx context.Context, w io.Writer) (bool, error) {
+			_, err := w.Write([]byte("gray segment"))
+			return false, err
+		})
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, <-leaderDone, context.Canceled)
+	require.NoError(t, <-followerDone)
+}
+
+func Test_SegmentCachePerViewer(t *testing.T) {
+	cache := NewSegmentCache(1<<20, nil)
+
+	var calls atomic.Int32
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	get := func(viewer string) string {
+		var segment bytes.Buffer
+		require.NoError(t, cache.Get(context.Background(), "id/v0/segment0.ts", []string{"forensic"}, &segment, func(ctx context.Context, w io.Writer) (bool, error) {
+			calls.Add(1)
+			started <- struct{}{}
+			<-release
+			_, err := w.Write([]byte("segment for " + viewer))
+			return true, err
+		}))
+		return segment.String()
+	}
+
+	// Requests waiting for the transformation of another viewer transform their own segment
+	done := make(chan string)
+	go func() { done <- get("alice") }()
+	<-started
+	go func() { done <- get("bob") }()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	require.ElementsMatch(t, []string{"segment for alice", "segment for bob"}, []string{<-done, <-done})
+	require.Equal(t, int32(2), calls.Load())
+
+	// Never cached
+	require.Equal(t, "segment for carol", get("carol"))
+	require.Equal(t, int32(3), calls.Load())
+}
@@ -1,6 +1,9 @@
 package config
 
 import (
+	"net/netip"
+	"time"
+
 	"github.com/caarlos0/env/v6"
 )
 
@@ -28,7 +31,33 @@ type Config struct {
 	MariadbHost    string `env:"MARIADB_HOST,required"`
 	MariadbPort    string `env:"MARIADB_PORT,required"`
 
-	ConsulHost string `env:"CONSUL_URL,required"`
+	// Service discovery backend : consul, file or dns
+	DiscoveryBackend         string        `env:"DISCOVERY_BACKEND" envDefault:"consul"`
+	ConsulHost               string        `env:"CONSUL_URL" envDefault:""`
+	DiscoveryFile            string        `env:"DISCOVERY_FILE" envDefault:""`
+	DiscoveryDNSDomain       string        `env:"DISCOVERY_DNS_DOMAIN" envDefault:""`
+	DiscoveryDNSServices     []string      `env:"DISCOVERY_DNS_SERVICES" envSeparator:","`
+	DiscoveryRefreshInterval time.Duration `env:"DISCOVERY_REFRESH_INTERVAL" envDefault:"5s"`
+
+	// Signed playback URLs are disabled without secret
+	PlaybackSecret   string        `env:"PLAYBACK_SECRET" envDefault:""`
+	PlaybackTokenTTL time.Duration `env:"PLAYBACK_TOKEN_TTL" envDefault:"4h"`
+	PlaybackBindIP   bool          `env:"PLAYBACK_BIND_IP" envDefault:"false"`
+	// CIDRs of the reverse proxies in front of the API (e.g. 10.0.0.0/8), the client IP a token is
+	// bound to is then read from their X-Forwarded-For header. Without them, PLAYBACK_BIND_IP binds
+	// tokens to the proxy address.
+	PlaybackTrustedProxies []netip.Prefix `env:"PLAYBACK_TRUSTED_PROXIES" envSeparator:","`
+
+	// Bytes of transformed segments kept in memory, and whether they are also kept on S3. Transformed
+	// segments are not cached with a size of 0 and without S3.
+	TransformCacheSize int64 `env:"TRANSFORM_CACHE_SIZE" envDefault:"268435456"`
+	TransformCacheS3   bool  `env:"TRANSFORM_CACHE_S3" envDefault:"false"`
+
+	// Serve untransformed segments, with a Warning header, when no transformer is available
+	TransformFallback bool `env:"TRANSFORM_FALLBACK" envDefault:"false"`
+
+	// Maximum duration of the transformation of a segment, propagated to every transformer
+	TransformTimeout time.Duration `env:"TRANSFORM_TIMEOUT" envDefault:"30s"`
 }
 
 func NewConfig() (Config, error) {
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/rishirishhh/vought/src/cmd/api/models"
+	"github.com/rishirishhh/vought/src/cmd/api/playback"
+	"github.com/rishirishhh/vought/src/pkg/clients"
+	transformerv2 "github.com/rishirishhh/vought/src/pkg/transformer/v2"
+)
+
+// filterChain is the transformation requested by the filters of a request
+type filterChain struct {
+	steps []*transformerv2.Step
+	// A step personalises the video for its viewer, as advertised by the service discovery. The
+	// transformers also tell it with the parts they send, in case it is not advertised.
+	perViewer bool
+	// IDs of the other videos read by the steps, as told by the advertised schemas. The transformers
+	// check them too, against the video the forwarded principal is bound to.
+	videos []string
+}
+
+// Parse the filters of a request, name[:key=value[,key=value...]], into transformation steps.
+// Parameters are validated with the schemas advertised by the transformers, so that invalid ones
+// are rejected before any transformer is called.
+func parseFilters(discovery clients.ServiceDiscovery, filters []string) (filterChain, error) {
+	chain := filterChain{}
+	if len(filters) == 0 {
+		return chain, nil
+	}
+
+	services := map[string]models.TransformerService{}
+	for _, service := range discovery.GetExistingServices() {
+		services[service.Name] = service
+	}
+
+	for _, filter := range filters {
+		name, parameters, err := transformerv2.ParseFilter(filter)
+		if err != nil {
+			return filterChain{}, err
+		}
+
+		service, ok := services[name]
+		if !ok {
+			return filterChain{}, fmt.Errorf("Unknown filter %v", name)
+		}
+		specs, err := transformerv2.ParametersFromSchema(service.Schema)
+		if err != nil {
+			return filterChain{}, fmt.Errorf("Cannot read parameter schema of filter %v : %w", name, err)
+		}
+
+		step, err := transformerv2.NewStep(name, parameters, specs)
+		if err != nil {
+			return filterChain{}, err
+		}
+		chain.steps = append(chain.steps, step)
+		chain.perViewer = chain.perViewer || service.PerViewer
+		chain.videos = append(chain.videos, transformerv2.ReferencedVideos(step, specs)...)
+	}
+	return chain, nil
+}
+
+// Tell whether the principal may read every video of the chain
+func (c filterChain) allowedFor(principal playback.Principal) bool {
+	for _, video := range c.videos {
+		if !principal.CanAccess(video) {
+			return false
+		}
+	}
+	return true
+}
+
+// Names of the steps, for the metrics
+func stepNames(steps []*transformerv2.Step) []string {
+	names := make([]string, 0, len(steps))
+	for _, step := range steps {
+		names = append(names, step.GetName())
+	}
+	return names
+}
+
+// Canonical filters of the steps, equal transformations have equal filters
+func stepFilters(steps []*transformerv2.Step) []string {
+	filters := make([]string, 0, len(steps))
+	for _, step := range steps {
+		filters = append(filters, transformerv2.StepFilter(step))
+	}
+	return filters
+}
@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/rishirishhh/vought/src/pkg/clients"
+	"github.com/rishirishhh/vought/src/pkg/hls"
+)
+
+var errTimestampOutOfRange = errors.New("timestamp out of range")
+
+// playlistRewrite describes how a playlist stored on S3 is rewritten for a request
+type playlistRewrite struct {
+	// Query parameters carried by every URI of the playlist, since players do not forward
+	// them to the variants and segments they request
+	query url.Values
+	// Variants higher than maxHeight are removed from master playlists, 0 keeps every variant
+	maxHeight uint64
+}
+
+func playlistRewriteFromRequest(r *http.Request) (playlistRewrite, error) {
+	query := r.URL.Query()
+	rewrite := playlistRewrite{query: url.Values{}}
+
+	if token := query.Get("token"); token != "" {
+		rewrite.query.Set("token", token)
+	}
+	if filters := query["filter"]; len(filters) > 0 {
+		rewrite.query["filter"] = filters
+	}
+
+	if query.Get("max_height") != "" {
+		maxHeight, err := strconv.ParseUint(query.Get("max_height"), 10, 32)
+		if err != nil {
+			return rewrite, fmt.Errorf("invalid max_height %v : %w", query.Get("max_height"), err)
+		}
+		rewrite.maxHeight = maxHeight
+	}
+
+	return rewrite, nil
+}
+
+func (p playlistRewrite) isNeeded() bool {
+	return len(p.query) > 0 || p.maxHeight > 0
+}
+
+// Serve a rewritten S3 playlist
+func serveRewrittenPlaylist(w http.ResponseWriter, r *http.Request, s3Client clients.IS3Client, key string, rewrite playlistRewrite) error {
+	object, err := s3Client.GetObject(r.Context(), key)
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+
+	playlist, err := hls.Decode(object)
+	if err != nil {
+		return err
+	}
+	rewritePlaylist(playlist, rewrite)
+
+	// The rewritten playlist is specific to the request, it must not be shared by caches
+	w.Header().Set("Content-Type", streamContentTypes[".m3u8"])
+	w.Header().Set("Cache-Control", "private, no-store")
+	if r.Method == http.MethodHead {
+		return nil
+	}
+
+	_, _ = w.Write(playlist.Encode())
+	return nil
+}
+
+// Append the rewrite query parameters to every URI of a playlist, including the URI attributes
+// of tags, and remove the variants above the maximum height. The smallest variants are always
+// kept, so that the playlist remains playable.
+func rewritePlaylist(playlist hls.Playlist, rewrite playlistRewrite) {
+	switch p := playlist.(type) {
+	case *hls.MasterPlaylist:
+		maxHeight := rewrite.maxHeight
+		if minHeight := minVariantHeight(p); maxHeight > 0 && minHeight > maxHeight {
+			maxHeight = minHeight
+		}
+
+		variants := p.Variants[:0]
+		for _, variant := range p.Variants {
+			if _, height, ok := variant.Resolution(); ok && maxHeight > 0 && height > maxHeight {
+				continue
+			}
+			variant.URI = appendQuery(variant.URI, rewrite.query)
+			variants = append(variants, variant)
+		}
+		p.Variants = variants
+
+		iFrameVariants := p.IFrameVariants[:0]
+		for _, variant := range p.IFrameVariants {
+			if _, height, ok := variant.Resolution(); ok && maxHeight > 0 && height > maxHeight {
+				continue
+			}
+			variant.SetURI(appendQuery(variant.URI(), rewrite.query))
+			iFrameVariants = append(iFrameVariants, variant)
+		}
+		p.IFrameVariants = iFrameVariants
+
+		for _, media := range p.Media {
+			if media.URI() != "" {
+				media.SetURI(appendQuery(media.URI(), rewrite.query))
+			}
+		}
+
+	case *hls.MediaPlaylist:
+		for _, segment := range p.Segments {
+			segment.URI = appendQuery(segment.URI, rewrite.query)
+			if segment.Key != nil && segment.Key.URI() != "" {
+				segment.Key.SetURI(appendQuery(segment.Key.URI(), rewrite.query))
+			}
+			if segment.Map != nil {
+				segment.Map.SetURI(appendQuery(segment.Map.URI(), rewrite.query))
+			}
+		}
+	}
+}
+
+func minVariantHeight(master *hls.MasterPlaylist) uint64 {
+	minHeight := uint64(0)
+	for _, variant := range master.Variants {
+		if _, height, ok := variant.Resolution(); ok && (minHeight == 0 || height < minHeight) {
+			minHeight = height
+		}
+	}
+	return minHeight
+}
+
+func appendQuery(uri string, query url.Values) string {
+	if len(query) == 0 {
+		return uri
+	}
+	if strings.Contains(uri, "?") {
+		return uri + "&" + query.Encode()
+	}
+	return uri + "?" + query.Encode()
+}
+
+// Pick the variant of a master playlist best suited for the given width: the smallest one at
+// least as wide, or the widest one. A width of 0 selects the widest variant.
+func selectVariant(master *hls.MasterPlaylist, width uint32) (*hls.Variant, error) {
+	var selected *hls.Variant
+	var selectedWidth uint64
+
+	for _, variant := range master.Variants {
+		variantWidth, _, _ := variant.Resolution()
+		if selected == nil || betterVariant(variantWidth, selectedWidth, uint64(width)) {
+			selected = variant
+			selectedWidth = variantWidth
+		}
+	}
+
+	if selected == nil {
+		return nil, errors.New("no variant found in master playlist")
+	}
+	return selected, nil
+}
+
+func betterVariant(candidate, selected, width uint64) bool {
+	if width == 0 {
+		return candidate > selected
+	}
+	if selected < width {
+		return candidate > selected
+	}
+	return candidate >= width && candidate < selected
+}
+
+// Find the segment of a media playlist containing the timestamp t (in seconds). Return it with
+// its start timestamp.
+func locateSegment(media *hls.MediaPlaylist, t float64) (*hls.Segment, float64, error) {
+	index, start, ok := media.SegmentAt(t)
+	if !ok {
+		return nil, 0, fmt.Errorf("%w : %v is beyond the end of the video (%v)", errTimestampOutOfRange, t, start)
+	}
+	return media.Segments[index], start, nil
+}
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rishirishhh/vought/src/pkg/clients"
+	log "github.com/sirupsen/logrus"
+)
+
+// HLS playlists are served with revalidation, since they can be rewritten when a video is re-encoded
+const (
+	playlistCacheControl string = "no-cache"
+	segmentCacheControl  string = "public, max-age=86400"
+)
+
+var streamContentTypes = map[string]string{
+	".m3u8": "application/vnd.apple.mpegurl",
+	".ts":   "video/mp2t",
+}
+
+// Serve an S3 object with support of HEAD, Range and conditional requests (If-None-Match,
+// If-Modified-Since, If-Range). The client Range is forwarded to S3, so that the object is
+// fetched with a single GET, and its Content-Range is passed through.
+func serveS3Object(w http.ResponseWriter, r *http.Request, s3Client clients.IS3Client, key string, contentType string, cacheControl string) error {
+	if r.Method == http.MethodHead {
+		object, err := s3Client.HeadObject(r.Context(), key)
+		if err != nil {
+			return err
+		}
+		defer object.Close()
+		writeS3ObjectHeaders(w, r, object, contentType, cacheControl)
+		return nil
+	}
+
+	// S3 serves a single range, multiple ranges are answered with the whole object
+	byteRange := r.Header.Get("Range")
+	if strings.Contains(byteRange, ",") {
+		byteRange = ""
+	}
+
+	object, err := s3Client.GetObjectRange(r.Context(), key, byteRange)
+	if errors.Is(err, clients.ErrInvalidRange) {
+		// The client is told the size of the object, to ask for a range within it
+		object, err := s3Client.HeadObject(r.Context(), key)
+		if err != nil {
+			return err
+		}
+		object.Close()
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", object.ContentLength))
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// The range is only wanted if the object is the one the client already has a part of
+	if byteRange != "" && !ifRangeMatches(r, object) {
+		object.Close()
+		if object, err = s3Client.GetObject(r.Context(), key); err != nil {
+			return err
+		}
+	}
+	defer object.Close()
+
+	if !writeS3ObjectHeaders(w, r, object, contentType, cacheControl) {
+		return nil
+	}
+	if _, err := io.Copy(w, object); err != nil {
+		log.Error("Cannot send "+key+" : ", err)
+	}
+	return nil
+}
+
+// Write the headers and the status of the response, return false if the client already has the
+// object and no body must be sent
+func writeS3ObjectHeaders(w http.ResponseWriter, r *http.Request, object *clients.Object, contentType string, cacheControl string) bool {
+	if object.ETag != "" {
+		w.Header().Set("ETag", object.ETag)
+	}
+	if !object.LastModified.IsZero() {
+		w.Header().Set("Last-Modified", object.LastModified.UTC().Format(http.TimeFormat))
+	}
+	if cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+
+	if isNotModified(r, object) {
+		w.WriteHeader(http.StatusNotModified)
+		return false
+	}
+
+	if contentType == "" {
+		contentType = object.ContentType
+	}
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Content-Length", strconv.FormatInt(object.ContentLength, 10))
+
+	if object.ContentRange != "" {
+		w.Header().Set("Content-Range", object.ContentRange)
+		w.WriteHeader(http.StatusPartialContent)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	return true
+}
+
+func isNotModified(r *http.Request, object *clients.Object) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if object.ETag == "" {
+			return false
+		}
+		for _, etag := range strings.Split(ifNoneMatch, ",") {
+			etag = strings.TrimSpace(etag)
+			if etag == "*" || strings.TrimPrefix(etag, "W/") == strings.TrimPrefix(object.ETag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	modifiedSince, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || object.LastModified.IsZero() {
+		return false
+	}
+	return !object.LastModified.Truncate(time.Second).After(modifiedSince)
+}
+
+// If-Range holds either a strong ETag or a date, which must be the ones of the object
+func ifRangeMatches(r *http.Request, object *clients.Object) bool {
+	ifRange := r.Header.Get("If-Range")
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) {
+		return object.ETag != "" && ifRange == object.ETag
+	}
+	modifiedSince, err := http.ParseTime(ifRange)
+	return err == nil && object.LastModified.Truncate(time.Second).Equal(modifiedSince)
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rishirishhh/vought/src/pkg/clients"
+)
+
+// fakeS3Client serves a single object, ranges are given as bytes=<first>-<last>
+type fakeS3Client struct {
+	clients.IS3Client
+	content string
+	calls   []string
+}
+
+func (f *fakeS3Client) object(body string) *clients.Object {
+	return &clients.Object{
+		ReadCloser:    io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		ContentType:   "video/mp2t",
+		ETag:          `"etag"`,
+		LastModified:  time.Unix(1700000000, 0),
+	}
+}
+
+func (f *fakeS3Client) GetObject(ctx context.Context, key string) (*clients.Object, error) {
+	return f.GetObjectRange(ctx, key, "")
+}
+
+func (f *fakeS3Client) GetObjectRange(ctx context.Context, key string, byteRange string) (*clients.Object, error) {
+	f.calls = append(f.calls, "GET "+byteRange)
+	if byteRange == "" {
+		return f.object(f.content), nil
+	}
+
+	var first, last int
+	if _, err := fmt.Sscanf(byteRange, "bytes=%d-%d", &first, &last); err != nil {
+		return nil, err
+	}
+	if first >= len(f.content) {
+		return nil, clients.ErrInvalidRange
+	}
+	last = min(last, len(f.content)-1)
+	object := f.object(f.content[first : last+1])
+	object.ContentRange = fmt.Sprintf("bytes %d-%d/%d", first, last, len(f.content))
+	return object, nil
+}
+
+func (f *fakeS3Client) HeadObject(ctx context.Context, key string) (*clients.Object, error) {
+	f.calls = append(f.calls, "HEAD")
+	object := f.object("")
+	object.ContentLength = int64(len(f.content))
+	return object, nil
+}
+
+func Test_ServeS3Object(t *testing.T) {
+	cases := []struct {
+		Name          string
+		GivenMethod   string
+		GivenHeaders  map[string]string
+		ExpectStatus  int
+		ExpectHeaders map[string]string
+		ExpectBody    string
+		ExpectCalls   []string
+	}{
+		{Name: "Whole object", GivenMethod: http.MethodGet, ExpectStatus: http.StatusOK, ExpectHeaders: map[string]string{"Content-Length": "10", "ETag": `"etag"`}, ExpectBody: "0123456789", ExpectCalls: []string{"GET "}},
+		{Name: "Range", GivenMethod: http.MethodGet, GivenHeaders: map[string]string{"Range": "bytes=2-4"}, ExpectStatus: http.StatusPartialContent, ExpectHeaders: map[string]string{"Content-Length": "3", "Content-Range": "bytes 2-4/10"}, ExpectBody: "234", ExpectCalls: []string{"GET bytes=2-4"}},
+		{Name: "Unsatisfiable range", GivenMethod: http.MethodGet, GivenHeaders: map[string]string{"Range": "bytes=20-30"}, ExpectStatus: http.StatusRequestedRangeNotSatisfiable, ExpectHeaders: map[string]string{"Content-Range": "bytes */10"}, ExpectCalls: []string{"GET bytes=20-30", "HEAD"}},
+		{Name: "Range of another version", GivenMethod: http.MethodGet, GivenHeaders: map[string]string{"Range": "bytes=2-4", "If-Range": `"old"`}, ExpectStatus: http.StatusOK, ExpectBody: "0123456789", ExpectCalls: []string{"GET bytes=2-4", "GET "}},
+		{Name: "Not modified", GivenMethod: http.MethodGet, GivenHeaders: map[string]string{"If-None-Match": `"etag"`}, ExpectStatus: http.StatusNotModified, ExpectCalls: []string{"GET "}},
+		{Name: "HEAD", GivenMethod: http.MethodHead, ExpectStatus: http.StatusOK, ExpectHeaders: map[string]string{"Content-Length": "10"}, ExpectCalls: []string{"HEAD"}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			s3Client := &fakeS3Client{content: "0123456789"}
+			r := httptest.NewRequest(tt.GivenMethod, "/segment.ts", nil)
+			for name, value := range tt.GivenHeaders {
+				r.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+
+			require.NoError(t, serveS3Object(w, r, s3Client, "id/v0/segment.ts", "", segmentCacheControl))
+			require.Equal(t, tt.ExpectStatus, w.Code)
+			for name, value := range tt.ExpectHeaders {
+				require.Equal(t, value, w.Header().Get(name), name)
+			}
+			require.Equal(t, tt.ExpectBody, w.Body.String())
+			require.Equal(t, tt.ExpectCalls, s3Client.calls)
+		})
+	}
+}
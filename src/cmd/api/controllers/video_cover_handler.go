@@ -57,6 +57,7 @@ func (v VideoCoverHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusNotFound)
 			return
 		}
+		defer object.Close()
 
 		rawObject, err := io.ReadAll(object)
 		if err != nil {
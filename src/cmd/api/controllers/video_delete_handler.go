@@ -7,6 +7,7 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/rishirishhh/vought/src/cmd/api/cache"
 	"github.com/rishirishhh/vought/src/cmd/api/db/dao"
 	"github.com/rishirishhh/vought/src/cmd/api/models"
 	"github.com/rishirishhh/vought/src/pkg/clients"
@@ -14,10 +15,11 @@ import (
 )
 
 type VideoDeleteHandler struct {
-	S3Client   clients.IS3Client
-	VideosDAO  *dao.VideosDAO
-	UploadsDAO *dao.UploadsDAO
-	UUIDGen    clients.IUUIDGenerator
+	S3Client     clients.IS3Client
+	VideosDAO    *dao.VideosDAO
+	UploadsDAO   *dao.UploadsDAO
+	UUIDGen      clients.IUUIDGenerator
+	SegmentCache *cache.SegmentCache
 }
 
 // VideoDeleteHandler godoc
@@ -71,6 +73,12 @@ func (v VideoDeleteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+
+	if v.SegmentCache != nil {
+		if err = v.SegmentCache.Invalidate(r.Context(), id); err != nil {
+			log.Error("Cannot remove transformed segments of video "+id+" : ", err)
+		}
+	}
 }
 
 func (v VideoDeleteHandler) deleteVideoAndUpdate(ctx context.Context, id string) (int, error) {
@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/rishirishhh/vought/src/pkg/clients"
+	"github.com/rishirishhh/vought/src/pkg/ffmpeg"
+	"github.com/rishirishhh/vought/src/pkg/hls"
+	log "github.com/sirupsen/logrus"
+)
+
+var frameContentTypes = map[string]string{
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"webp": "image/webp",
+}
+
+type VideoGetFrameHandler struct {
+	S3Client clients.IS3Client
+	UUIDGen  clients.IUUIDGenerator
+}
+
+// VideoGetFrameHandler godoc
+// @Summary Get video frame
+// @Description Get a still image of the video at an arbitrary timestamp
+// @Tags video
+// @Produce jpeg,png
+// @Param id path string true "Video ID"
+// @Param t query number true "Timestamp in seconds"
+// @Param width query int false "Image width, source width if not set"
+// @Param format query string false "Image format (jpeg, png, webp), jpeg if not set"
+// @Success 200 {string} string "Video frame"
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+// @Router /api/v1/videos/{id}/frame [get]
+func (v VideoGetFrameHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+	log.Debug("GET VideoGetFrameHandler - parameters ", vars, query)
+
+	id := vars["id"]
+	if !v.UUIDGen.IsValidUUID(id) {
+		log.Error("Invalid id")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	t, err := strconv.ParseFloat(query.Get("t"), 64)
+	if err != nil || t < 0 {
+		log.Error("Invalid timestamp ", query.Get("t"))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	width := uint64(0)
+	if query.Get("width") != "" {
+		width, err = strconv.ParseUint(query.Get("width"), 10, 16)
+		if err != nil {
+			log.Error("Invalid width ", query.Get("width"))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	format := query.Get("format")
+	if format == "" {
+		format = "jpeg"
+	}
+	contentType, ok := frameContentTypes[format]
+	if !ok {
+		log.Error("Unsupported frame format ", format)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Serve the frame from the cache if it has already been extracted
+	cachePath := fmt.Sprintf("%v/frames/%v_%v.%v", id, strconv.FormatFloat(t, 'f', 3, 64), width, format)
+	if cachedFrame, err := v.S3Client.GetObject(r.Context(), cachePath); err == nil {
+		defer cachedFrame.Close()
+		log.Debug("Frame found in cache ", cachePath)
+		w.Header().Set("Content-Type", contentType)
+		if _, err := io.Copy(w, cachedFrame); err != nil {
+			log.Error("Unable to stream frame", err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	frame, err := v.extractFrame(r.Context(), id, t, uint32(width), format)
+	if err != nil {
+		log.Error("Cannot extract frame : ", err)
+		if errors.Is(err, errTimestampOutOfRange) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if _, err := w.Write(frame); err != nil {
+		log.Error("Unable to stream frame", err)
+		return
+	}
+
+	if err := v.S3Client.PutObjectInput(r.Context(), bytes.NewReader(frame), cachePath); err != nil {
+		log.Error("Cannot cache frame "+cachePath+" : ", err)
+	}
+}
+
+func (v VideoGetFrameHandler) extractFrame(ctx context.Context, id string, t float64, width uint32, format string) ([]byte, error) {
+	// Find the variant and the segment containing the timestamp
+	masterObject, err := v.S3Client.GetObject(ctx, id+"/master.m3u8")
+	if err != nil {
+		return nil, err
+	}
+	defer masterObject.Close()
+	master, err := hls.DecodeMaster(masterObject)
+	if err != nil {
+		return nil, err
+	}
+	variant, err := selectVariant(master, width)
+	if err != nil {
+		return nil, err
+	}
+
+	variantPath := path.Join(id, variant.URI)
+	variantObject, err := v.S3Client.GetObject(ctx, variantPath)
+	if err != nil {
+		return nil, err
+	}
+	defer variantObject.Close()
+	media, err := hls.DecodeMedia(variantObject)
+	if err != nil {
+		return nil, err
+	}
+	segment, segmentStart, err := locateSegment(media, t)
+	if err != nil {
+		return nil, err
+	}
+
+	segmentObject, err := v.S3Client.GetObject(ctx, path.Join(path.Dir(variantPath), segment.URI))
+	if err != nil {
+		return nil, err
+	}
+	defer segmentObject.Close()
+
+	cmd, err := ffmpeg.CreateFrameCommand(ctx, t-segmentStart, width, format)
+	if err != nil {
+		return nil, err
+	}
+
+	var frame bytes.Buffer
+	if err := ffmpeg.TransformHLSPart(cmd, segmentObject, &frame); err != nil {
+		return nil, err
+	}
+	if frame.Len() == 0 {
+		return nil, fmt.Errorf("no frame extracted at %v in %v", t-segmentStart, segment.URI)
+	}
+
+	return frame.Bytes(), nil
+}
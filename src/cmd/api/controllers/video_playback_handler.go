@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/mux"
+	jsonDTO "github.com/rishirishhh/vought/src/cmd/api/dto/json"
+	"github.com/rishirishhh/vought/src/cmd/api/models"
+	"github.com/rishirishhh/vought/src/cmd/api/playback"
+	"github.com/rishirishhh/vought/src/pkg/clients"
+	log "github.com/sirupsen/logrus"
+)
+
+type VideoPlaybackTokenHandler struct {
+	Signer  *playback.Signer
+	UUIDGen clients.IUUIDGenerator
+}
+
+type PlaybackTokenResponse struct {
+	PlaybackToken jsonDTO.PlaybackTokenJson   `json:"playback"`
+	Links         map[string]jsonDTO.LinkJson `json:"_links"`
+}
+
+// VideoPlaybackTokenHandler godoc
+// @Summary Get video playback token
+// @Description Get a signed and expiring token to play the video streams without basic auth
+// @Tags video
+// @Produce json
+// @Param id path string true "Video ID"
+// @Success 200 {object} PlaybackTokenResponse "Playback token and Links (HATEOAS)"
+// @Failure 400 {string} string
+// @Failure 500 {string} string
+// @Failure 501 {string} string "Signed playback URLs are disabled"
+// @Router /api/v1/videos/{id}/playback [get]
+func (v VideoPlaybackTokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	log.Debug("GET VideoPlaybackTokenHandler - parameters ", vars)
+
+	id := vars["id"]
+	if !v.UUIDGen.IsValidUUID(id) {
+		log.Error("Invalid id")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if v.Signer == nil {
+		log.Error("Signed playback URLs are disabled, no secret configured")
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	// The token names the viewer it is issued to, the API is protected by basic auth
+	viewerID, _, _ := r.BasicAuth()
+	token, expiresAt := v.Signer.Sign(id, viewerID, v.Signer.ClientIP(r), time.Now())
+	playbackToken := &models.PlaybackToken{Token: token, ExpiresAt: expiresAt}
+
+	// Include the stream link carrying the token into response (HATEOAS)
+	query := url.Values{"token": {token}}
+	links := map[string]jsonDTO.LinkJson{
+		"stream": jsonDTO.LinkToLinkJson(&models.Link{Href: "api/v1/videos/" + id + "/streams/master.m3u8?" + query.Encode(), Method: "GET"}),
+	}
+
+	payload, err := json.Marshal(PlaybackTokenResponse{PlaybackToken: jsonDTO.PlaybackTokenToPlaybackTokenJson(playbackToken), Links: links})
+	if err != nil {
+		log.Error("Unable to parse data struct in json ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(payload)
+}
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gorilla/mux"
+	"github.com/rishirishhh/vought/src/pkg/clients"
+	log "github.com/sirupsen/logrus"
+)
+
+// Previews never change once encoded, clients and CDNs can keep them for a day
+const previewCacheControl string = "public, max-age=86400"
+
+var previewContentTypes = map[string]string{
+	".mp4":  "video/mp4",
+	".webp": "image/webp",
+}
+
+type VideoGetRenditionsHandler struct {
+	S3Client clients.IS3Client
+	UUIDGen  clients.IUUIDGenerator
+}
+
+// VideoGetRenditionsHandler godoc
+// @Summary Get video renditions
+// @Description Get the list of renditions (HLS variants, storyboard, previews, cover) of an encoded video
+// @Tags video
+// @Produce json
+// @Param id path string true "Video ID"
+// @Success 200 {string} string "Renditions metadata"
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+// @Router /api/v1/videos/{id}/renditions [get]
+func (v VideoGetRenditionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	log.Debug("GET VideoGetRenditionsHandler - parameters ", vars)
+
+	id := vars["id"]
+	if !v.UUIDGen.IsValidUUID(id) {
+		log.Error("Invalid id")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	object, err := v.S3Client.GetObject(r.Context(), id+"/renditions.json")
+	if err != nil {
+		log.Error("Failed to open renditions "+id+"/renditions.json ", err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	defer object.Close()
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = io.Copy(w, object); err != nil {
+		log.Error("Unable to stream renditions", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+type VideoGetPreviewHandler struct {
+	S3Client clients.IS3Client
+	UUIDGen  clients.IUUIDGenerator
+}
+
+// VideoGetPreviewHandler godoc
+// @Summary Get video animated preview
+// @Description Get a short silent looping preview of the video
+// @Tags video
+// @Produce mpeg
+// @Param id path string true "Video ID"
+// @Param filename path string true "Preview name (preview.mp4, preview.webp)"
+// @Success 200 {string} string "Animated preview"
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+// @Router /api/v1/videos/{id}/previews/{filename} [get]
+func (v VideoGetPreviewHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	log.Debug("GET VideoGetPreviewHandler - parameters ", vars)
+
+	id := vars["id"]
+	if !v.UUIDGen.IsValidUUID(id) {
+		log.Error("Invalid id")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	filename := vars["filename"]
+	contentType, ok := previewContentTypes[filepath.Ext(filename)]
+	if !ok {
+		log.Error("Unsupported preview ", filename)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	// Range support is required by some browsers to play mp4 previews
+	if err := serveS3Object(w, r, v.S3Client, id+"/previews/"+filename, contentType, previewCacheControl); err != nil {
+		log.Error("Failed to open preview "+id+"/previews/"+filename+" ", err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+}
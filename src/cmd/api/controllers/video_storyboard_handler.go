@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rishirishhh/vought/src/pkg/clients"
+	log "github.com/sirupsen/logrus"
+)
+
+type VideoGetStoryboardHandler struct {
+	S3Client clients.IS3Client
+	UUIDGen  clients.IUUIDGenerator
+}
+
+// VideoGetStoryboardHandler godoc
+// @Summary Get video storyboard
+// @Description Get WebVTT storyboard mapping time ranges to thumbnails, for seek previews
+// @Tags video
+// @Produce plain
+// @Param id path string true "Video ID"
+// @Success 200 {string} string "WebVTT storyboard"
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+// @Router /api/v1/videos/{id}/storyboard.vtt [get]
+func (v VideoGetStoryboardHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	log.Debug("GET VideoGetStoryboardHandler - parameters ", vars)
+
+	id := vars["id"]
+	if !v.UUIDGen.IsValidUUID(id) {
+		log.Error("Invalid id")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	object, err := v.S3Client.GetObject(r.Context(), id+"/storyboard.vtt")
+	if err != nil {
+		log.Error("Failed to open storyboard "+id+"/storyboard.vtt ", err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	defer object.Close()
+
+	w.Header().Set("Content-Type", "text/vtt")
+	if _, err = io.Copy(w, object); err != nil {
+		log.Error("Unable to stream storyboard", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+type VideoGetStoryboardImageHandler struct {
+	S3Client clients.IS3Client
+	UUIDGen  clients.IUUIDGenerator
+}
+
+// VideoGetStoryboardImageHandler godoc
+// @Summary Get video storyboard sprite
+// @Description Get a sprite sheet of thumbnails referenced by the storyboard
+// @Tags video
+// @Produce jpeg
+// @Param id path string true "Video ID"
+// @Param filename path string true "Sprite name"
+// @Success 200 {string} string "Sprite sheet (.jpeg)"
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+// @Router /api/v1/videos/{id}/storyboard/{filename} [get]
+func (v VideoGetStoryboardImageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	log.Debug("GET VideoGetStoryboardImageHandler - parameters ", vars)
+
+	id := vars["id"]
+	if !v.UUIDGen.IsValidUUID(id) {
+		log.Error("Invalid id")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	filename := vars["filename"]
+	object, err := v.S3Client.GetObject(r.Context(), id+"/storyboard/"+filename)
+	if err != nil {
+		log.Error("Failed to open storyboard sprite "+id+"/storyboard/"+filename+" ", err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	defer object.Close()
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	if _, err = io.Copy(w, object); err != nil {
+		log.Error("Unable to stream storyboard sprite", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
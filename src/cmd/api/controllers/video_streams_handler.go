@@ -1,25 +1,31 @@
 package controllers
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"io"
+	"math"
 	"net/http"
+	"path"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/rishirishhh/vought/src/cmd/api/cache"
 	"github.com/rishirishhh/vought/src/cmd/api/metrics"
+	"github.com/rishirishhh/vought/src/cmd/api/playback"
 	"github.com/rishirishhh/vought/src/pkg/clients"
-	"github.com/rishirishhh/vought/src/pkg/transformer/v1"
+	transformerv2 "github.com/rishirishhh/vought/src/pkg/transformer/v2"
 	log "github.com/sirupsen/logrus"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type VideoGetMasterHandler struct {
-	S3Client clients.IS3Client
-	UUIDGen  clients.IUUIDGenerator
+	S3Client         clients.IS3Client
+	UUIDGen          clients.IUUIDGenerator
+	ServiceDiscovery clients.ServiceDiscovery
 }
 
 // VideoGetMasterHandler godoc
@@ -28,9 +34,16 @@ type VideoGetMasterHandler struct {
 // @Tags video
 // @Produce plain
 // @Param id path string true "Video ID"
+// @Param token query string false "Signed playback token, replaces basic auth and is carried by every URI of the playlist"
+// @Param filter query []string false "List of filters, name[:key=value,...], carried by every URI of the playlist"
+// @Param max_height query int false "Maximum height of the variants listed in the playlist"
 // @Success 200 {string} string "HLS video master"
+// @Success 206 {string} string "Part of HLS video master"
+// @Success 304 {string} string
 // @Failure 400 {string} string
+// @Failure 403 {string} string "Filters read a video the playback token does not grant"
 // @Failure 404 {string} string
+// @Failure 416 {string} string
 // @Failure 500 {string} string
 // @Router /api/v1/videos/{id}/streams/master.m3u8 [get]
 func (v VideoGetMasterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -44,16 +57,34 @@ func (v VideoGetMasterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	object, err := v.S3Client.GetObject(r.Context(), id+"/master.m3u8")
+	rewrite, err := playlistRewriteFromRequest(r)
 	if err != nil {
-		log.Error("Failed to open video "+id+"/master.m3u8 ", err)
-		w.WriteHeader(http.StatusNotFound)
+		log.Error("Invalid playlist parameters : ", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Filters are checked once for the whole stream, rather than failing on every segment
+	chain, err := parseFilters(v.ServiceDiscovery, rewrite.query["filter"])
+	if err != nil {
+		log.Error("Invalid filters : ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if principal, _ := playback.PrincipalFromContext(r.Context()); !chain.allowedFor(principal) {
+		log.Error("Filters read videos the principal cannot access")
+		w.WriteHeader(http.StatusForbidden)
 		return
 	}
 
-	if _, err = io.Copy(w, object); err != nil {
-		log.Error("Unable to stream video master", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	if rewrite.isNeeded() {
+		err = serveRewrittenPlaylist(w, r, v.S3Client, id+"/master.m3u8", rewrite)
+	} else {
+		err = serveS3Object(w, r, v.S3Client, id+"/master.m3u8", streamContentTypes[".m3u8"], playlistCacheControl)
+	}
+	if err != nil {
+		log.Error("Failed to open video "+id+"/master.m3u8 ", err)
+		w.WriteHeader(http.StatusNotFound)
 		return
 	}
 }
@@ -62,6 +93,11 @@ type VideoGetSubPartHandler struct {
 	S3Client         clients.IS3Client
 	UUIDGen          clients.IUUIDGenerator
 	ServiceDiscovery clients.ServiceDiscovery
+	SegmentCache     *cache.SegmentCache
+	// Serve untransformed segments when no transformer is available
+	Fallback bool
+	// Maximum duration of a transformation, without limit if zero
+	Timeout time.Duration
 }
 
 // VideoGetSubPartHandler godoc
@@ -72,11 +108,20 @@ type VideoGetSubPartHandler struct {
 // @Param id path string true "Video ID"
 // @Param quality path string true "Video quality"
 // @Param filename path string true "Video sub part name"
-// @Param filter query []string false "List of required filters"
+// @Param filter query []string false "List of required filters, name[:key=value,...]"
+// @Param token query string false "Signed playback token, replaces basic auth"
 // @Success 200 {string} string "Video sub part (.ts)"
+// @Success 206 {string} string "Part of video sub part, without filter only"
+// @Header 200 {string} Warning "Set when no transformer is available and the segment is served untransformed"
+// @Success 304 {string} string
 // @Failure 400 {string} string
+// @Failure 403 {string} string "Filters read a video the playback token does not grant"
 // @Failure 404 {string} string
+// @Failure 416 {string} string
 // @Failure 500 {string} string
+// @Failure 503 {string} string "No transformer available, or every one is busy"
+// @Header 503 {integer} Retry-After "Seconds after which busy transformers can take the request"
+// @Failure 504 {string} string "Transformation timed out"
 // @Router /api/v1/videos/{id}/streams/{quality}/{filename} [get]
 func (v VideoGetSubPartHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -92,121 +137,201 @@ func (v VideoGetSubPartHandler) ServeHTTP(w http.ResponseWriter, r *http.Request
 
 	quality := vars["quality"]
 	filename := vars["filename"]
-	transformers := query["filter"]
+	filters := query["filter"]
 	s3VideoPath := id + "/" + quality + "/" + filename
 
-	if strings.Contains(filename, "segment_index") || transformers == nil {
-		object, err := v.S3Client.GetObject(r.Context(), id+"/"+quality+"/"+filename)
+	if strings.Contains(filename, "segment_index") || filters == nil {
+		rewrite, err := playlistRewriteFromRequest(r)
+		if err != nil {
+			log.Error("Invalid playlist parameters : ", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		switch {
+		case path.Ext(filename) == ".m3u8" && rewrite.isNeeded():
+			err = serveRewrittenPlaylist(w, r, v.S3Client, s3VideoPath, rewrite)
+		case path.Ext(filename) == ".m3u8":
+			err = serveS3Object(w, r, v.S3Client, s3VideoPath, streamContentTypes[".m3u8"], playlistCacheControl)
+		default:
+			err = serveS3Object(w, r, v.S3Client, s3VideoPath, streamContentTypes[path.Ext(filename)], segmentCacheControl)
+		}
 		if err != nil {
 			log.Error("Failed to open video videoPath", err)
 			w.WriteHeader(http.StatusNotFound)
 			return
 		}
+	} else {
+		chain, err := parseFilters(v.ServiceDiscovery, filters)
+		if err != nil {
+			log.Error("Invalid filters : ", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		principal, _ := playback.PrincipalFromContext(r.Context())
+		if !chain.allowedFor(principal) {
+			log.Error("Filters read videos the principal cannot access")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		steps := chain.steps
 
-		if _, err := io.Copy(w, object); err != nil {
-			log.Error("Unable to stream subpart", err)
-			w.WriteHeader(http.StatusInternalServerError)
+		// Transformed parts are generated on the fly, their size is unknown without transforming them
+		w.Header().Set("Content-Type", streamContentTypes[".ts"])
+		stream := &flushWriter{w: w}
+		if chain.perViewer {
+			stream.private()
+		}
+		if r.Method == http.MethodHead {
 			return
 		}
-	} else {
+
 		// Add metrics (should be move into transformations service implem)
-		for _, service := range transformers {
-			switch service {
-			case "gray":
-				metrics.CounterVideoTransformGray.Inc()
-			case "flip":
-				metrics.CounterVideoTransformFlip.Inc()
-			}
+		for _, filter := range stepNames(steps) {
+			metrics.CounterVideoTransform.WithLabelValues(filter).Inc()
 		}
 
-		videoPart, err := v.getVideoPart(r.Context(), s3VideoPath, transformers)
-		if err != nil {
+		// The segment is streamed while it is transformed, a client leaving cancels the
+		// transformation through the request context
+		if err := v.streamTransformedPart(r.Context(), s3VideoPath, steps, principal, chain.perViewer, stream); err != nil {
+			if r.Context().Err() != nil {
+				log.Debug("Client left during transformation of ", s3VideoPath)
+				return
+			}
 			log.Error("Cannot get video part : ", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			if !stream.written && v.Fallback && errors.Is(err, clients.ErrNoTransformerAvailable) {
+				v.serveUntransformedPart(w, r, s3VideoPath)
+				return
+			}
+			if stream.written {
+				// The status has already been sent, the connection is aborted so that the client
+				// does not mistake a truncated segment for a complete one
+				panic(http.ErrAbortHandler)
+			}
+			if retryDelay, busy := transformerv2.RetryAfter(err); busy {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryDelay.Seconds()))))
+			}
+			w.WriteHeader(transformStatus(err))
 			return
 		}
+	}
+}
 
-		if _, err := io.Copy(w, videoPart); err != nil {
-			log.Error("Unable to stream subpart", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
+// Serve the segment as it is stored, when it cannot be transformed
+func (v VideoGetSubPartHandler) serveUntransformedPart(w http.ResponseWriter, r *http.Request, s3VideoPath string) {
+	log.Warn("No transformer available, serving untransformed ", s3VideoPath)
+	w.Header().Set("Warning", `199 - "transformers unavailable, segment served untransformed"`)
+
+	// The client should ask again for the transformed segment
+	if err := serveS3Object(w, r, v.S3Client, s3VideoPath, streamContentTypes[".ts"], "no-store"); err != nil {
+		log.Error("Failed to open video "+s3VideoPath+" ", err)
+		w.WriteHeader(http.StatusNotFound)
 	}
 }
 
-func (v VideoGetSubPartHandler) getVideoPart(ctx context.Context, s3VideoPath string, transformers []string) (io.Reader, error) {
-	if len(transformers) == 0 {
-		// Retrieve the video part from aws S3
-		var err error
-		videoPart, err := v.S3Client.GetObject(ctx, s3VideoPath)
-		if err != nil {
-			log.Error("Failed to get video from S3 : ", err)
-			return nil, err
-		}
-		return videoPart, nil
+// Parts personalised for their viewer are not cached, they would be served to other viewers. The
+// transformers tell it with the part, even if the service discovery does not advertise it.
+func (v VideoGetSubPartHandler) streamTransformedPart(ctx context.Context, s3VideoPath string, steps []*transformerv2.Step, principal playback.Principal, perViewer bool, w *flushWriter) error {
+	if v.SegmentCache == nil || perViewer {
+		_, err := v.transformVideoPart(ctx, s3VideoPath, steps, principal, w, w.private)
+		return err
+	}
+	// Parameters are part of the cache key, in their canonical form
+	return v.SegmentCache.Get(ctx, s3VideoPath, stepFilters(steps), w, func(ctx context.Context, cacheWriter io.Writer) (bool, error) {
+		return v.transformVideoPart(ctx, s3VideoPath, steps, principal, cacheWriter, w.private)
+	})
+}
 
-	} else {
-		// Ask for video part transformation
-		start := time.Now()
+// Ask for video part transformation, chunks are written to w as they are received. The deadline
+// of the transformation is propagated to every transformer of the chain. If the part depends on
+// its viewer, private is called before anything is written and true is returned.
+func (v VideoGetSubPartHandler) transformVideoPart(ctx context.Context, s3VideoPath string, steps []*transformerv2.Step, principal playback.Principal, w io.Writer, private func()) (bool, error) {
+	start := time.Now()
+	if v.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, v.Timeout)
+		defer cancel()
+	}
 
-		// Connect to RPC Client
-		clientRPC, err := v.connectClientRPC(transformers[len(transformers)-1])
-		if err != nil {
-			log.Error("Cannot connect to RPC client : ", err)
-			return nil, err
-		}
+	// Ask an instance of the last transformer for video transformation, other instances are
+	// tried if it fails before sending anything
+	request := transformerv2.TransformVideoRequest{
+		Videopath: s3VideoPath,
+		Steps:     steps,
+		Principal: &transformerv2.Principal{ViewerId: principal.ViewerID, SessionId: principal.SessionID, VideoId: principal.VideoID},
+	}
+	streamResponse, err := clients.OpenTransformStream(ctx, v.ServiceDiscovery, &request)
+	if err != nil {
+		log.Error("Failed to transform video : ", err)
+		return false, err
+	}
+	defer streamResponse.Close()
+	if streamResponse.PerViewer() {
+		private()
+	}
 
-		// Ask RPC Client for video transformation
-		request := transformer.TransformVideoRequest{
-			Videopath:       s3VideoPath,
-			TransformerList: transformers,
-		}
-		streamResponse, err := clientRPC.TransformVideo(ctx, &request)
+	for {
+		res, err := streamResponse.Recv()
 		if err != nil {
-			log.Error("Failed to transform video : ", err)
-			return nil, err
-		}
-
-		var videoPart bytes.Buffer
-		for {
-			res, err := streamResponse.Recv()
-			if err != nil {
-				if err == io.EOF {
-					break
-				}
-				log.Error("Failed to receive stream : ", err)
-				return nil, err
+			if err == io.EOF {
+				break
 			}
+			log.Error("Failed to receive stream : ", err)
+			return streamResponse.PerViewer(), err
+		}
 
-			if res != nil {
-				_, err := videoPart.Write(res.Chunk)
-				if err != nil {
-					log.Error("Failed to write : ", err)
-					return nil, err
-				}
+		if res != nil {
+			if _, err := w.Write(res.Chunk); err != nil {
+				log.Error("Failed to write : ", err)
+				return streamResponse.PerViewer(), err
 			}
 		}
-
-		log.Debug("transformation execution time : ", time.Since(start).Seconds())
-		metrics.StoreTranformationTime(start, transformers)
-		return &videoPart, nil
 	}
+
+	log.Debug("transformation execution time : ", time.Since(start).Seconds())
+	metrics.StoreTranformationTime(start, stepNames(steps))
+	return streamResponse.PerViewer(), nil
 }
 
-func (v VideoGetSubPartHandler) connectClientRPC(clientName string) (transformer.TransformerServiceClient, error) {
-	// Retrieve service address and port
-	tfServices, err := v.ServiceDiscovery.GetTransformationService(clientName)
-	if err != nil {
-		log.Errorf("Cannot get address for service name %v : %v", clientName, err)
-		return nil, err
+// HTTP status of a failed transformation
+func transformStatus(err error) int {
+	switch status.Code(err) {
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unavailable, codes.ResourceExhausted:
+		return http.StatusServiceUnavailable
 	}
+	if errors.Is(err, clients.ErrNoTransformerAvailable) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
 
-	opts := grpc.WithTransportCredentials(insecure.NewCredentials())
-	conn, err := grpc.Dial(tfServices, opts)
-	if err != nil {
-		log.Errorf("Cannot open TCP connection with grpc %v transformer server : %v", clientName, err)
-		return nil, err
+// flushWriter flushes every write to the client, and records whether the response has started
+type flushWriter struct {
+	w       http.ResponseWriter
+	written bool
+}
+
+// Parts personalised for the viewer must not be served to others by shared caches. The header is
+// only set if the response has not started.
+func (f *flushWriter) private() {
+	if !f.written {
+		f.w.Header().Set("Cache-Control", "private, no-store")
 	}
+}
 
-	return transformer.NewTransformerServiceClient(conn), nil
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if n > 0 {
+		f.written = true
+	}
+	if flusher, ok := f.w.(http.Flusher); ok && err == nil {
+		flusher.Flush()
+	}
+	return n, err
 }
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/rishirishhh/vought/src/cmd/api/cache"
+	"github.com/rishirishhh/vought/src/cmd/api/db/dao"
+	"github.com/rishirishhh/vought/src/pkg/clients"
+	"github.com/rishirishhh/vought/src/pkg/subtitles"
+)
+
+// Maximum size of a subtitle track
+const maxSubtitlesSize int64 = 5 << 20
+
+type VideoSubtitlesHandler struct {
+	S3Client     clients.IS3Client
+	VideosDAO    *dao.VideosDAO
+	UUIDGen      clients.IUUIDGenerator
+	SegmentCache *cache.SegmentCache
+}
+
+// VideoSubtitlesHandler godoc
+// @Summary Attach a subtitle track to a video
+// @Description Attach a WebVTT or SRT subtitle track to a video, replacing the track of the same name. It is stored as WebVTT and can be burnt into the streams with the subtitles filter.
+// @Tags video
+// @Accept plain
+// @Produce plain
+// @Param id path string true "Video ID"
+// @Param track path string true "Track name, e.g. a language code"
+// @Param subtitles body string true "WebVTT or SRT track"
+// @Success 204 {string} string
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 413 {string} string
+// @Failure 500 {string} string
+// @Router /api/v1/videos/{id}/subtitles/{track} [put]
+func (v VideoSubtitlesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	log.Debug("PUT VideoSubtitlesHandler - parameters ", vars)
+
+	id := vars["id"]
+	if !v.UUIDGen.IsValidUUID(id) {
+		log.Error("Invalid id")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	track := vars["track"]
+	if !subtitles.IsValidTrack(track) {
+		log.Error("Invalid track name ", track)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if _, err := v.VideosDAO.GetVideo(r.Context(), id); err != nil {
+		log.Error("Cannot find video "+id+" : ", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSubtitlesSize))
+	if err != nil {
+		log.Error("Cannot read subtitle track : ", err)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		return
+	}
+	cues, err := subtitles.Parse(data)
+	if err != nil {
+		log.Error("Invalid subtitle track : ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := v.S3Client.PutObjectInput(r.Context(), bytes.NewReader(subtitles.FormatVTT(cues)), subtitles.TrackKey(id, track)); err != nil {
+		log.Error("Cannot store subtitle track "+track+" of video "+id+" : ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Segments may have been transformed with the previous track
+	if v.SegmentCache != nil {
+		if err := v.SegmentCache.Invalidate(r.Context(), id); err != nil {
+			log.Error("Cannot invalidate transformed segments of video "+id+" : ", err)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -20,7 +20,7 @@ type TransformerServiceListResponse struct {
 
 // VideoTransformerListHandler godoc
 // @Summary Get list of existing services
-// @Description Get list of existing services
+// @Description Get list of existing services, with their version, description and parameter schema
 // @Tags services
 // @Produce json
 // @Success 200 {object} TransformerServiceListResponse "Service list"
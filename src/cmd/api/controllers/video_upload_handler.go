@@ -17,6 +17,7 @@ import (
 	jsonDTO "github.com/rishirishhh/vought/src/cmd/api/dto/json"
 	"github.com/rishirishhh/vought/src/cmd/api/dto/protobuf"
 	protobufDTO "github.com/rishirishhh/vought/src/cmd/api/dto/protobuf"
+	"github.com/rishirishhh/vought/src/cmd/api/metrics"
 	"github.com/rishirishhh/vought/src/cmd/api/models"
 	"github.com/rishirishhh/vought/src/pkg/clients"
 	"github.com/rishirishhh/vought/src/pkg/events"
@@ -53,6 +54,7 @@ type Response struct {
 // @Router /api/v1/videos/upload [post]
 func (v VideoUploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) { //nolint:cyclop
 	log.Debug("POST VideoUploadHandler")
+	metrics.CounterVideoUploadRequest.Inc()
 
 	// Fetch title
 	title := r.FormValue("title")
@@ -234,11 +236,17 @@ func (v VideoUploadHandler) uploadCover(ctx context.Context, cover multipart.Fil
 	return coverPath, nil
 }
 
-func (v VideoUploadHandler) uploadVideo(ctx context.Context, videoID, title, videoPath, coverPath string, file multipart.File, video *models.Video) (*models.Video, error) {
+func (v VideoUploadHandler) uploadVideo(ctx context.Context, videoID, title, videoPath, coverPath string, file multipart.File, video *models.Video) (uploaded *models.Video, err error) {
+	defer func() {
+		if err != nil {
+			metrics.CounterVideoUploadFail.Inc()
+		} else {
+			metrics.CounterVideoUploadSuccess.Inc()
+		}
+	}()
 
 	// video not nil means that the video already exists. So we are in case of recover after error
 	if video == nil {
-		var err error
 		video, err = v.VideosDAO.CreateVideo(ctx, videoID, title, int(models.UPLOADING), videoPath, coverPath)
 		if err != nil {
 			log.Error("Cannot generate new uploadID : ", err)
@@ -340,6 +348,7 @@ func (v VideoUploadHandler) sendVideoForEncoding(ctx context.Context, video *mod
 		v.videoEncodeFailed(ctx, video)
 		return err
 	}
+	metrics.CounterVideoEncodeRequest.Inc()
 
 	// Update video status : ENCODING
 	video.Status = models.ENCODING
@@ -15,6 +15,8 @@ type VideosRequestName int
 
 const (
 	CreateTableVideosReq VideosRequestName = iota
+	HasFailureReasonColumn
+	AddFailureReasonColumn
 	CreateVideo
 	UpdateVideo
 	GetVideo
@@ -37,13 +39,18 @@ var VideosRequests = map[VideosRequestName]string{
 			updated_at      DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 			source_path     VARCHAR(64) NOT NULL,
 			cover_path      VARCHAR(64),
+			failure_reason  VARCHAR(512) NOT NULL DEFAULT '',
 
 			CONSTRAINT pk PRIMARY KEY (id),
 			CONSTRAINT unique_title UNIQUE (title)
 		);`,
 
+	// Tables created before the failure reason was stored lack its column
+	HasFailureReasonColumn: "SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'videos' AND column_name = 'failure_reason'",
+	AddFailureReasonColumn: "ALTER TABLE videos ADD COLUMN failure_reason VARCHAR(512) NOT NULL DEFAULT ''",
+
 	CreateVideo:             "INSERT INTO videos (id, title, video_status, source_path, cover_path) VALUES (?, ? , ?, ?, ?)",
-	UpdateVideo:             "UPDATE videos SET title = ?, video_status = ?, uploaded_at = ?, source_path = ?, cover_path = ? WHERE id = ?",
+	UpdateVideo:             "UPDATE videos SET title = ?, video_status = ?, uploaded_at = ?, source_path = ?, cover_path = ?, failure_reason = ? WHERE id = ?",
 	GetVideo:                "SELECT * FROM videos WHERE id = ?",
 	GetVideoFromTitle:       "SELECT * FROM videos WHERE title = ?",
 	GetVideosTitleAsc:       "SELECT * FROM videos WHERE video_status = ? ORDER BY title ASC LIMIT ?,?",
@@ -152,6 +159,19 @@ func createTableVideos(ctx context.Context, db *sql.DB) error {
 	}
 
 	log.Debug("Table videos created (or existed already)")
+
+	var hasFailureReason int
+	if err := db.QueryRowContext(ctx, VideosRequests[HasFailureReasonColumn]).Scan(&hasFailureReason); err != nil {
+		log.Error("Cannot read columns of table videos : ", err)
+		return err
+	}
+	if hasFailureReason == 0 {
+		if _, err := db.ExecContext(ctx, VideosRequests[AddFailureReasonColumn]); err != nil {
+			log.Error("Cannot add column failure_reason : ", err)
+			return err
+		}
+		log.Info("Column failure_reason added to table videos")
+	}
 	return nil
 }
 
@@ -243,7 +263,7 @@ func (v VideosDAO) DeleteVideoTx(ctx context.Context, tx *sql.Tx, ID string) err
 }
 
 func (v VideosDAO) UpdateVideo(ctx context.Context, video *models.Video) error {
-	res, err := v.stmtUpdate.ExecContext(ctx, video.Title, video.Status, video.UploadedAt, video.SourcePath, video.CoverPath, video.ID)
+	res, err := v.stmtUpdate.ExecContext(ctx, video.Title, video.Status, video.UploadedAt, video.SourcePath, video.CoverPath, video.FailureReason, video.ID)
 	if err != nil {
 		log.Error("Error while update video : ", err)
 		return err
@@ -266,7 +286,7 @@ func (v VideosDAO) UpdateVideo(ctx context.Context, video *models.Video) error {
 
 func (v VideosDAO) UpdateVideoTx(ctx context.Context, tx *sql.Tx, video *models.Video) error {
 	stmt := tx.StmtContext(ctx, v.stmtUpdate)
-	res, err := stmt.ExecContext(ctx, video.Title, video.Status, video.UploadedAt, video.SourcePath, video.CoverPath, video.ID)
+	res, err := stmt.ExecContext(ctx, video.Title, video.Status, video.UploadedAt, video.SourcePath, video.CoverPath, video.FailureReason, video.ID)
 	if err != nil {
 		log.Error("Error while update video : ", err)
 		return err
@@ -299,6 +319,7 @@ func (v VideosDAO) GetVideo(ctx context.Context, ID string) (*models.Video, erro
 		&video.UpdatedAt,
 		&video.SourcePath,
 		&video.CoverPath,
+		&video.FailureReason,
 	)
 	if err != nil {
 		log.Error("Error, video not found : ", err)
@@ -319,6 +340,7 @@ func (v VideosDAO) GetVideoFromTitle(ctx context.Context, title string) (*models
 		&video.UpdatedAt,
 		&video.SourcePath,
 		&video.CoverPath,
+		&video.FailureReason,
 	)
 	if err != nil {
 		log.Error("Error, video not found : ", err)
@@ -382,6 +404,7 @@ func (v VideosDAO) GetVideos(ctx context.Context, attribute interface{}, ascendi
 			&row.UpdatedAt,
 			&row.SourcePath,
 			&row.CoverPath,
+			&row.FailureReason,
 		); err != nil {
 			log.Error("Cannot read rows : ", err)
 			return nil, err
@@ -1,6 +1,7 @@
 package json
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/rishirishhh/vought/src/cmd/api/models"
@@ -29,14 +30,16 @@ func VideoToVideoJson(video *models.Video) VideoJson {
 }
 
 type VideoStatus struct {
-	Title  string `json:"title" example:"AmazingTitle"`
-	Status string `json:"status" example:"UPLOADED"`
+	Title         string `json:"title" example:"AmazingTitle"`
+	Status        string `json:"status" example:"UPLOADED"`
+	FailureReason string `json:"failureReason,omitempty" example:"invalid encoding output : v0/segment3.ts is empty"`
 }
 
 func VideoToStatusJson(video *models.Video) VideoStatus {
 	videoStatus := VideoStatus{
-		Title:  video.Title,
-		Status: video.Status.String(),
+		Title:         video.Title,
+		Status:        video.Status.String(),
+		FailureReason: video.FailureReason,
 	}
 
 	return videoStatus
@@ -45,12 +48,16 @@ func VideoToStatusJson(video *models.Video) VideoStatus {
 type VideoInfo struct {
 	Title          string `json:"title" example:"amazingtitle"`
 	UploadDateUnix int64  `json:"uploadDateUnix" example:"1652173257"`
+	Status         string `json:"status" example:"Fail_encode"`
+	FailureReason  string `json:"failureReason,omitempty" example:"invalid encoding output : v0/segment3.ts is empty"`
 }
 
 func VideoToInfoJson(video *models.Video) VideoInfo {
 	videoInfo := VideoInfo{
 		Title:          video.Title,
 		UploadDateUnix: video.UploadedAt.Unix(),
+		Status:         video.Status.String(),
+		FailureReason:  video.FailureReason,
 	}
 
 	return videoInfo
@@ -71,13 +78,37 @@ func LinkToLinkJson(link *models.Link) LinkJson {
 }
 
 type TransformerServiceJson struct {
-	Name string `json:"name" example:"gray"`
+	Name        string          `json:"name" example:"gray"`
+	Version     string          `json:"version,omitempty" example:"1.0.0"`
+	Description string          `json:"description,omitempty" example:"Convert the video to shades of gray"`
+	Schema      json.RawMessage `json:"schema,omitempty" swaggertype:"object"`
+	PerViewer   bool            `json:"perViewer,omitempty"`
 }
 
 func TransformerServiceToTransformerServiceJson(transformerService models.TransformerService) TransformerServiceJson {
 	transformerServiceJson := TransformerServiceJson{
-		Name: transformerService.Name,
+		Name:        transformerService.Name,
+		Version:     transformerService.Version,
+		Description: transformerService.Description,
+		PerViewer:   transformerService.PerViewer,
+	}
+	if transformerService.Schema != "" {
+		transformerServiceJson.Schema = json.RawMessage(transformerService.Schema)
 	}
 
 	return transformerServiceJson
 }
+
+type PlaybackTokenJson struct {
+	Token     string    `json:"token" example:"YWFhYS1iNTZiLS4uLnwxNjUyMTczMjU3fA.c2lnbmF0dXJl"`
+	ExpiresAt time.Time `json:"expiresAt" example:"2022-04-15T12:59:52Z"`
+}
+
+func PlaybackTokenToPlaybackTokenJson(playbackToken *models.PlaybackToken) PlaybackTokenJson {
+	playbackTokenJson := PlaybackTokenJson{
+		Token:     playbackToken.Token,
+		ExpiresAt: playbackToken.ExpiresAt,
+	}
+
+	return playbackTokenJson
+}
@@ -35,10 +35,11 @@ func VideoProtobufToVideo(videoProto *contracts.Video) *models.Video {
 	}
 
 	video := models.Video{
-		ID:         videoProto.Id,
-		Status:     protoToModelStatus[videoProto.Status],
-		SourcePath: videoProto.Source,
-		CoverPath:  videoProto.CoverPath,
+		ID:            videoProto.Id,
+		Status:        protoToModelStatus[videoProto.Status],
+		SourcePath:    videoProto.Source,
+		CoverPath:     videoProto.CoverPath,
+		FailureReason: videoProto.FailureReason,
 	}
 
 	return &video
@@ -51,10 +52,11 @@ func VideoToVideoProtobuf(video *models.Video) *contracts.Video {
 	}
 
 	videoData := &contracts.Video{
-		Id:        video.ID,
-		Status:    modelToProtoStatus[video.Status],
-		Source:    video.SourcePath,
-		CoverPath: video.CoverPath,
+		Id:            video.ID,
+		Status:        modelToProtoStatus[video.Status],
+		Source:        video.SourcePath,
+		CoverPath:     video.CoverPath,
+		FailureReason: video.FailureReason,
 	}
 
 	return videoData
@@ -8,6 +8,7 @@ import (
 
 	contracts "github.com/rishirishhh/vought/src/pkg/contracts/v1"
 
+	"github.com/rishirishhh/vought/src/cmd/api/cache"
 	"github.com/rishirishhh/vought/src/cmd/api/config"
 	"github.com/rishirishhh/vought/src/cmd/api/db/dao"
 	"github.com/rishirishhh/vought/src/cmd/api/dto/protobuf"
@@ -17,7 +18,7 @@ import (
 	"github.com/rishirishhh/vought/src/pkg/events"
 )
 
-func ConsumeEvents(cfg config.Config, amqpVideoStatusUpdate clients.AmqpClient, videosDAO *dao.VideosDAO) {
+func ConsumeEvents(cfg config.Config, amqpVideoStatusUpdate clients.AmqpClient, videosDAO *dao.VideosDAO, segmentCache *cache.SegmentCache) {
 	// amqpClient for encoded video (encoder->api)
 	amqpClientVideoEncode, err := clients.NewAmqpClient(cfg.RabbitmqUser, cfg.RabbitmqPwd, cfg.RabbitmqAddr)
 	if err != nil {
@@ -54,14 +55,24 @@ func ConsumeEvents(cfg config.Config, amqpVideoStatusUpdate clients.AmqpClient,
 
 			videoDb.Status = video.Status
 			videoDb.CoverPath = video.CoverPath
+			// Cleared once the video is encoded again
+			videoDb.FailureReason = video.FailureReason
 			if err := videosDAO.UpdateVideo(context.Background(), videoDb); err != nil {
 				log.Errorf("Unable to update videos with status  %v: %v", videoDb.Status, err)
 			}
 
+			// Segments transformed from the previous encoding are outdated
+			if segmentCache != nil {
+				if err := segmentCache.Invalidate(context.Background(), video.ID); err != nil {
+					log.Errorf("Cannot remove transformed segments of video %v : %v", video.ID, err)
+				}
+			}
+
 			switch video.Status {
 			case models.COMPLETE:
 				metrics.CounterVideoEncodeSuccess.Inc()
 			case models.FAIL_ENCODE:
+				log.Warnf("Encoding of video %v failed : %v", video.ID, video.FailureReason)
 				metrics.CounterVideoEncodeFail.Inc()
 			}
 
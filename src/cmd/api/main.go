@@ -1,9 +1,19 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
 	"time"
 
 	"github.com/rishirishhh/vought/src/cmd/api/config"
+	"github.com/rishirishhh/vought/src/cmd/api/db/dao"
+	eventhandler "github.com/rishirishhh/vought/src/cmd/api/eventHandler"
+	"github.com/rishirishhh/vought/src/cmd/api/router"
+	"github.com/rishirishhh/vought/src/pkg/clients"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -24,4 +34,64 @@ func main() {
 		log.SetLevel(log.DebugLevel)
 	}
 
+	apiClients, err := router.NewClients(cfg)
+	if err != nil {
+		log.Fatal("Cannot create clients : ", err)
+	}
+
+	// Watch the transformers, the API itself is registered only if it has a local address
+	go func() {
+		serviceInfos := clients.ServiceInfos{
+			Name:    "api",
+			Address: cfg.LocalAddr,
+			Port:    int(cfg.Port),
+			Tags:    []string{"api"},
+		}
+		if err := apiClients.ServiceDiscovery.StartServiceDiscovery(serviceInfos); err != nil {
+			log.Fatal("Discovery Service crash : ", err)
+		}
+	}()
+
+	db, err := sql.Open("mysql", fmt.Sprintf("%v:%v@tcp(%v:%v)/%v?parseTime=true", cfg.MariadbUser, cfg.MariadbUserPwd, cfg.MariadbHost, cfg.MariadbPort, cfg.MariadbName))
+	if err != nil {
+		log.Fatal("Cannot open database : ", err)
+	}
+	defer db.Close()
+
+	videosDAO, err := dao.CreateVideosDAO(context.Background(), db)
+	if err != nil {
+		log.Fatal("Cannot create videos DAO : ", err)
+	}
+	uploadsDAO, err := dao.CreateUploadsDAO(context.Background(), db)
+	if err != nil {
+		log.Fatal("Cannot create uploads DAO : ", err)
+	}
+	DAOs := &router.DAOs{Db: db, VideosDAO: *videosDAO, UploadsDAO: *uploadsDAO}
+
+	// Listen to the encoded videos
+	go eventhandler.ConsumeEvents(cfg, apiClients.AmqpVideoStatusUpdate, videosDAO, apiClients.SegmentCache)
+
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%v", cfg.Port),
+		Handler: router.NewRouter(cfg, apiClients, DAOs),
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal("HTTP server error : ", err)
+		}
+	}()
+
+	// Wait for SIGINT.
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	<-sig
+
+	// Stop serviceDiscovery and wait for the pending requests
+	apiClients.ServiceDiscovery.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), GORILLA_MUX_SHUTDOWN_TIMEOUT)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Error("Cannot shutdown HTTP server : ", err)
+	}
+	time.Sleep(GOROUTINE_FLUSH_TIMEOUT)
 }
@@ -81,18 +81,20 @@ var (
 	})
 )
 
-var (
-	CounterVideoTransformGray = promauto.NewCounter(prometheus.CounterOpts{
-		Name: "api_gray_transformation_request",
-		Help: "The total number of gray transformation request",
-	})
+var CounterVideoTransform = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "api_transformation_request",
+		Help: "The total number of transformation requests, by filter. A chain counts once for each of its filters",
+	},
+	[]string{"filter"},
 )
 
-var (
-	CounterVideoTransformFlip = promauto.NewCounter(prometheus.CounterOpts{
-		Name: "api_flip_transformation_request",
-		Help: "The total number of flip transformation request",
-	})
+var CounterTransformCache = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "api_transformed_segment_cache_total",
+		Help: "The total number of transformed segment cache lookups, by tier (local or s3) and result (hit or miss)",
+	},
+	[]string{"tier", "result"},
 )
 
 func StoreTranformationTime(start time.Time, transformers []string) {
@@ -0,0 +1,10 @@
+package models
+
+import (
+	"time"
+)
+
+type PlaybackToken struct {
+	Token     string
+	ExpiresAt time.Time
+}
@@ -1,9 +1,21 @@
 package models
 
 type TransformerService struct {
-	Name string
+	Name        string
+	Version     string
+	Description string
+	// JSON schema of the transformer parameters
+	Schema string
+	// The output depends on the viewer, it is not cached
+	PerViewer bool
 }
 
-func CreateTransformerService(name string) *TransformerService {
-	return &TransformerService{Name: name}
+func CreateTransformerService(name string, version string, description string, schema string, perViewer bool) *TransformerService {
+	return &TransformerService{
+		Name:        name,
+		Version:     version,
+		Description: description,
+		Schema:      schema,
+		PerViewer:   perViewer,
+	}
 }
@@ -79,4 +79,6 @@ type Video struct {
 	UpdatedAt  *time.Time
 	SourcePath string
 	CoverPath  string
+	// Why the last encoding failed, empty if it did not
+	FailureReason string
 }
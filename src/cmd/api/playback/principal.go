@@ -0,0 +1,29 @@
+package playback
+
+import "context"
+
+// Principal is the authenticated viewer of a stream
+type Principal struct {
+	ViewerID string
+	// Empty if the stream is not played with a token
+	SessionID string
+	// Video the principal is restricted to, empty if it may access every video
+	VideoID string
+}
+
+// Tell whether the principal may access the video
+func (p Principal) CanAccess(videoID string) bool {
+	return p.VideoID == "" || p.VideoID == videoID
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// Retrieve the principal the request was authenticated as
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
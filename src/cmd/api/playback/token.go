@@ -0,0 +1,137 @@
+package playback
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid playback token")
+	ErrExpiredToken = errors.New("expired playback token")
+)
+
+// Signer issues and verifies HMAC-signed playback tokens. A token is bound to a video ID, an
+// expiry date and optionally to the client IP, and names the viewer it was issued to. It is
+// formatted as <payload>.<signature>, both base64url encoded, the payload being
+// <videoID>|<expiry unix>|<client IP>|<viewer ID>. Tokens issued without viewer have no last field.
+type Signer struct {
+	secret []byte
+	ttl    time.Duration
+	bindIP bool
+	// Proxies whose X-Forwarded-For header is trusted to find the client IP
+	trustedProxies []netip.Prefix
+}
+
+func NewSigner(secret string, ttl time.Duration, bindIP bool, trustedProxies []netip.Prefix) *Signer {
+	return &Signer{secret: []byte(secret), ttl: ttl, bindIP: bindIP, trustedProxies: trustedProxies}
+}
+
+func (s *Signer) Sign(videoID string, viewerID string, clientIP string, now time.Time) (string, time.Time) {
+	expiresAt := now.Add(s.ttl).Truncate(time.Second)
+	if !s.bindIP {
+		clientIP = ""
+	}
+
+	payload := videoID + "|" + strconv.FormatInt(expiresAt.Unix(), 10) + "|" + clientIP + "|" + viewerID
+	token := base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(s.signature(payload))
+	return token, expiresAt
+}
+
+// Verify the token and return the ID of the viewer it was issued to
+func (s *Signer) Verify(token string, videoID string, clientIP string, now time.Time) (string, error) {
+	encodedPayload, encodedSignature, found := strings.Cut(token, ".")
+	if !found {
+		return "", ErrInvalidToken
+	}
+
+	rawPayload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+
+	payload := string(rawPayload)
+	if !hmac.Equal(signature, s.signature(payload)) {
+		return "", ErrInvalidToken
+	}
+
+	// The viewer ID is the last field, it may contain the separator
+	fields := strings.SplitN(payload, "|", 4)
+	if len(fields) < 3 || fields[0] != videoID {
+		return "", ErrInvalidToken
+	}
+	if fields[2] != "" && fields[2] != clientIP {
+		return "", ErrInvalidToken
+	}
+
+	expiry, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	if now.After(time.Unix(expiry, 0)) {
+		return "", ErrExpiredToken
+	}
+
+	viewerID := ""
+	if len(fields) == 4 {
+		viewerID = fields[3]
+	}
+	return viewerID, nil
+}
+
+// Identify the playback session of a token, without disclosing the token
+func SessionID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (s *Signer) signature(payload string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// Retrieve the IP of the client a token is bound to. Behind trusted proxies, it is the last
+// address of X-Forwarded-For not added by one of them, the other addresses can be forged by the client.
+func (s *Signer) ClientIP(r *http.Request) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0 && s.isTrustedProxy(ip); i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if hop == "" {
+			continue
+		}
+		ip = hop
+	}
+	return ip
+}
+
+func (s *Signer) isTrustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, proxy := range s.trustedProxies {
+		if proxy.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
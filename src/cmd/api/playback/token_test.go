@@ -0,0 +1,88 @@
+package playback
+
+import (
+	"encoding/base64"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Verify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	videoID := "aaaa-b56b-4c1d-9a6e-123456789abc"
+
+	signer := NewSigner("secret", time.Hour, false, nil)
+	token, expiresAt := signer.Sign(videoID, "alice", "10.0.0.1", now)
+	require.Equal(t, now.Add(time.Hour), expiresAt)
+
+	ipSigner := NewSigner("secret", time.Hour, true, nil)
+	ipToken, _ := ipSigner.Sign(videoID, "bob|smith", "10.0.0.1", now)
+
+	// Tokens issued before they named their viewer
+	legacyPayload := videoID + "|" + strconv.FormatInt(expiresAt.Unix(), 10) + "|"
+	legacyToken := base64.RawURLEncoding.EncodeToString([]byte(legacyPayload)) + "." + base64.RawURLEncoding.EncodeToString(signer.signature(legacyPayload))
+
+	cases := []struct {
+		Name           string
+		GivenSigner    *Signer
+		GivenToken     string
+		GivenID        string
+		GivenIP        string
+		GivenNow       time.Time
+		ExpectedViewer string
+		ExpectError    error
+	}{
+		{Name: "Valid token", GivenSigner: signer, GivenToken: token, GivenID: videoID, GivenIP: "10.0.0.2", GivenNow: now, ExpectedViewer: "alice"},
+		{Name: "Valid token bound to IP", GivenSigner: ipSigner, GivenToken: ipToken, GivenID: videoID, GivenIP: "10.0.0.1", GivenNow: now, ExpectedViewer: "bob|smith"},
+		{Name: "Token without viewer", GivenSigner: signer, GivenToken: legacyToken, GivenID: videoID, GivenNow: now},
+		{Name: "Other client IP", GivenSigner: ipSigner, GivenToken: ipToken, GivenID: videoID, GivenIP: "10.0.0.2", GivenNow: now, ExpectError: ErrInvalidToken},
+		{Name: "Other video", GivenSigner: signer, GivenToken: token, GivenID: "bbbb-b56b-4c1d-9a6e-123456789abc", GivenNow: now, ExpectError: ErrInvalidToken},
+		{Name: "Expired token", GivenSigner: signer, GivenToken: token, GivenID: videoID, GivenNow: now.Add(2 * time.Hour), ExpectError: ErrExpiredToken},
+		{Name: "Other secret", GivenSigner: NewSigner("other", time.Hour, false, nil), GivenToken: token, GivenID: videoID, GivenNow: now, ExpectError: ErrInvalidToken},
+		{Name: "Tampered token", GivenSigner: signer, GivenToken: "x" + token, GivenID: videoID, GivenNow: now, ExpectError: ErrInvalidToken},
+		{Name: "Malformed token", GivenSigner: signer, GivenToken: "token", GivenID: videoID, GivenNow: now, ExpectError: ErrInvalidToken},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			viewer, err := tt.GivenSigner.Verify(tt.GivenToken, tt.GivenID, tt.GivenIP, tt.GivenNow)
+			require.ErrorIs(t, err, tt.ExpectError)
+			require.Equal(t, tt.ExpectedViewer, viewer)
+		})
+	}
+}
+
+func Test_ClientIP(t *testing.T) {
+	signer := NewSigner("secret", time.Hour, true, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("fd00::/8")})
+
+	cases := []struct {
+		Name              string
+		GivenSigner       *Signer
+		GivenRemoteAddr   string
+		GivenForwardedFor []string
+		ExpectedIP        string
+	}{
+		{Name: "Direct client", GivenSigner: signer, GivenRemoteAddr: "203.0.113.7:41000", ExpectedIP: "203.0.113.7"},
+		{Name: "Forwarded by an untrusted client", GivenSigner: signer, GivenRemoteAddr: "203.0.113.7:41000", GivenForwardedFor: []string{"198.51.100.1"}, ExpectedIP: "203.0.113.7"},
+		{Name: "Forwarded by a trusted proxy", GivenSigner: signer, GivenRemoteAddr: "10.0.0.2:41000", GivenForwardedFor: []string{"198.51.100.1"}, ExpectedIP: "198.51.100.1"},
+		{Name: "Forged address before the client", GivenSigner: signer, GivenRemoteAddr: "10.0.0.2:41000", GivenForwardedFor: []string{"192.0.2.9, 198.51.100.1"}, ExpectedIP: "198.51.100.1"},
+		{Name: "Chain of trusted proxies", GivenSigner: signer, GivenRemoteAddr: "[fd00::2]:41000", GivenForwardedFor: []string{"198.51.100.1, 10.0.0.3", "10.0.0.4"}, ExpectedIP: "198.51.100.1"},
+		{Name: "Only trusted proxies", GivenSigner: signer, GivenRemoteAddr: "10.0.0.2:41000", GivenForwardedFor: []string{"10.0.0.3"}, ExpectedIP: "10.0.0.3"},
+		{Name: "Without trusted proxies", GivenSigner: NewSigner("secret", time.Hour, true, nil), GivenRemoteAddr: "10.0.0.2:41000", GivenForwardedFor: []string{"198.51.100.1"}, ExpectedIP: "10.0.0.2"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.GivenRemoteAddr
+			for _, forwardedFor := range tt.GivenForwardedFor {
+				r.Header.Add("X-Forwarded-For", forwardedFor)
+			}
+			require.Equal(t, tt.ExpectedIP, tt.GivenSigner.ClientIP(r))
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package router
+
+import (
+	"github.com/rishirishhh/vought/src/cmd/api/cache"
+	"github.com/rishirishhh/vought/src/cmd/api/config"
+	"github.com/rishirishhh/vought/src/pkg/clients"
+)
+
+// Create the clients used by the API handlers
+func NewClients(cfg config.Config) (*Clients, error) {
+	s3Client, err := clients.NewS3Client(cfg.S3Host, cfg.S3Region, cfg.S3Bucket, cfg.S3AuthKey, cfg.S3AuthPwd)
+	if err != nil {
+		return nil, err
+	}
+
+	// amqpClient for new uploaded video (api->encoder), and the video status updates (api->front)
+	amqpClient, err := clients.NewAmqpClient(cfg.RabbitmqUser, cfg.RabbitmqPwd, cfg.RabbitmqAddr)
+	if err != nil {
+		return nil, err
+	}
+	amqpVideoStatusUpdate, err := clients.NewAmqpClient(cfg.RabbitmqUser, cfg.RabbitmqPwd, cfg.RabbitmqAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	// serviceDiscovery to retrieve the transformers
+	serviceDiscovery, err := clients.NewServiceDiscoveryFromConfig(discoveryConfig(cfg))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Clients{
+		S3Client:              s3Client,
+		AmqpClient:            amqpClient,
+		AmqpVideoStatusUpdate: amqpVideoStatusUpdate,
+		ServiceDiscovery:      serviceDiscovery,
+		UUIDGen:               clients.NewUuidGenerator(),
+		SegmentCache:          newSegmentCache(cfg, s3Client),
+	}, nil
+}
+
+func discoveryConfig(cfg config.Config) clients.DiscoveryConfig {
+	return clients.DiscoveryConfig{
+		Backend:         cfg.DiscoveryBackend,
+		ConsulURL:       cfg.ConsulHost,
+		File:            cfg.DiscoveryFile,
+		DNSDomain:       cfg.DiscoveryDNSDomain,
+		DNSServices:     cfg.DiscoveryDNSServices,
+		RefreshInterval: cfg.DiscoveryRefreshInterval,
+	}
+}
+
+// Transformed segments are not cached without memory for them nor S3 tier
+func newSegmentCache(cfg config.Config, s3Client clients.IS3Client) *cache.SegmentCache {
+	if cfg.TransformCacheSize <= 0 && !cfg.TransformCacheS3 {
+		return nil
+	}
+	if !cfg.TransformCacheS3 {
+		s3Client = nil
+	}
+	return cache.NewSegmentCache(cfg.TransformCacheSize, s3Client)
+}
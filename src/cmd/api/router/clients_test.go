@@ -0,0 +1,94 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rishirishhh/vought/src/cmd/api/config"
+	"github.com/rishirishhh/vought/src/pkg/clients"
+)
+
+type fakeS3Client struct {
+	clients.IS3Client
+	puts []string
+}
+
+func (f *fakeS3Client) GetObject(ctx context.Context, key string) (*clients.Object, error) {
+	return nil, errors.New("not found")
+}
+
+func (f *fakeS3Client) PutObjectInput(ctx context.Context, r io.Reader, path string) error {
+	f.puts = append(f.puts, path)
+	return nil
+}
+
+func Test_NewSegmentCache(t *testing.T) {
+	cases := []struct {
+		Name             string
+		GivenConfig      config.Config
+		ExpectCache      bool
+		ExpectTransforms int
+		ExpectS3Puts     int
+	}{
+		{Name: "In memory", GivenConfig: config.Config{TransformCacheSize: 1 << 20}, ExpectCache: true, ExpectTransforms: 1},
+		{Name: "In memory and on S3", GivenConfig: config.Config{TransformCacheSize: 1 << 20, TransformCacheS3: true}, ExpectCache: true, ExpectTransforms: 1, ExpectS3Puts: 1},
+		{Name: "On S3 only", GivenConfig: config.Config{TransformCacheS3: true}, ExpectCache: true, ExpectTransforms: 2, ExpectS3Puts: 2},
+		{Name: "Disabled", GivenConfig: config.Config{}, ExpectCache: false},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			s3Client := &fakeS3Client{}
+			segmentCache := newSegmentCache(tt.GivenConfig, s3Client)
+			require.Equal(t, tt.ExpectCache, segmentCache != nil)
+			if segmentCache == nil {
+				return
+			}
+
+			transforms := 0
+			transform := func(ctx context.Context, w io.Writer) (bool, error) {
+				transforms++
+				_, err := w.Write([]byte("segment"))
+				return false, err
+			}
+			for range 2 {
+				var segment bytes.Buffer
+				require.NoError(t, segmentCache.Get(context.Background(), "id/v0/segment0.ts", []string{"gray"}, &segment, transform))
+				require.Equal(t, "segment", segment.String())
+			}
+			require.Equal(t, tt.ExpectTransforms, transforms)
+			require.Len(t, s3Client.puts, tt.ExpectS3Puts)
+		})
+	}
+}
+
+func Test_DiscoveryConfig(t *testing.T) {
+	cases := []struct {
+		Name        string
+		GivenConfig config.Config
+		ExpectError bool
+	}{
+		{Name: "File", GivenConfig: config.Config{DiscoveryBackend: clients.DiscoveryBackendFile, DiscoveryFile: "transformers.json", DiscoveryRefreshInterval: time.Second}},
+		{Name: "DNS", GivenConfig: config.Config{DiscoveryBackend: clients.DiscoveryBackendDNS, DiscoveryDNSDomain: "vought.local", DiscoveryDNSServices: []string{"gray"}, DiscoveryRefreshInterval: time.Second}},
+		{Name: "File without path", GivenConfig: config.Config{DiscoveryBackend: clients.DiscoveryBackendFile, DiscoveryRefreshInterval: time.Second}, ExpectError: true},
+		{Name: "Unknown backend", GivenConfig: config.Config{DiscoveryBackend: "etcd"}, ExpectError: true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			serviceDiscovery, err := clients.NewServiceDiscoveryFromConfig(discoveryConfig(tt.GivenConfig))
+			if tt.ExpectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, serviceDiscovery)
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/goji/httpauth"
 
@@ -15,8 +16,11 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rishirishhh/vought/src/cmd/api/cache"
 	"github.com/rishirishhh/vought/src/cmd/api/config"
 	"github.com/rishirishhh/vought/src/cmd/api/metrics"
+	"github.com/rishirishhh/vought/src/cmd/api/playback"
+	log "github.com/sirupsen/logrus"
 
 	"github.com/rishirishhh/vought/src/cmd/api/controllers"
 	"github.com/rishirishhh/vought/src/cmd/api/db/dao"
@@ -29,6 +33,7 @@ type Clients struct {
 	AmqpVideoStatusUpdate clients.AmqpClient
 	ServiceDiscovery      clients.ServiceDiscovery
 	UUIDGen               clients.IUUIDGenerator
+	SegmentCache          *cache.SegmentCache
 }
 
 type DAOs struct {
@@ -51,15 +56,33 @@ func NewRouter(config config.Config, clients *Clients, DAOs *DAOs) http.Handler
 
 	r.PathPrefix("/health").Handler(controllers.HealthComponentHandler{}).Methods("GET")
 
+	var signer *playback.Signer
+	if config.PlaybackSecret != "" {
+		signer = playback.NewSigner(config.PlaybackSecret, config.PlaybackTokenTTL, config.PlaybackBindIP, config.PlaybackTrustedProxies)
+	}
+
+	// Native HLS players cannot attach an Authorization header to every request, streams
+	// can be played with a signed playback token instead of basic auth
+	streams := r.PathPrefix("/api/v1/videos/{id}/streams").Subrouter()
+	streams.Use(playbackAuthMiddleware(config, signer))
+
+	streams.PathPrefix("/master.m3u8").Handler(controllers.VideoGetMasterHandler{S3Client: clients.S3Client, UUIDGen: clients.UUIDGen, ServiceDiscovery: clients.ServiceDiscovery}).Methods("GET", "HEAD")
+	streams.PathPrefix("/{quality}/{filename}").Handler(controllers.VideoGetSubPartHandler{S3Client: clients.S3Client, UUIDGen: clients.UUIDGen, ServiceDiscovery: clients.ServiceDiscovery, SegmentCache: clients.SegmentCache, Fallback: config.TransformFallback, Timeout: config.TransformTimeout}).Methods("GET", "HEAD")
+
 	v1 := r.PathPrefix("/api/v1").Subrouter()
 	v1.Use(httpauth.SimpleBasicAuth(config.UserAuth, config.PwdAuth))
 
-	v1.PathPrefix("/videos/{id}/streams/master.m3u8").Handler(controllers.VideoGetMasterHandler{S3Client: clients.S3Client, UUIDGen: clients.UUIDGen}).Methods("GET")
-	v1.PathPrefix("/videos/{id}/streams/{quality}/{filename}").Handler(controllers.VideoGetSubPartHandler{S3Client: clients.S3Client, UUIDGen: clients.UUIDGen, ServiceDiscovery: clients.ServiceDiscovery}).Methods("GET")
+	v1.PathPrefix("/videos/{id}/playback").Handler(controllers.VideoPlaybackTokenHandler{Signer: signer, UUIDGen: clients.UUIDGen}).Methods("GET")
 	v1.PathPrefix("/videos/transformer/list").Handler(controllers.VideoTransformerListHandler{ServiceDiscovery: clients.ServiceDiscovery}).Methods("GET")
+	v1.PathPrefix("/videos/{id}/storyboard.vtt").Handler(controllers.VideoGetStoryboardHandler{S3Client: clients.S3Client, UUIDGen: clients.UUIDGen}).Methods("GET")
+	v1.PathPrefix("/videos/{id}/storyboard/{filename}").Handler(controllers.VideoGetStoryboardImageHandler{S3Client: clients.S3Client, UUIDGen: clients.UUIDGen}).Methods("GET")
+	v1.PathPrefix("/videos/{id}/renditions").Handler(controllers.VideoGetRenditionsHandler{S3Client: clients.S3Client, UUIDGen: clients.UUIDGen}).Methods("GET")
+	v1.PathPrefix("/videos/{id}/previews/{filename}").Handler(controllers.VideoGetPreviewHandler{S3Client: clients.S3Client, UUIDGen: clients.UUIDGen}).Methods("GET", "HEAD")
+	v1.PathPrefix("/videos/{id}/frame").Handler(controllers.VideoGetFrameHandler{S3Client: clients.S3Client, UUIDGen: clients.UUIDGen}).Methods("GET")
+	v1.PathPrefix("/videos/{id}/subtitles/{track}").Handler(controllers.VideoSubtitlesHandler{S3Client: clients.S3Client, VideosDAO: &DAOs.VideosDAO, UUIDGen: clients.UUIDGen, SegmentCache: clients.SegmentCache}).Methods("PUT")
 	v1.PathPrefix("/videos/{id}/cover").Handler(controllers.VideoCoverHandler{S3Client: clients.S3Client, VideosDAO: &DAOs.VideosDAO, UUIDGen: clients.UUIDGen}).Methods("GET")
 	v1.PathPrefix("/videos/list/{attribute}/{order}/{page}/{limit}/{status}").Handler(controllers.VideosListHandler{VideosDAO: &DAOs.VideosDAO}).Methods("GET")
-	v1.PathPrefix("/videos/{id}/delete").Handler(controllers.VideoDeleteHandler{S3Client: clients.S3Client, VideosDAO: &DAOs.VideosDAO, UploadsDAO: &DAOs.UploadsDAO, UUIDGen: clients.UUIDGen}).Methods("DELETE")
+	v1.PathPrefix("/videos/{id}/delete").Handler(controllers.VideoDeleteHandler{S3Client: clients.S3Client, VideosDAO: &DAOs.VideosDAO, UploadsDAO: &DAOs.UploadsDAO, UUIDGen: clients.UUIDGen, SegmentCache: clients.SegmentCache}).Methods("DELETE")
 	v1.PathPrefix("/videos/{id}/archive").Handler(controllers.VideoArchiveHandler{VideosDAO: &DAOs.VideosDAO, UUIDGen: clients.UUIDGen}).Methods("PUT")
 	v1.PathPrefix("/videos/{id}/info").Handler(controllers.VideoGetInfoHandler{VideosDAO: &DAOs.VideosDAO, UUIDGen: clients.UUIDGen}).Methods("GET")
 	v1.PathPrefix("/videos/upload").Handler(controllers.VideoUploadHandler{S3Client: clients.S3Client, AmqpClient: clients.AmqpClient, AmqpVideoStatusUpdate: clients.AmqpVideoStatusUpdate, VideosDAO: &DAOs.VideosDAO, UploadsDAO: &DAOs.UploadsDAO, UUIDGen: clients.UUIDGen}).Methods("POST")
@@ -68,13 +91,14 @@ func NewRouter(config config.Config, clients *Clients, DAOs *DAOs) http.Handler
 	return handlers.CORS(getCORS())(r)
 }
 
-func getCORS() (handlers.CORSOption, handlers.CORSOption, handlers.CORSOption, handlers.CORSOption) {
+func getCORS() (handlers.CORSOption, handlers.CORSOption, handlers.CORSOption, handlers.CORSOption, handlers.CORSOption) {
 	corsObj := handlers.AllowedOrigins([]string{"*"})
-	methods := handlers.AllowedMethods([]string{"GET", "POST", "PUT", "OPTIONS", "DELETE"})
-	headers := handlers.AllowedHeaders([]string{"Authorization"})
+	methods := handlers.AllowedMethods([]string{"GET", "HEAD", "POST", "PUT", "OPTIONS", "DELETE"})
+	headers := handlers.AllowedHeaders([]string{"Authorization", "Range", "If-None-Match", "If-Modified-Since", "If-Range"})
+	exposedHeaders := handlers.ExposedHeaders([]string{"Accept-Ranges", "Content-Length", "Content-Range", "ETag"})
 	credentials := handlers.AllowCredentials()
 
-	return corsObj, methods, headers, credentials
+	return corsObj, methods, headers, exposedHeaders, credentials
 }
 
 func NewResponseWriter(w http.ResponseWriter) *responseWriter {
@@ -94,6 +118,42 @@ func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	return h.Hijack()
 }
 
+// Transformed segments are flushed to the client while they are being transformed
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func playbackAuthMiddleware(config config.Config, signer *playback.Signer) mux.MiddlewareFunc {
+	basicAuth := httpauth.SimpleBasicAuth(config.UserAuth, config.PwdAuth)
+
+	return func(next http.Handler) http.Handler {
+		nextWithBasicAuth := basicAuth(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The principal is forwarded to the transformers personalising the video
+			token := r.URL.Query().Get("token")
+			if token == "" || signer == nil {
+				user, _, _ := r.BasicAuth()
+				ctx := playback.WithPrincipal(r.Context(), playback.Principal{ViewerID: user})
+				nextWithBasicAuth.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			viewerID, err := signer.Verify(token, mux.Vars(r)["id"], signer.ClientIP(r), time.Now())
+			if err != nil {
+				log.Error("Invalid playback token : ", err)
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			ctx := playback.WithPrincipal(r.Context(), playback.Principal{ViewerID: viewerID, SessionID: playback.SessionID(token), VideoID: mux.Vars(r)["id"]})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
 func promotheusMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		route := mux.CurrentRoute(r)
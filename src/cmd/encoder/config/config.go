@@ -16,6 +16,16 @@ type Config struct {
 	RabbitmqAddr string `env:"RABBITMQ_ADDR,required"`
 	RabbitmqUser string `env:"RABBITMQ_USER,required"`
 	RabbitmqPwd  string `env:"RABBITMQ_PWD,required"`
+
+	// Seconds between two thumbnails of the seek previews storyboard
+	StoryboardInterval uint32 `env:"STORYBOARD_INTERVAL" envDefault:"5"`
+
+	// Seconds the duration of an encoded variant may differ from the duration of its source
+	DurationTolerance float64 `env:"DURATION_TOLERANCE" envDefault:"1"`
+
+	// Port of the HTTP server exposing the metrics, apart from the ports of the other services and
+	// of node_exporter (9100) so that they can run on the same host
+	MetricsPort uint32 `env:"METRICS_PORT" envDefault:"9102"`
 }
 
 func NewConfig() (Config, error) {
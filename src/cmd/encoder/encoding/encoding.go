@@ -6,15 +6,20 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/rishirishhh/vought/src/cmd/encoder/config"
+	"github.com/rishirishhh/vought/src/cmd/encoder/metrics"
 	"github.com/rishirishhh/vought/src/pkg/clients"
 	contracts "github.com/rishirishhh/vought/src/pkg/contracts/v1"
 	"github.com/rishirishhh/vought/src/pkg/ffmpeg"
 )
 
-func Process(s3Client clients.IS3Client, videoData *contracts.Video) error {
+// Process encodes the uploaded video. The duration of each stage (download, probe, encode, upload
+// and validate) is recorded, failures are counted with the stage they happened in.
+func Process(cfg config.Config, s3Client clients.IS3Client, videoData *contracts.Video) error {
 	// Going to the working directory
 	processingFolder := filepath.Join(os.TempDir(), "/encoder-processing-dir")
 	if err := os.MkdirAll(processingFolder, os.ModePerm); err != nil {
@@ -31,52 +36,141 @@ func Process(s3Client clients.IS3Client, videoData *contracts.Video) error {
 		_ = os.RemoveAll(processingFolder)
 	}()
 
-	// Download and write the source file on the filesystem
-	err := fetchVideoSource(s3Client, videoData)
+	// Download and write the source file and the cover file on the filesystem
+	isCoverFetch := false
+	err := runStage("download", func() error {
+		if err := fetchVideoSource(s3Client, videoData); err != nil {
+			log.Error("Failed to fetch video source")
+			return err
+		}
+
+		var err error
+		isCoverFetch, err = fetchCoverSource(s3Client, videoData)
+		if err != nil {
+			log.Error("Failed to fetch cover image")
+		}
+		return err
+	})
 	if err != nil {
-		log.Error("Failed to fetch video source")
 		return err
 	}
 
-	// Video Processing
 	// Some video doesn't contains audio and HLS can't handle it, so we add an empty track
-	err = encode(videoData)
-	if err != nil {
-		log.Error("Failed to encode video")
+	withSound := false
+	duration := 0.0
+	err = runStage("probe", func() error {
+		sourceFile := filepath.Base(videoData.GetSource())
+		var err error
+		if withSound, err = ffmpeg.CheckContainsSound(sourceFile); err != nil {
+			log.Error("Failed to probe video")
+			return err
+		}
+		if duration, err = ffmpeg.ExtractDuration(sourceFile); err != nil {
+			log.Error("Failed to probe video")
+		}
 		return err
-	}
-
-	// Download and write the cover file on the filesystem
-	isCoverFetch, err := fetchCoverSource(s3Client, videoData)
+	})
 	if err != nil {
-		log.Error("Failed to fetch cover image")
 		return err
 	}
 
-	// Cover image compression
-	if isCoverFetch {
-		if err = compressCover(videoData); err != nil {
-			log.Error("Failed to compress cover image")
+	isCoverExtracted := false
+	err = runStage("encode", func() error {
+		// Video Processing
+		if err := encode(videoData, withSound); err != nil {
+			log.Error("Failed to encode video")
+			return err
+		}
+
+		// Thumbnails sprite sheets and WebVTT storyboard for seek previews
+		if err := generateStoryboard(videoData, duration, cfg.StoryboardInterval); err != nil {
+			log.Error("Failed to generate storyboard")
+			return err
+		}
+
+		// Short silent looping previews for hover previews
+		previews, err := ffmpeg.GeneratePreviews(filepath.Base(videoData.GetSource()), duration)
+		if err != nil {
+			log.Error("Failed to generate previews")
 			return err
 		}
+
+		// Cover image compression
+		if isCoverFetch {
+			if err = compressCover(videoData); err != nil {
+				log.Error("Failed to compress cover image")
+				return err
+			}
+		} else {
+			// No cover supplied, pick a representative frame of the video instead
+			if err = extractCover(videoData, duration); err != nil {
+				log.Warn("Failed to extract cover from video ", videoData.GetId(), " : ", err)
+			} else {
+				isCoverExtracted = true
+			}
+		}
+
+		// List every rendition in the video metadata
+		if err = writeRenditions(previews); err != nil {
+			log.Error("Failed to write renditions metadata")
+		}
+		return err
+	})
+	if err != nil {
+		return err
 	}
 
 	log.Info("Processing of video ", videoData.GetId(), "done - Uploading to S3")
 	// Uploading files to the S3
-	err = uploadFiles(s3Client, videoData)
+	err = runStage("upload", func() error {
+		err := uploadFiles(s3Client, videoData)
+		if err != nil {
+			log.Error("Failed to upload video data to S3")
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Check what has actually been uploaded before the video is marked as complete
+	err = runStage("validate", func() error {
+		err := validateEncoding(cfg, s3Client, videoData, duration)
+		if err != nil {
+			log.Error("Encoded video ", videoData.GetId(), " is invalid")
+		}
+		return err
+	})
 	if err != nil {
-		log.Error("Failed to upload video data to S3")
+		// The outputs have been uploaded but will not be served, they are not left behind
+		removeUploadedFiles(s3Client, videoData)
 		return err
 	}
 
+	if isCoverExtracted {
+		videoData.CoverPath = filepath.Join(videoData.GetId(), "cover.jpeg")
+	}
+
 	return nil
 }
 
+// Run a stage of the processing, recording its duration, and its failure along with the stage
+func runStage(stage string, run func() error) error {
+	start := time.Now()
+	err := run()
+	metrics.StageDuration.WithLabelValues(stage).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.CounterJobFail.WithLabelValues(stage).Inc()
+	}
+	return err
+}
+
 func fetchVideoSource(s3Client clients.IS3Client, videoData *contracts.Video) error {
 	source, err := s3Client.GetObject(context.Background(), videoData.GetSource())
 	if err != nil {
 		return err
 	}
+	defer source.Close()
 	f, err := os.Create(filepath.Base(videoData.GetSource()))
 	if err != nil {
 		return err
@@ -89,13 +183,9 @@ func fetchVideoSource(s3Client clients.IS3Client, videoData *contracts.Video) er
 	return f.Close()
 }
 
-func encode(data *contracts.Video) error {
+func encode(data *contracts.Video, withSound bool) error {
 	sourceFile := filepath.Base(data.GetSource())
 
-	withSound, err := ffmpeg.CheckContainsSound(sourceFile)
-	if err != nil {
-		return err
-	}
 	if !withSound {
 		if err := ffmpeg.AddEmptyAudioTrack(sourceFile); err != nil {
 			return err
@@ -114,6 +204,29 @@ func encode(data *contracts.Video) error {
 	return nil
 }
 
+func generateStoryboard(data *contracts.Video, duration float64, interval uint32) error {
+	sourceFile := filepath.Base(data.GetSource())
+
+	res, err := ffmpeg.ExtractResolution(sourceFile)
+	if err != nil {
+		return err
+	}
+
+	return ffmpeg.GenerateStoryboard(sourceFile, res, duration, interval)
+}
+
+func validateEncoding(cfg config.Config, s3Client clients.IS3Client, data *contracts.Video, sourceDuration float64) error {
+	variants, err := encodedVariants()
+	if err != nil {
+		return err
+	}
+	if len(variants) == 0 {
+		return invalidOutput("no variant has been encoded")
+	}
+
+	return validateOutput(s3Client, data.GetId(), variants, sourceDuration, cfg.DurationTolerance)
+}
+
 func fetchCoverSource(s3Client clients.IS3Client, videoData *contracts.Video) (isFileFetch bool, err error) {
 	// Do not fetch cover if cover path is empty
 	if len(videoData.GetCoverPath()) == 0 {
@@ -124,6 +237,7 @@ func fetchCoverSource(s3Client clients.IS3Client, videoData *contracts.Video) (i
 	if err != nil {
 		return false, err
 	}
+	defer source.Close()
 	f, err := os.Create(filepath.Base(videoData.GetCoverPath()))
 	if err != nil {
 		return false, err
@@ -144,13 +258,23 @@ func compressCover(videoData *contracts.Video) error {
 	return nil
 }
 
+func extractCover(videoData *contracts.Video, duration float64) error {
+	sourceFile := filepath.Base(videoData.GetSource())
+
+	if err := ffmpeg.ExtractPosterFrame(sourceFile, "poster.png", duration); err != nil {
+		return err
+	}
+
+	return ffmpeg.ConvertImg("poster.png", "cover.jpeg")
+}
+
 func uploadFiles(s3Client clients.IS3Client, data *contracts.Video) error {
 	err := filepath.Walk(".",
 		func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
 			}
-			if path == "." || (!strings.HasSuffix(path, ".ts") && !strings.HasSuffix(path, ".m3u8") && !strings.HasSuffix(path, ".jpeg")) {
+			if path == "." || path == filepath.Base(data.GetSource()) || !isOutputFile(path) {
 				log.Debug("Skipping ", path)
 				return nil
 			}
@@ -165,9 +289,11 @@ func uploadFiles(s3Client clients.IS3Client, data *contracts.Video) error {
 		return err
 	}
 
-	// Remove cover image on S3 if needed
-	if _, err = os.Stat("cover.jpeg"); err == nil {
-		err = s3Client.RemoveObject(context.Background(), data.GetCoverPath())
+	// Remove cover image on S3 if needed. Without source cover (poster frame extracted) or when the
+	// source cover has already been overwritten by the compressed one, there is nothing to remove.
+	coverPath := data.GetCoverPath()
+	if _, err = os.Stat("cover.jpeg"); err == nil && len(coverPath) > 0 && coverPath != filepath.Join(data.GetId(), "cover.jpeg") {
+		err = s3Client.RemoveObject(context.Background(), coverPath)
 		if err != nil {
 			return err
 		}
@@ -175,3 +301,36 @@ func uploadFiles(s3Client clients.IS3Client, data *contracts.Video) error {
 
 	return nil
 }
+
+// Remove from S3 the outputs uploaded by uploadFiles. The source is kept so that the video can be
+// encoded again, and so is the cover, the source one may have been replaced by it.
+func removeUploadedFiles(s3Client clients.IS3Client, data *contracts.Video) {
+	entries, err := os.ReadDir(".")
+	if err != nil {
+		log.Error("Cannot list outputs of video ", data.GetId(), " : ", err)
+		return
+	}
+
+	for _, entry := range entries {
+		key := filepath.Join(data.GetId(), entry.Name())
+		switch {
+		case entry.IsDir():
+			// Renditions, every file of them is an output
+			key += "/"
+		case entry.Name() == filepath.Base(data.GetSource()) || entry.Name() == "cover.jpeg" || !isOutputFile(entry.Name()):
+			continue
+		}
+		if err := s3Client.RemoveObject(context.Background(), key); err != nil {
+			log.Error("Cannot remove output ", key, " : ", err)
+		}
+	}
+}
+
+func isOutputFile(path string) bool {
+	for _, ext := range []string{".ts", ".m3u8", ".jpeg", ".vtt", ".mp4", ".webp", ".json"} {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package encoding
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/rishirishhh/vought/src/pkg/ffmpeg"
+)
+
+const renditionsFile string = "renditions.json"
+
+// Renditions lists every output of the encoder, as paths relative to the video prefix on S3
+type Renditions struct {
+	Master     string   `json:"master"`
+	Variants   []string `json:"variants"`
+	Storyboard string   `json:"storyboard,omitempty"`
+	Previews   []string `json:"previews,omitempty"`
+	Cover      string   `json:"cover,omitempty"`
+}
+
+// List the variant playlists written by the encoder in the working directory
+func encodedVariants() ([]string, error) {
+	variants, err := filepath.Glob(filepath.Join("v*", "segment_index.m3u8"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(variants)
+	return variants, nil
+}
+
+func writeRenditions(previews []string) error {
+	variants, err := encodedVariants()
+	if err != nil {
+		return err
+	}
+
+	renditions := Renditions{
+		Master:   "master.m3u8",
+		Variants: variants,
+		Previews: previews,
+	}
+	if _, err := os.Stat(ffmpeg.StoryboardVTT); err == nil {
+		renditions.Storyboard = ffmpeg.StoryboardVTT
+	}
+	if _, err := os.Stat("cover.jpeg"); err == nil {
+		renditions.Cover = "cover.jpeg"
+	}
+
+	payload, err := json.Marshal(renditions)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(renditionsFile, payload, 0644) //nolint:gosec
+}
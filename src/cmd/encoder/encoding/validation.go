@@ -0,0 +1,112 @@
+package encoding
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/rishirishhh/vought/src/pkg/clients"
+	"github.com/rishirishhh/vought/src/pkg/hls"
+)
+
+// ValidationError reports encoded files on S3 which are missing, empty or inconsistent with the source
+type ValidationError struct {
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid encoding output : " + e.Reason
+}
+
+func invalidOutput(format string, args ...any) error {
+	return &ValidationError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// Check the uploaded HLS stream of a video: the master playlist must list every variant encoded
+// locally, every segment of every variant must exist on S3 and not be empty, and the duration of
+// every variant must match the duration of the source, within the given tolerance (in seconds).
+func validateOutput(s3Client clients.IS3Client, videoID string, variants []string, sourceDuration float64, tolerance float64) error {
+	ctx := context.Background()
+
+	masterPath := path.Join(videoID, "master.m3u8")
+	object, err := s3Client.GetObject(ctx, masterPath)
+	if err != nil {
+		return invalidOutput("cannot get %v : %v", masterPath, err)
+	}
+	defer object.Close()
+
+	master, err := hls.DecodeMaster(object)
+	if err != nil {
+		return invalidOutput("cannot parse %v : %v", masterPath, err)
+	}
+	if len(master.Variants) == 0 {
+		return invalidOutput("%v has no variant", masterPath)
+	}
+
+	listed := map[string]bool{}
+	for _, variant := range master.Variants {
+		listed[path.Clean(variant.URI)] = true
+	}
+	for _, variant := range variants {
+		if !listed[filepath.ToSlash(variant)] {
+			return invalidOutput("variant %v is missing from %v", variant, masterPath)
+		}
+	}
+
+	for _, variant := range master.Variants {
+		if err := validateVariant(ctx, s3Client, path.Join(videoID, variant.URI), sourceDuration, tolerance); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateVariant(ctx context.Context, s3Client clients.IS3Client, variantPath string, sourceDuration float64, tolerance float64) error {
+	object, err := s3Client.GetObject(ctx, variantPath)
+	if err != nil {
+		return invalidOutput("cannot get variant %v : %v", variantPath, err)
+	}
+	defer object.Close()
+
+	media, err := hls.DecodeMedia(object)
+	if err != nil {
+		return invalidOutput("cannot parse variant %v : %v", variantPath, err)
+	}
+	if len(media.Segments) == 0 {
+		return invalidOutput("variant %v has no segment", variantPath)
+	}
+	if !media.EndList {
+		return invalidOutput("variant %v is not terminated by #EXT-X-ENDLIST", variantPath)
+	}
+
+	// Byte range segments share their resource, which is checked once
+	checked := map[string]bool{}
+	for _, segment := range media.Segments {
+		segmentPath := path.Join(path.Dir(variantPath), segment.URI)
+		if checked[segmentPath] {
+			continue
+		}
+		checked[segmentPath] = true
+
+		head, err := s3Client.HeadObject(ctx, segmentPath)
+		if err != nil {
+			return invalidOutput("segment %v of variant %v is missing : %v", segment.URI, variantPath, err)
+		}
+		if head.ContentLength <= 0 {
+			return invalidOutput("segment %v of variant %v is empty", segment.URI, variantPath)
+		}
+	}
+
+	duration := media.Duration()
+	if math.Abs(duration-sourceDuration) > tolerance {
+		return invalidOutput("variant %v lasts %.3fs while the source lasts %.3fs", variantPath, duration, sourceDuration)
+	}
+
+	log.Debug("Variant ", variantPath, " is valid : ", len(media.Segments), " segments, ", duration, "s")
+	return nil
+}
@@ -2,8 +2,11 @@ package eventhandler
 
 import (
 	"path/filepath"
+	"time"
 
+	"github.com/rishirishhh/vought/src/cmd/encoder/config"
 	"github.com/rishirishhh/vought/src/cmd/encoder/encoding"
+	"github.com/rishirishhh/vought/src/cmd/encoder/metrics"
 	"github.com/rishirishhh/vought/src/pkg/clients"
 	"github.com/rishirishhh/vought/src/pkg/events"
 	"google.golang.org/protobuf/proto"
@@ -13,7 +16,7 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-func ConsumeEvents(amqpClientVideoUpload clients.AmqpClient, s3Client clients.IS3Client) {
+func ConsumeEvents(cfg config.Config, amqpClientVideoUpload clients.AmqpClient, s3Client clients.IS3Client) {
 	session := amqpClientVideoUpload.WithRedial()
 	for {
 		client := <-session
@@ -25,9 +28,15 @@ func ConsumeEvents(amqpClientVideoUpload clients.AmqpClient, s3Client clients.IS
 		}
 
 		for msg := range msgs {
+			start := time.Now()
+			if publishedAt, ok := clients.PublishedAt(msg); ok {
+				metrics.QueueLag.Observe(start.Sub(publishedAt).Seconds())
+			}
+
 			video := &contracts.Video{}
 			if err := proto.Unmarshal([]byte(msg.Body), video); err != nil {
 				log.Error("Fail to unmarshal video event : ", err)
+				metrics.CounterJobFail.WithLabelValues("invalid_event").Inc()
 				continue
 			}
 
@@ -40,27 +49,31 @@ func ConsumeEvents(amqpClientVideoUpload clients.AmqpClient, s3Client clients.IS
 			log.Debug("New message received: ", video)
 			log.Info("Starting encoding of video with ID ", video.Id)
 
-			if err := encoding.Process(s3Client, video); err != nil {
-				log.Error("Failed to processing video ", video.Id, " - ", err)
+			if processErr := encoding.Process(cfg, s3Client, video); processErr != nil {
+				log.Error("Failed to processing video ", video.Id, " - ", processErr)
 
-				if err = msg.Acknowledger.Nack(msg.DeliveryTag, false, false); err != nil {
+				if err := msg.Acknowledger.Nack(msg.DeliveryTag, false, false); err != nil {
 					log.Error("Failed to Nack message ", video.Id, " - ", err)
 				}
 
 				// Send video status updated : FAIL_ENCODE
 				videoEncoded.Status = contracts.Video_VIDEO_STATUS_FAIL_ENCODE
-				if err = sendUpdatedVideoStatus(videoEncoded, client); err != nil {
+				videoEncoded.FailureReason = processErr.Error()
+				if err := sendUpdatedVideoStatus(videoEncoded, client); err != nil {
 					log.Error("Error while sending new video status : ", err)
 				}
+				metrics.JobDuration.Observe(time.Since(start).Seconds())
 
 				continue
 			}
 
 			if err := msg.Acknowledger.Ack(msg.DeliveryTag, false); err != nil {
 				log.Error("Failed to Ack message ", video.Id, " - ", err)
+				metrics.CounterJobFail.WithLabelValues("ack").Inc()
 
 				// Send video status updated : FAIL_ENCODE
 				videoEncoded.Status = contracts.Video_VIDEO_STATUS_FAIL_ENCODE
+				videoEncoded.FailureReason = "cannot acknowledge the upload event : " + err.Error()
 				if err = sendUpdatedVideoStatus(videoEncoded, client); err != nil {
 					log.Error("Error while sending new video status : ", err)
 				}
@@ -71,7 +84,8 @@ func ConsumeEvents(amqpClientVideoUpload clients.AmqpClient, s3Client clients.IS
 			// Send updates
 			// Update video status to COMPLETE
 			videoEncoded.Status = contracts.Video_VIDEO_STATUS_COMPLETE
-			// Update video cover path
+			// Update video cover path, a poster frame may have been picked by the encoder
+			videoEncoded.CoverPath = video.CoverPath
 			if len(videoEncoded.CoverPath) > 0 && filepath.Ext(videoEncoded.CoverPath) != ".jpeg" {
 				videoEncoded.CoverPath = videoEncoded.Id + "/cover.jpeg"
 			}
@@ -79,6 +93,8 @@ func ConsumeEvents(amqpClientVideoUpload clients.AmqpClient, s3Client clients.IS
 				log.Error("Error while sending new video status : ", err)
 				continue
 			}
+			metrics.CounterJobSuccess.Inc()
+			metrics.JobDuration.Observe(time.Since(start).Seconds())
 		}
 		// We close the client to let another take his place.
 		client.Close()
@@ -1,7 +1,11 @@
 package main
 
 import (
-	"github.com/rishirishhh/vought/src/cmd/api/config"
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rishirishhh/vought/src/cmd/encoder/config"
 	"github.com/rishirishhh/vought/src/cmd/encoder/eventhandler"
 	"github.com/rishirishhh/vought/src/pkg/clients"
 	log "github.com/sirupsen/logrus"
@@ -26,7 +30,16 @@ func main() {
 	}
 	amqpClientVideoUpload, _ := clients.NewAmqpClient(cfg.RabbitmqUser, cfg.RabbitmqPwd, cfg.RabbitmqAddr)
 
+	// Expose the metrics
+	go func() {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		if err := http.ListenAndServe(fmt.Sprintf(":%v", cfg.MetricsPort), mux); err != nil {
+			log.Error("Cannot serve metrics : ", err)
+		}
+	}()
+
 	// Listen, consume and publish on amqpClientVideoUpload
-	eventhandler.ConsumeEvents(amqpClientVideoUpload, s3Client)
+	eventhandler.ConsumeEvents(cfg, amqpClientVideoUpload, s3Client)
 
 }
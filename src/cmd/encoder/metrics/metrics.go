@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var StageDuration = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "encoder_stage_duration_seconds",
+		Help:    "Duration of the stages of the encoding jobs: download, probe, encode, upload and validate",
+		Buckets: prometheus.ExponentialBuckets(0.5, 2, 12),
+	},
+	[]string{"stage"},
+)
+
+var JobDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+	Name:    "encoder_job_duration_seconds",
+	Help:    "Duration of the encoding jobs, from the reception of the upload event to the publication of the new status",
+	Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+})
+
+var QueueLag = promauto.NewHistogram(prometheus.HistogramOpts{
+	Name:    "encoder_queue_lag_seconds",
+	Help:    "Time the upload events waited in the queue before being received",
+	Buckets: prometheus.ExponentialBuckets(0.1, 2, 14),
+})
+
+var (
+	CounterJobSuccess = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "encoder_job_success",
+		Help: "The total number of videos encoded successfully",
+	})
+)
+
+var CounterJobFail = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "encoder_job_fail",
+		Help: "The total number of encoding jobs failed, by reason: the stage which failed, invalid_event or ack",
+	},
+	[]string{"reason"},
+)
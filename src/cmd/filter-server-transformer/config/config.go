@@ -0,0 +1,51 @@
+package config
+
+import (
+	"time"
+
+	"github.com/caarlos0/env/v6"
+)
+
+type Config struct {
+	Port      uint32 `env:"PORT" envDefault:"50051"`
+	LocalAddr string `env:"LOCAL_ADDR" envDefault:""`
+	DevMode   bool   `env:"DEV_MODE" envDefault:"false"`
+
+	// Filters of the catalogue served by the instance, all of them if empty
+	Filters []string `env:"FILTERS" envSeparator:","`
+
+	// Maximum duration of a transformation, including the steps run by the next transformers.
+	// Sooner deadlines of the clients are kept.
+	TransformTimeout time.Duration `env:"TRANSFORM_TIMEOUT" envDefault:"30s"`
+
+	// Transformations run at once, without limit if zero, and transformations waiting for one of
+	// them to end. The next ones are rejected.
+	Workers     int `env:"WORKERS" envDefault:"4"`
+	WorkerQueue int `env:"WORKER_QUEUE" envDefault:"16"`
+
+	// Port of the HTTP server exposing the metrics, apart from the ports of the other services and
+	// of node_exporter (9100) so that they can run on the same host
+	MetricsPort uint32 `env:"METRICS_PORT" envDefault:"9101"`
+
+	S3Host    string `env:"S3_HOST" envDefault:""`
+	S3AuthKey string `env:"S3_AUTH_KEY,required"`
+	S3AuthPwd string `env:"S3_AUTH_PWD,required"`
+	S3Bucket  string `env:"S3_BUCKET" envDefault:"voogle-video"`
+	S3Region  string `env:"S3_REGION" envDefault:"eu-west-3"`
+
+	// Service discovery backend : consul, file or dns
+	DiscoveryBackend         string        `env:"DISCOVERY_BACKEND" envDefault:"consul"`
+	ConsulHost               string        `env:"CONSUL_URL" envDefault:""`
+	DiscoveryFile            string        `env:"DISCOVERY_FILE" envDefault:""`
+	DiscoveryDNSDomain       string        `env:"DISCOVERY_DNS_DOMAIN" envDefault:""`
+	DiscoveryDNSServices     []string      `env:"DISCOVERY_DNS_SERVICES" envSeparator:","`
+	DiscoveryRefreshInterval time.Duration `env:"DISCOVERY_REFRESH_INTERVAL" envDefault:"5s"`
+}
+
+func NewConfig() (Config, error) {
+	config := Config{}
+
+	err := env.Parse(&config)
+
+	return config, err
+}
@@ -2,35 +2,24 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 
-	"github.com/rishirishhh/vought/src/cmd/gray-server-transformer/config"
+	"github.com/rishirishhh/vought/src/cmd/filter-server-transformer/config"
 	"github.com/rishirishhh/vought/src/pkg/clients"
 	transformer_factory "github.com/rishirishhh/vought/src/pkg/transformer/transformer_factory"
-	"github.com/rishirishhh/vought/src/pkg/transformer/v1"
 )
 
 const GOROUTINE_FLUSH_TIMEOUT time.Duration = time.Millisecond * 100
 
-var _ transformer.TransformerServiceServer = &grayServer{}
-
-type grayServer struct {
-	transformer.UnimplementedTransformerServiceServer
-	transformer transformer_factory.ITransformerServer
-}
-
-func (r *grayServer) TransformVideo(args *transformer.TransformVideoRequest, stream transformer.TransformerService_TransformVideoServer) error {
-	log.Debug("Beginning Transformation")
-	ctx := context.Background()
-	return r.transformer.TransformVideo(ctx, args, stream)
-}
-
 func main() {
-	log.Info("Starting Voogle gray transformer")
+	log.Info("Starting Vought filter transformer")
 
 	cfg, err := config.NewConfig()
 	if err != nil {
@@ -47,35 +36,56 @@ func main() {
 	}
 
 	// serviceDiscovery to retrieve transformer address
-	discoveryClient, err := clients.NewServiceDiscovery(cfg.ConsulHost)
+	discoveryClient, err := clients.NewServiceDiscoveryFromConfig(clients.DiscoveryConfig{
+		Backend:         cfg.DiscoveryBackend,
+		ConsulURL:       cfg.ConsulHost,
+		File:            cfg.DiscoveryFile,
+		DNSDomain:       cfg.DiscoveryDNSDomain,
+		DNSServices:     cfg.DiscoveryDNSServices,
+		RefreshInterval: cfg.DiscoveryRefreshInterval,
+	})
 	if err != nil {
 		log.Fatal("Fail to create Service Discovery : ", err)
 	}
 
+	transformer, err := transformer_factory.GetTransformer(cfg.Filters, s3Client, discoveryClient, transformer_factory.Limits{
+		Timeout: cfg.TransformTimeout,
+		Workers: cfg.Workers,
+		Queue:   cfg.WorkerQueue,
+	})
+	if err != nil {
+		log.Fatal("Cannot create transformer : ", err)
+	}
+
 	// Start service discovery
 	go func() {
 		serviceInfos := clients.ServiceInfos{
-			Name:    "gray-server-transformer",
+			Name:    "filter-server-transformer",
 			Address: cfg.LocalAddr,
 			Port:    int(cfg.Port),
 			Tags:    []string{"transformer"},
+			// Clients find the filters and the real port from their metadata
+			Transformers: transformer.Infos(),
 		}
 		if err := discoveryClient.StartServiceDiscovery(serviceInfos); err != nil {
 			log.Fatal("Discovery Service crash : ", err)
 		}
 	}()
 
-	transformer, err := transformer_factory.GetTransformer("Gray", s3Client, discoveryClient)
-	if err != nil {
-		log.Error("Cannot create transformer : ", err)
-	}
+	// Expose the metrics
+	go func() {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		if err := http.ListenAndServe(fmt.Sprintf(":%v", cfg.MetricsPort), mux); err != nil {
+			log.Error("Cannot serve metrics : ", err)
+		}
+	}()
 
 	// Launch grpc Server
 	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
-		grayServer := &grayServer{transformer: transformer}
-		if err := transformer.StartRPCServer(ctx, grayServer, cfg.Port); err != nil {
-			log.Fatal("Gray RPC server error : ", err)
+		if err := transformer.StartRPCServer(ctx, cfg.Port); err != nil {
+			log.Fatal("Filter RPC server error : ", err)
 		}
 	}()
 
@@ -5,11 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 	log "github.com/sirupsen/logrus"
 )
 
+// Header holding the time a message has been published at, in milliseconds since the epoch. The
+// Timestamp property only has a resolution of one second.
+const HeaderPublishedAt = "x-published-at-ms"
+
 type AmqpClient interface {
 	WithRedial() chan AmqpClient
 	WithExchanger(exchangerName string) error
@@ -110,10 +115,7 @@ func (r *amqpClient) Publish(routingKey string, message []byte) error {
 		routingKey,
 		false,
 		false,
-		amqp.Publishing{
-			ContentType: "text/plain",
-			Body:        message,
-		},
+		newPublishing(message),
 	)
 	// If we cannot publish, try to reconnect to rabbitMQ service ONE time before
 	// return error
@@ -135,15 +137,35 @@ func (r *amqpClient) Publish(routingKey string, message []byte) error {
 			routingKey,
 			false,
 			false,
-			amqp.Publishing{
-				ContentType: "text/plain",
-				Body:        message,
-			},
+			newPublishing(message),
 		)
 	}
 	return nil
 }
 
+// Consumers measure the time the message waited in the queue with its publication time
+func newPublishing(message []byte) amqp.Publishing {
+	now := time.Now()
+	return amqp.Publishing{
+		ContentType: "text/plain",
+		Body:        message,
+		Headers:     amqp.Table{HeaderPublishedAt: now.UnixMilli()},
+		Timestamp:   now,
+	}
+}
+
+// Time the message has been published at, to the millisecond if the publisher told it. ok is
+// false if the publisher did not tell it at all.
+func PublishedAt(msg amqp.Delivery) (time.Time, bool) {
+	switch publishedAt := msg.Headers[HeaderPublishedAt].(type) {
+	case int64:
+		return time.UnixMilli(publishedAt), true
+	case int32:
+		return time.UnixMilli(int64(publishedAt)), true
+	}
+	return msg.Timestamp, !msg.Timestamp.IsZero()
+}
+
 func (r *amqpClient) Consume(nameQueue string) (<-chan amqp.Delivery, error) {
 	_, err := r.channel.QueueDeclare(nameQueue, false, false, false, false, nil)
 	if err != nil {
@@ -0,0 +1,41 @@
+package clients
+
+import (
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PublishedAt(t *testing.T) {
+	publishedAt := time.UnixMilli(1700000000123)
+	cases := []struct {
+		Name         string
+		GivenMsg     amqp.Delivery
+		ExpectedTime time.Time
+		ExpectedOk   bool
+	}{
+		{Name: "Header in milliseconds", GivenMsg: newDelivery(newPublishing([]byte("video"))), ExpectedOk: true},
+		{Name: "Header preferred to the timestamp", GivenMsg: amqp.Delivery{Headers: amqp.Table{HeaderPublishedAt: publishedAt.UnixMilli()}, Timestamp: publishedAt.Truncate(time.Second)}, ExpectedTime: publishedAt, ExpectedOk: true},
+		{Name: "Timestamp only", GivenMsg: amqp.Delivery{Timestamp: publishedAt.Truncate(time.Second)}, ExpectedTime: publishedAt.Truncate(time.Second), ExpectedOk: true},
+		{Name: "Invalid header", GivenMsg: amqp.Delivery{Headers: amqp.Table{HeaderPublishedAt: "yesterday"}, Timestamp: publishedAt.Truncate(time.Second)}, ExpectedTime: publishedAt.Truncate(time.Second), ExpectedOk: true},
+		{Name: "No publication time", GivenMsg: amqp.Delivery{}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			at, ok := PublishedAt(tt.GivenMsg)
+			require.Equal(t, tt.ExpectedOk, ok)
+			if tt.ExpectedTime.IsZero() && ok {
+				require.WithinDuration(t, time.Now(), at, time.Second)
+				return
+			}
+			require.True(t, tt.ExpectedTime.Equal(at), "expected %v, got %v", tt.ExpectedTime, at)
+		})
+	}
+}
+
+func newDelivery(publishing amqp.Publishing) amqp.Delivery {
+	return amqp.Delivery{Headers: publishing.Headers, Timestamp: publishing.Timestamp, Body: publishing.Body}
+}
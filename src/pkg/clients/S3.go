@@ -5,22 +5,27 @@ import (
 	_ "context"
 	"errors"
 	_ "errors"
+	"fmt"
 	"io"
 	_ "io"
 	"strings"
 	_ "strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
 	log "github.com/sirupsen/logrus"
 )
 
 type IS3Client interface {
 	ListObjects(ctx context.Context) ([]string, error)
-	GetObject(ctx context.Context, key string) (io.Reader, error)
+	GetObject(ctx context.Context, key string) (*Object, error)
+	GetObjectRange(ctx context.Context, key string, byteRange string) (*Object, error)
+	HeadObject(ctx context.Context, key string) (*Object, error)
 	PutObjectInput(ctx context.Context, f io.Reader, path string) error
 	CreateBucketIfDoesNotExists(ctx context.Context, bucketName string) error
 	RemoveObject(ctx context.Context, path string) error
@@ -28,6 +33,19 @@ type IS3Client interface {
 
 var _ IS3Client = s3Client{}
 
+// ErrInvalidRange is returned by GetObjectRange when the range does not overlap the object
+var ErrInvalidRange = errors.New("range not satisfiable")
+
+// Object is the body of an S3 object along with its metadata. The body is empty for HeadObject.
+type Object struct {
+	io.ReadCloser
+	ContentLength int64
+	ContentRange  string
+	ContentType   string
+	ETag          string
+	LastModified  time.Time
+}
+
 type s3Client struct {
 	awsS3Client *s3.Client
 	bucket      string
@@ -98,18 +116,56 @@ func (s s3Client) ListObjects(ctx context.Context) ([]string, error) {
 	return objectsName, nil
 }
 
-func (s s3Client) GetObject(ctx context.Context, key string) (io.Reader, error) {
+func (s s3Client) GetObject(ctx context.Context, key string) (*Object, error) {
+	return s.GetObjectRange(ctx, key, "")
+}
+
+// Get part of an object, byteRange follows the HTTP Range header syntax (e.g. bytes=0-99).
+// The whole object is returned if byteRange is empty.
+func (s s3Client) GetObjectRange(ctx context.Context, key string, byteRange string) (*Object, error) {
 	input := &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
 	}
+	if byteRange != "" {
+		input.Range = aws.String(byteRange)
+	}
 
 	response, err := s.awsS3Client.GetObject(ctx, input)
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidRange" {
+		return nil, fmt.Errorf("%w : %v", ErrInvalidRange, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Object{
+		ReadCloser:    response.Body,
+		ContentLength: aws.ToInt64(response.ContentLength),
+		ContentRange:  aws.ToString(response.ContentRange),
+		ContentType:   aws.ToString(response.ContentType),
+		ETag:          aws.ToString(response.ETag),
+		LastModified:  aws.ToTime(response.LastModified),
+	}, nil
+}
+
+func (s s3Client) HeadObject(ctx context.Context, key string) (*Object, error) {
+	response, err := s.awsS3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return response.Body, nil
+	return &Object{
+		ReadCloser:    io.NopCloser(strings.NewReader("")),
+		ContentLength: aws.ToInt64(response.ContentLength),
+		ContentType:   aws.ToString(response.ContentType),
+		ETag:          aws.ToString(response.ETag),
+		LastModified:  aws.ToTime(response.LastModified),
+	}, nil
 }
 
 func (s s3Client) PutObjectInput(ctx context.Context, fileReader io.Reader, path string) error {
@@ -1,9 +1,11 @@
 package clients
 
 import (
+	"encoding/json"
 	"fmt"
-	"strings"
-	"sync"
+	"sort"
+	"strconv"
+	"time"
 
 	consul_api "github.com/hashicorp/consul/api"
 	"github.com/hashicorp/consul/api/watch"
@@ -11,35 +13,97 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// Consul service metadata keys advertised by the transformers
+const (
+	MetaTransformerName        = "transformer_name"
+	MetaTransformerVersion     = "transformer_version"
+	MetaTransformerDescription = "transformer_description"
+	MetaTransformerSchema      = "transformer_schema"
+	MetaTransformerPerViewer   = "transformer_per_viewer"
+)
+
 type ServiceInfos struct {
 	Name    string
 	Address string
 	Port    int
 	Tags    []string
+	// Transformers served by the service, every one is registered apart with its metadata
+	Transformers []TransformerInfos
+}
+
+// TransformerInfos describes a transformer to its clients. Schema is the JSON schema of its
+// parameters. Consul limits every metadata value to 512 characters.
+type TransformerInfos struct {
+	Name        string
+	Version     string
+	Description string
+	Schema      string
+	// The output depends on the viewer, it must not be shared between viewers
+	PerViewer bool
 }
 
 type TransformersInstances struct {
-	index        int
+	infos        TransformerInfos
 	servicesURLs []string
 }
 
 type ServiceDiscovery interface {
-	GetTransformationService(name string) (string, error)
+	// Get a client to an instance of the last transformer of chain, preferably one also serving
+	// the previous transformers so that it runs them in the same pass
+	GetTransformationClient(chain []string, exclude ...string) (*TransformerClient, error)
 	GetExistingServices() []models.TransformerService
 	StartServiceDiscovery(serviceInfos ServiceInfos) error
 	Stop()
 }
 
+// Service discovery backends
+const (
+	DiscoveryBackendConsul = "consul"
+	DiscoveryBackendFile   = "file"
+	DiscoveryBackendDNS    = "dns"
+)
+
+// DiscoveryConfig selects the service discovery backend, only the settings of the selected
+// backend are used
+type DiscoveryConfig struct {
+	Backend   string
+	ConsulURL string
+	// File listing the transformers
+	File string
+	// Domain of the SRV records, and the transformers looked up in it
+	DNSDomain   string
+	DNSServices []string
+	// Interval between two reloads of the file or lookups of the DNS
+	RefreshInterval time.Duration
+}
+
+// NewServiceDiscoveryFromConfig creates the service discovery of the configured backend
+func NewServiceDiscoveryFromConfig(cfg DiscoveryConfig) (ServiceDiscovery, error) {
+	switch cfg.Backend {
+	case DiscoveryBackendConsul, "":
+		if cfg.ConsulURL == "" {
+			return nil, fmt.Errorf("no Consul URL given")
+		}
+		return NewServiceDiscovery(cfg.ConsulURL)
+	case DiscoveryBackendFile:
+		return NewFileServiceDiscovery(cfg.File, cfg.RefreshInterval)
+	case DiscoveryBackendDNS:
+		return NewDNSServiceDiscovery(cfg.DNSDomain, cfg.DNSServices, cfg.RefreshInterval)
+	}
+	return nil, fmt.Errorf("unknown service discovery backend %v", cfg.Backend)
+}
+
 var _ ServiceDiscovery = &serviceDiscovery{}
 
 type serviceDiscovery struct {
-	client                    *consul_api.Client
-	agent                     *consul_api.Agent
-	plan                      *watch.Plan
-	transformersAddressesList map[string]*TransformersInstances
-	mutex                     sync.RWMutex
+	*transformerDirectory
+	client     *consul_api.Client
+	agent      *consul_api.Agent
+	plan       *watch.Plan
+	checksPlan *watch.Plan
 }
 
+// NewServiceDiscovery creates a service discovery backed by the Consul agent at consulURL
 func NewServiceDiscovery(consulURL string) (ServiceDiscovery, error) {
 	// Create a Consul API client
 	client, err := consul_api.NewClient(&consul_api.Config{Address: consulURL})
@@ -53,13 +117,19 @@ func NewServiceDiscovery(consulURL string) (ServiceDiscovery, error) {
 		return nil, err
 	}
 
+	// Create Watch that check for changes on services health
+	checksPlan, err := watch.Parse(map[string]interface{}{"type": "checks"})
+	if err != nil {
+		return nil, err
+	}
+
 	// Create service discovery
 	service := serviceDiscovery{
-		client:                    client,
-		agent:                     client.Agent(),
-		plan:                      plan,
-		transformersAddressesList: map[string]*TransformersInstances{},
-		mutex:                     sync.RWMutex{},
+		transformerDirectory: newTransformerDirectory(),
+		client:               client,
+		agent:                client.Agent(),
+		plan:                 plan,
+		checksPlan:           checksPlan,
 	}
 
 	return &service, nil
@@ -80,29 +150,12 @@ func (s *serviceDiscovery) StartServiceDiscovery(serviceInfos ServiceInfos) erro
 	return nil
 }
 
-// Get all available instances of a given transformation service
-func (s *serviceDiscovery) GetTransformationService(name string) (string, error) {
-	// We need to ensure that the Watch function runs by another goroutine is not
-	// currently modifying the list
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
-	if s.transformersAddressesList[name] == nil {
-		return "", fmt.Errorf("No service with name %v found.", name)
-	}
-	serviceInstance := loadBalancing(s.transformersAddressesList[name])
-	return serviceInstance, nil
-}
-
-func loadBalancing(t *TransformersInstances) string {
-	t.index = t.index + 1
-	if t.index >= len(t.servicesURLs) {
-		t.index = 0
+func (s *serviceDiscovery) registerService(serviceInfos ServiceInfos) error {
+	if serviceInfos.Address == "" {
+		return nil
 	}
-	return t.servicesURLs[t.index]
-}
 
-func (s *serviceDiscovery) registerService(serviceInfos ServiceInfos) error {
-	if serviceInfos.Address != "" {
+	if len(serviceInfos.Transformers) == 0 {
 		return s.agent.ServiceRegister(&consul_api.AgentServiceRegistration{
 			Name:    serviceInfos.Name,
 			Address: serviceInfos.Address,
@@ -110,49 +163,76 @@ func (s *serviceDiscovery) registerService(serviceInfos ServiceInfos) error {
 			Tags:    serviceInfos.Tags,
 		})
 	}
-	return nil
-}
 
-func (s *serviceDiscovery) GetExistingServices() []models.TransformerService {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
-	existingServices := []models.TransformerService{}
-	for name := range s.transformersAddressesList {
-		existingServices = append(existingServices, *models.CreateTransformerService(name))
+	// Consul metadata describes a single transformer, an instance serving several of them is
+	// registered once for each
+	for _, transformer := range serviceInfos.Transformers {
+		err := s.agent.ServiceRegister(&consul_api.AgentServiceRegistration{
+			ID:      fmt.Sprintf("%v-%v-%v", serviceInfos.Name, transformer.Name, serviceInfos.Port),
+			Name:    serviceInfos.Name,
+			Address: serviceInfos.Address,
+			Port:    serviceInfos.Port,
+			Tags:    serviceInfos.Tags,
+			Meta:    transformer.meta(),
+		})
+		if err != nil {
+			return fmt.Errorf("Cannot register transformer %v : %w", transformer.Name, err)
+		}
 	}
-	return existingServices
+	return nil
 }
 
 func (s *serviceDiscovery) updateList() error {
-	services, err := s.agent.ServicesWithFilter("transformer in Service")
+	services, err := s.agent.ServicesWithFilter(strconv.Quote(MetaTransformerName) + " in Meta")
+	if err != nil {
+		return err
+	}
+
+	// Instances failing their Consul health checks are not sent requests
+	checks, err := s.agent.Checks()
 	if err != nil {
 		return err
 	}
+	critical := map[string]bool{}
+	for _, check := range checks {
+		if check.Status == consul_api.HealthCritical {
+			critical[check.ServiceID] = true
+		}
+	}
 
-	// This part update the transformersAddressesList, since it is down
-	// by the Watch function which aims to be run by a goroutine, we need
-	// to ensure that no one is already reading on this list.
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
 	tmpList := map[string]*TransformersInstances{}
 
-	for _, service := range services {
-		name := strings.Split(service.Service, "-")[0]
+	// Instances of a transformer may advertise different descriptions during an upgrade, the
+	// services are sorted so that the same one is kept on every update
+	ids := make([]string, 0, len(services))
+	for id := range services {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		service := services[id]
+		infos := transformerInfosFromMeta(service.Meta)
+		if infos.Name == "" || service.Port == 0 {
+			log.Warn("Transformer ", service.ID, " does not advertise its name or its port, it is skipped")
+			continue
+		}
+		name := infos.Name
 
 		if tmpList[name] == nil {
-			if s.transformersAddressesList[name] == nil {
-				tmpList[name] = &TransformersInstances{index: 0, servicesURLs: []string{}}
-			} else {
-				tmpList[name] = &TransformersInstances{index: s.transformersAddressesList[name].index, servicesURLs: []string{}}
-			}
+			tmpList[name] = &TransformersInstances{infos: infos, servicesURLs: []string{}}
+		} else if tmpList[name].infos != infos {
+			log.Debug("Instances of transformer ", name, " advertise different descriptions, ", service.ID, " one is ignored")
+		}
+		if critical[service.ID] {
+			log.Debug("Transformer ", service.ID, " fails its health checks, it is skipped")
+			continue
 		}
-		// address := service.Address + ":" + strconv.Itoa(service.Port)
 
-		// TODO : REMOVE IT WHEN SQUARESCALE UPDATE PORT FROM DOCKERFILE TO NOMAD/CONSUL
-		address := service.Address + ":" + fixMeLater(name)
+		address := service.Address + ":" + strconv.Itoa(service.Port)
 		tmpList[name].servicesURLs = append(tmpList[name].servicesURLs, address)
 	}
-	s.transformersAddressesList = tmpList
+	s.setTransformers(tmpList)
 	return nil
 }
 
@@ -164,22 +244,53 @@ func (s *serviceDiscovery) watch() error {
 		_ = s.updateList()
 	}
 
+	// Health changes do not register or deregister services, they are watched apart
+	s.checksPlan.Handler = func(idx uint64, result interface{}) {
+		log.Debug("Change detected : Health check status")
+		_ = s.updateList()
+	}
+	go func() {
+		if err := s.checksPlan.RunWithClientAndHclog(s.client, nil); err != nil {
+			log.Error("Health checks watch stopped : ", err)
+		}
+	}()
+
 	// Launch the watch. Note that the handler function will be run one first time
 	return s.plan.RunWithClientAndHclog(s.client, nil)
 }
 
 func (s *serviceDiscovery) Stop() {
 	s.plan.Stop()
+	s.checksPlan.Stop()
+	s.close()
 	log.Info("Gracefully shutdown service discovery")
 }
 
-// TODO : REMOVE IT WHEN SQUARESCALE UPDATE PORT FROM DOCKERFILE TO NOMAD/CONSUL
-func fixMeLater(name string) string {
-	switch name {
-	case "gray":
-		return "50051"
-	case "flip":
-		return "50052"
+func (t TransformerInfos) meta() map[string]string {
+	meta := map[string]string{
+		MetaTransformerName:        t.Name,
+		MetaTransformerVersion:     t.Version,
+		MetaTransformerDescription: t.Description,
+		MetaTransformerSchema:      t.Schema,
+	}
+	if t.PerViewer {
+		meta[MetaTransformerPerViewer] = "true"
+	}
+	return meta
+}
+
+func transformerInfosFromMeta(meta map[string]string) TransformerInfos {
+	infos := TransformerInfos{
+		Name:        meta[MetaTransformerName],
+		Version:     meta[MetaTransformerVersion],
+		Description: meta[MetaTransformerDescription],
+		Schema:      meta[MetaTransformerSchema],
+		PerViewer:   meta[MetaTransformerPerViewer] == "true",
+	}
+	// The schema is sent as is to the API clients, it must not break their JSON
+	if infos.Schema != "" && !json.Valid([]byte(infos.Schema)) {
+		log.Warn("Transformer ", infos.Name, " advertises an invalid parameter schema, it is ignored")
+		infos.Schema = ""
 	}
-	return ""
+	return infos
 }
@@ -0,0 +1,136 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Time given to the lookups of one refresh
+const dnsLookupTimeout time.Duration = 5 * time.Second
+
+var _ ServiceDiscovery = &dnsServiceDiscovery{}
+
+// dnsServiceDiscovery finds the instances of a fixed list of transformers with the SRV records
+// _<name>._tcp.<domain>. TXT records of the same name may describe the transformer with the
+// DNS-SD key=value format, using the keys of the Consul metadata.
+type dnsServiceDiscovery struct {
+	*transformerDirectory
+	resolver        *net.Resolver
+	domain          string
+	names           []string
+	refreshInterval time.Duration
+	stop            chan struct{}
+	stopOnce        sync.Once
+}
+
+// NewDNSServiceDiscovery creates a service discovery looking up the transformers names in domain
+// every refreshInterval
+func NewDNSServiceDiscovery(domain string, names []string, refreshInterval time.Duration) (ServiceDiscovery, error) {
+	if domain == "" {
+		return nil, fmt.Errorf("no discovery DNS domain given")
+	}
+	if refreshInterval <= 0 {
+		return nil, fmt.Errorf("invalid discovery refresh interval %v", refreshInterval)
+	}
+
+	return &dnsServiceDiscovery{
+		transformerDirectory: newTransformerDirectory(),
+		resolver:             net.DefaultResolver,
+		domain:               domain,
+		names:                names,
+		refreshInterval:      refreshInterval,
+		stop:                 make(chan struct{}),
+	}, nil
+}
+
+// Look up the transformers until Stop is called. Services are not registered, their records must
+// be managed by the DNS zone owner.
+func (s *dnsServiceDiscovery) StartServiceDiscovery(serviceInfos ServiceInfos) error {
+	if serviceInfos.Address != "" {
+		log.Debug("Service ", serviceInfos.Name, " is not registered, it must have SRV records in ", s.domain)
+	}
+
+	ticker := time.NewTicker(s.refreshInterval)
+	defer ticker.Stop()
+	for {
+		s.updateList()
+
+		select {
+		case <-s.stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *dnsServiceDiscovery) updateList() {
+	ctx, cancel := context.WithTimeout(context.Background(), dnsLookupTimeout)
+	defer cancel()
+
+	s.mutex.RLock()
+	previous := s.transformersAddressesList
+	s.mutex.RUnlock()
+
+	tmpList := map[string]*TransformersInstances{}
+	for _, name := range s.names {
+		instances, err := s.lookup(ctx, name)
+		var dnsErr *net.DNSError
+		switch {
+		case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+			log.Debug("No SRV record for transformer ", name)
+		case err != nil:
+			// The instances are kept until the DNS answers again
+			log.Error("Cannot look up transformer ", name, " : ", err)
+			if previous[name] != nil {
+				tmpList[name] = previous[name]
+			}
+		case len(instances.servicesURLs) > 0:
+			tmpList[name] = instances
+		}
+	}
+	s.setTransformers(tmpList)
+}
+
+func (s *dnsServiceDiscovery) lookup(ctx context.Context, name string) (*TransformersInstances, error) {
+	_, records, err := s.resolver.LookupSRV(ctx, name, "tcp", s.domain)
+	if err != nil {
+		return nil, err
+	}
+
+	instances := &TransformersInstances{infos: TransformerInfos{Name: name}, servicesURLs: []string{}}
+	for _, record := range records {
+		host := strings.TrimSuffix(record.Target, ".")
+		instances.servicesURLs = append(instances.servicesURLs, net.JoinHostPort(host, strconv.Itoa(int(record.Port))))
+	}
+
+	// Descriptions are optional, the transformer is usable without them
+	txts, err := s.resolver.LookupTXT(ctx, "_"+name+"._tcp."+s.domain)
+	if err != nil {
+		log.Debug("No TXT record for transformer ", name, " : ", err)
+		return instances, nil
+	}
+	meta := map[string]string{}
+	for _, txt := range txts {
+		if key, value, ok := strings.Cut(txt, "="); ok {
+			meta[key] = value
+		}
+	}
+	infos := transformerInfosFromMeta(meta)
+	infos.Name = name
+	instances.infos = infos
+	return instances, nil
+}
+
+func (s *dnsServiceDiscovery) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	s.close()
+	log.Info("Gracefully shutdown service discovery")
+}
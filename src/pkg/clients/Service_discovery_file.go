@@ -0,0 +1,148 @@
+package clients
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var _ ServiceDiscovery = &fileServiceDiscovery{}
+
+// DiscoveryFile is the content of the file read by the file service discovery, e.g.
+//
+//	{
+//	  "transformers": [
+//	    {"name": "gray", "version": "1.0.0", "addresses": ["localhost:50051"]},
+//	    {"name": "flip", "addresses": ["localhost:50052", "localhost:50062"]}
+//	  ]
+//	}
+type DiscoveryFile struct {
+	Transformers []DiscoveryFileTransformer `json:"transformers"`
+}
+
+type DiscoveryFileTransformer struct {
+	Name        string          `json:"name"`
+	Version     string          `json:"version,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema,omitempty"`
+	PerViewer   bool            `json:"perViewer,omitempty"`
+	Addresses   []string        `json:"addresses"`
+}
+
+// fileServiceDiscovery lists the transformers of a static file, reloaded when it changes
+type fileServiceDiscovery struct {
+	*transformerDirectory
+	path            string
+	refreshInterval time.Duration
+	content         []byte
+	stop            chan struct{}
+	stopOnce        sync.Once
+}
+
+// NewFileServiceDiscovery creates a service discovery listing the transformers of the file at
+// path, checked for changes every refreshInterval
+func NewFileServiceDiscovery(path string, refreshInterval time.Duration) (ServiceDiscovery, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no discovery file given")
+	}
+	if refreshInterval <= 0 {
+		return nil, fmt.Errorf("invalid discovery refresh interval %v", refreshInterval)
+	}
+
+	return &fileServiceDiscovery{
+		transformerDirectory: newTransformerDirectory(),
+		path:                 path,
+		refreshInterval:      refreshInterval,
+		stop:                 make(chan struct{}),
+	}, nil
+}
+
+// Load the file, then reload it on every change until Stop is called. Services are not registered,
+// the file must list them.
+func (s *fileServiceDiscovery) StartServiceDiscovery(serviceInfos ServiceInfos) error {
+	if serviceInfos.Address != "" {
+		log.Debug("Service ", serviceInfos.Name, " is not registered, it must be listed in ", s.path)
+	}
+
+	if err := s.reload(); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(s.refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return nil
+		case <-ticker.C:
+			// The previous list is kept until the file is fixed
+			if err := s.reload(); err != nil {
+				log.Error("Cannot reload discovery file : ", err)
+			}
+		}
+	}
+}
+
+func (s *fileServiceDiscovery) reload() error {
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	if s.content != nil && bytes.Equal(content, s.content) {
+		return nil
+	}
+
+	// An invalid content is reported once, rather than on every refresh
+	s.content = content
+	list, err := parseDiscoveryFile(content)
+	if err != nil {
+		return fmt.Errorf("%v : %w", s.path, err)
+	}
+
+	log.Info("Change detected : Discovery file ", s.path)
+	s.setTransformers(list)
+	return nil
+}
+
+func parseDiscoveryFile(content []byte) (map[string]*TransformersInstances, error) {
+	file := DiscoveryFile{}
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, err
+	}
+
+	list := map[string]*TransformersInstances{}
+	for _, transformer := range file.Transformers {
+		if transformer.Name == "" {
+			return nil, fmt.Errorf("transformer without name")
+		}
+		if list[transformer.Name] != nil {
+			return nil, fmt.Errorf("transformer %v is listed twice", transformer.Name)
+		}
+
+		infos := TransformerInfos{
+			Name:        transformer.Name,
+			Version:     transformer.Version,
+			Description: transformer.Description,
+			PerViewer:   transformer.PerViewer,
+		}
+		if len(transformer.Schema) > 0 {
+			infos.Schema = string(transformer.Schema)
+		}
+		list[transformer.Name] = &TransformersInstances{
+			infos:        infos,
+			servicesURLs: append([]string{}, transformer.Addresses...),
+		}
+	}
+	return list, nil
+}
+
+func (s *fileServiceDiscovery) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	s.close()
+	log.Info("Gracefully shutdown service discovery")
+}
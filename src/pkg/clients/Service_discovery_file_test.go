@@ -0,0 +1,59 @@
+package clients
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseDiscoveryFile(t *testing.T) {
+	cases := []struct {
+		Name          string
+		GivenContent  string
+		ExpectedList  map[string]*TransformersInstances
+		ExpectedError bool
+	}{
+		{
+			Name:         "Transformers with their instances",
+			GivenContent: `{"transformers": [{"name": "gray", "version": "1.0.0", "addresses": ["localhost:50051"]}, {"name": "flip", "addresses": ["localhost:50052", "localhost:50062"]}]}`,
+			ExpectedList: map[string]*TransformersInstances{
+				"gray": {infos: TransformerInfos{Name: "gray", Version: "1.0.0"}, servicesURLs: []string{"localhost:50051"}},
+				"flip": {infos: TransformerInfos{Name: "flip"}, servicesURLs: []string{"localhost:50052", "localhost:50062"}},
+			},
+		},
+		{
+			Name:         "Schema and per viewer transformer",
+			GivenContent: `{"transformers": [{"name": "forensic", "description": "Viewer mark", "schema": {"type": "object"}, "perViewer": true, "addresses": ["10.0.0.1:50051"]}]}`,
+			ExpectedList: map[string]*TransformersInstances{
+				"forensic": {infos: TransformerInfos{Name: "forensic", Description: "Viewer mark", Schema: `{"type": "object"}`, PerViewer: true}, servicesURLs: []string{"10.0.0.1:50051"}},
+			},
+		},
+		{
+			Name:         "Transformer without instance",
+			GivenContent: `{"transformers": [{"name": "gray"}]}`,
+			ExpectedList: map[string]*TransformersInstances{
+				"gray": {infos: TransformerInfos{Name: "gray"}, servicesURLs: []string{}},
+			},
+		},
+		{
+			Name:         "No transformer",
+			GivenContent: `{}`,
+			ExpectedList: map[string]*TransformersInstances{},
+		},
+		{Name: "Transformer without name", GivenContent: `{"transformers": [{"addresses": ["localhost:50051"]}]}`, ExpectedError: true},
+		{Name: "Transformer listed twice", GivenContent: `{"transformers": [{"name": "gray"}, {"name": "gray"}]}`, ExpectedError: true},
+		{Name: "Invalid JSON", GivenContent: `{"transformers": [`, ExpectedError: true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			list, err := parseDiscoveryFile([]byte(tt.GivenContent))
+			if tt.ExpectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.ExpectedList, list)
+		})
+	}
+}
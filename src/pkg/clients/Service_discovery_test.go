@@ -0,0 +1,61 @@
+package clients
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TransformerInfosFromMeta(t *testing.T) {
+	cases := []struct {
+		Name          string
+		GivenMeta     map[string]string
+		ExpectedInfos TransformerInfos
+	}{
+		{
+			Name: "Every metadata",
+			GivenMeta: map[string]string{
+				MetaTransformerName:        "blur",
+				MetaTransformerVersion:     "1.2.0",
+				MetaTransformerDescription: "Blur the video",
+				MetaTransformerSchema:      `{"type":"object","properties":{"radius":{"type":"number"}}}`,
+			},
+			ExpectedInfos: TransformerInfos{Name: "blur", Version: "1.2.0", Description: "Blur the video", Schema: `{"type":"object","properties":{"radius":{"type":"number"}}}`},
+		},
+		{
+			Name:          "Per viewer transformer",
+			GivenMeta:     map[string]string{MetaTransformerName: "forensic", MetaTransformerPerViewer: "true"},
+			ExpectedInfos: TransformerInfos{Name: "forensic", PerViewer: true},
+		},
+		{
+			Name:          "Per viewer flag other than true",
+			GivenMeta:     map[string]string{MetaTransformerName: "gray", MetaTransformerPerViewer: "yes"},
+			ExpectedInfos: TransformerInfos{Name: "gray"},
+		},
+		{
+			Name:          "Invalid schema ignored",
+			GivenMeta:     map[string]string{MetaTransformerName: "gray", MetaTransformerSchema: `{"type":`},
+			ExpectedInfos: TransformerInfos{Name: "gray"},
+		},
+		{
+			Name:          "No metadata",
+			GivenMeta:     nil,
+			ExpectedInfos: TransformerInfos{},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			require.Equal(t, tt.ExpectedInfos, transformerInfosFromMeta(tt.GivenMeta))
+		})
+	}
+}
+
+func Test_TransformerInfosMeta(t *testing.T) {
+	infos := TransformerInfos{Name: "forensic", Version: "1.0.0", Description: "Viewer mark", Schema: `{"type":"object"}`, PerViewer: true}
+	require.Equal(t, infos, transformerInfosFromMeta(infos.meta()))
+
+	// Transformers which are not per viewer do not advertise it
+	_, ok := TransformerInfos{Name: "gray"}.meta()[MetaTransformerPerViewer]
+	require.False(t, ok)
+}
@@ -0,0 +1,164 @@
+package clients
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	transformerv2 "github.com/rishirishhh/vought/src/pkg/transformer/v2"
+)
+
+const (
+	// Consecutive failed requests after which an instance is ejected
+	maxConsecutiveFailures int = 3
+	// Time an ejected instance is not sent requests
+	ejectionDuration time.Duration = 30 * time.Second
+)
+
+// balancer picks transformer instances with the power of two choices: among two random usable
+// instances, the one with the least outstanding requests. Instances failing repeatedly are
+// ejected for a while, busy ones are not sent requests until they tell to retry.
+type balancer struct {
+	mutex     sync.Mutex
+	instances map[string]*instanceStats
+	random    *rand.Rand
+}
+
+type instanceStats struct {
+	outstanding         int
+	consecutiveFailures int
+	ejectedUntil        time.Time
+	busyUntil           time.Time
+}
+
+func newBalancer() *balancer {
+	return &balancer{
+		instances: map[string]*instanceStats{},
+		random:    rand.New(rand.NewSource(time.Now().UnixNano())), //nolint:gosec
+	}
+}
+
+// Pick one of the addresses for a new request, skipping the excluded, ejected and busy ones and
+// the ones usable returns false for. The request must be released with done.
+func (b *balancer) pick(addresses []string, exclude map[string]bool, usable func(address string) bool) (string, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	candidates := []string{}
+	for _, address := range addresses {
+		stats := b.stats(address)
+		if exclude[address] || now.Before(stats.ejectedUntil) || now.Before(stats.busyUntil) || !usable(address) {
+			continue
+		}
+		candidates = append(candidates, address)
+	}
+
+	var picked string
+	switch len(candidates) {
+	case 0:
+		return "", false
+	case 1:
+		picked = candidates[0]
+	default:
+		i := b.random.Intn(len(candidates))
+		j := b.random.Intn(len(candidates) - 1)
+		if j >= i {
+			j++
+		}
+		picked = candidates[i]
+		if b.stats(candidates[j]).outstanding < b.stats(picked).outstanding {
+			picked = candidates[j]
+		}
+	}
+
+	b.stats(picked).outstanding++
+	return picked, true
+}
+
+// Release a request picked on address, err is the outcome of the request
+func (b *balancer) done(address string, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	stats := b.stats(address)
+	stats.outstanding--
+
+	// A busy instance is not failing, it is skipped until it can take the request
+	if retryDelay, busy := transformerv2.RetryDelay(err); busy {
+		log.Debugf("Transformer %v is busy, retrying in %v", address, retryDelay)
+		stats.busyUntil = time.Now().Add(retryDelay)
+		return
+	}
+
+	if !isInstanceFailure(err) {
+		stats.consecutiveFailures = 0
+		return
+	}
+
+	stats.consecutiveFailures++
+	if stats.consecutiveFailures >= maxConsecutiveFailures {
+		log.Warnf("Transformer %v failed %v times in a row, ejected for %v", address, stats.consecutiveFailures, ejectionDuration)
+		stats.ejectedUntil = time.Now().Add(ejectionDuration)
+		stats.consecutiveFailures = 0
+	}
+}
+
+// Time until one of the addresses, skipped because it is busy, can take a request. ok is false if
+// none of them is busy.
+func (b *balancer) busyDelay(addresses []string) (time.Duration, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	delay, ok := time.Duration(0), false
+	for _, address := range addresses {
+		if until := b.stats(address).busyUntil; now.Before(until) && (!ok || until.Sub(now) < delay) {
+			delay, ok = until.Sub(now), true
+		}
+	}
+	return delay, ok
+}
+
+// Forget the instances which are not in addresses anymore
+func (b *balancer) retain(addresses []string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	current := map[string]bool{}
+	for _, address := range addresses {
+		current[address] = true
+	}
+	for address, stats := range b.instances {
+		if !current[address] && stats.outstanding <= 0 {
+			delete(b.instances, address)
+		}
+	}
+}
+
+func (b *balancer) stats(address string) *instanceStats {
+	stats, ok := b.instances[address]
+	if !ok {
+		stats = &instanceStats{}
+		b.instances[address] = stats
+	}
+	return stats
+}
+
+// Errors telling the instance is not able to serve requests, as opposed to a canceled request or
+// an invalid one. Instances rejecting requests because the next transformers are busy are not
+// failing.
+func isInstanceFailure(err error) bool {
+	if _, busy := transformerv2.RetryAfter(err); err == nil || busy {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Internal, codes.Unknown, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
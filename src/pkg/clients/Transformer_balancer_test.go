@@ -0,0 +1,181 @@
+package clients
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	transformerv2 "github.com/rishirishhh/vought/src/pkg/transformer/v2"
+)
+
+func Test_BalancerPick(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		Name            string
+		GivenStats      map[string]instanceStats
+		GivenAddresses  []string
+		GivenExcluded   []string
+		GivenUnusable   []string
+		ExpectedAddress string
+		ExpectedOk      bool
+	}{
+		{
+			Name:            "Single instance",
+			GivenAddresses:  []string{"a:1"},
+			ExpectedAddress: "a:1",
+			ExpectedOk:      true,
+		},
+		{
+			Name:            "Least outstanding requests",
+			GivenStats:      map[string]instanceStats{"a:1": {outstanding: 3}, "b:1": {outstanding: 1}},
+			GivenAddresses:  []string{"a:1", "b:1"},
+			ExpectedAddress: "b:1",
+			ExpectedOk:      true,
+		},
+		{
+			Name:            "Excluded instance",
+			GivenAddresses:  []string{"a:1", "b:1"},
+			GivenExcluded:   []string{"b:1"},
+			ExpectedAddress: "a:1",
+			ExpectedOk:      true,
+		},
+		{
+			Name:            "Unusable instance",
+			GivenAddresses:  []string{"a:1", "b:1"},
+			GivenUnusable:   []string{"a:1"},
+			ExpectedAddress: "b:1",
+			ExpectedOk:      true,
+		},
+		{
+			Name:            "Ejected instance",
+			GivenStats:      map[string]instanceStats{"a:1": {ejectedUntil: now.Add(time.Minute)}, "b:1": {outstanding: 5}},
+			GivenAddresses:  []string{"a:1", "b:1"},
+			ExpectedAddress: "b:1",
+			ExpectedOk:      true,
+		},
+		{
+			Name:            "Ejection over",
+			GivenStats:      map[string]instanceStats{"a:1": {ejectedUntil: now.Add(-time.Second)}, "b:1": {outstanding: 5}},
+			GivenAddresses:  []string{"a:1", "b:1"},
+			ExpectedAddress: "a:1",
+			ExpectedOk:      true,
+		},
+		{
+			Name:            "Busy instance",
+			GivenStats:      map[string]instanceStats{"a:1": {busyUntil: now.Add(time.Minute)}, "b:1": {outstanding: 5}},
+			GivenAddresses:  []string{"a:1", "b:1"},
+			ExpectedAddress: "b:1",
+			ExpectedOk:      true,
+		},
+		{
+			Name:           "Every instance skipped",
+			GivenStats:     map[string]instanceStats{"a:1": {busyUntil: now.Add(time.Minute)}, "b:1": {ejectedUntil: now.Add(time.Minute)}},
+			GivenAddresses: []string{"a:1", "b:1", "c:1"},
+			GivenExcluded:  []string{"c:1"},
+		},
+		{
+			Name: "No instance",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			b := newBalancer()
+			for address, stats := range tt.GivenStats {
+				stats := stats
+				b.instances[address] = &stats
+			}
+			excluded := map[string]bool{}
+			for _, address := range tt.GivenExcluded {
+				excluded[address] = true
+			}
+			usable := func(address string) bool {
+				for _, unusable := range tt.GivenUnusable {
+					if address == unusable {
+						return false
+					}
+				}
+				return true
+			}
+
+			address, ok := b.pick(tt.GivenAddresses, excluded, usable)
+			require.Equal(t, tt.ExpectedOk, ok)
+			require.Equal(t, tt.ExpectedAddress, address)
+			if ok {
+				require.Equal(t, tt.GivenStats[address].outstanding+1, b.stats(address).outstanding)
+			}
+		})
+	}
+}
+
+func Test_BalancerDone(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "down")
+	cases := []struct {
+		Name             string
+		GivenErrs        []error
+		ExpectedEjected  bool
+		ExpectedBusy     time.Duration
+		ExpectedFailures int
+	}{
+		{Name: "Success", GivenErrs: []error{nil}},
+		{Name: "Failures below the threshold", GivenErrs: []error{unavailable, unavailable}, ExpectedFailures: 2},
+		{Name: "Consecutive failures", GivenErrs: []error{unavailable, status.Error(codes.Internal, "crashed"), unavailable}, ExpectedEjected: true},
+		{Name: "Failures reset by a success", GivenErrs: []error{unavailable, unavailable, nil, unavailable}, ExpectedFailures: 1},
+		{Name: "Invalid requests are not failures", GivenErrs: []error{unavailable, status.Error(codes.InvalidArgument, "invalid"), unavailable}, ExpectedFailures: 1},
+		{Name: "Canceled requests are not failures", GivenErrs: []error{unavailable, unavailable, status.Error(codes.Canceled, "canceled"), unavailable}, ExpectedFailures: 1},
+		{Name: "Busy instance", GivenErrs: []error{transformerv2.BusyError("busy", time.Minute)}, ExpectedBusy: time.Minute},
+		{Name: "Busy instance is not failing", GivenErrs: []error{unavailable, unavailable, transformerv2.BusyError("busy", time.Minute)}, ExpectedBusy: time.Minute, ExpectedFailures: 2},
+		{Name: "Busy next transformers", GivenErrs: []error{unavailable, unavailable, transformerv2.NextBusyError("next busy", time.Minute), unavailable}, ExpectedFailures: 1},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			b := newBalancer()
+			for _, err := range tt.GivenErrs {
+				address, ok := b.pick([]string{"a:1"}, nil, func(string) bool { return true })
+				require.True(t, ok)
+				b.done(address, err)
+			}
+
+			stats := b.stats("a:1")
+			require.Equal(t, 0, stats.outstanding)
+			require.Equal(t, tt.ExpectedFailures, stats.consecutiveFailures)
+			require.Equal(t, tt.ExpectedEjected, time.Now().Before(stats.ejectedUntil))
+
+			delay, busy := b.busyDelay([]string{"a:1"})
+			require.Equal(t, tt.ExpectedBusy > 0, busy)
+			require.InDelta(t, tt.ExpectedBusy, delay, float64(time.Second))
+		})
+	}
+}
+
+func Test_BalancerBusyDelay(t *testing.T) {
+	b := newBalancer()
+	b.instances["a:1"] = &instanceStats{busyUntil: time.Now().Add(10 * time.Second)}
+	b.instances["b:1"] = &instanceStats{busyUntil: time.Now().Add(2 * time.Second)}
+	b.instances["c:1"] = &instanceStats{busyUntil: time.Now().Add(-time.Second)}
+
+	// The soonest instance to take requests again
+	delay, busy := b.busyDelay([]string{"a:1", "b:1", "c:1"})
+	require.True(t, busy)
+	require.InDelta(t, 2*time.Second, delay, float64(100*time.Millisecond))
+
+	_, busy = b.busyDelay([]string{"c:1", "d:1"})
+	require.False(t, busy)
+
+	// Instances gone are forgotten, unless requests are still sent to them
+	b.instances["c:1"].outstanding = 1
+	b.retain([]string{"a:1"})
+	require.ElementsMatch(t, []string{"a:1", "c:1"}, keys(b.instances))
+}
+
+func keys(instances map[string]*instanceStats) []string {
+	addresses := []string{}
+	for address := range instances {
+		addresses = append(addresses, address)
+	}
+	return addresses
+}
@@ -0,0 +1,152 @@
+package clients
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/rishirishhh/vought/src/cmd/api/models"
+	transformerv2 "github.com/rishirishhh/vought/src/pkg/transformer/v2"
+)
+
+// transformerDirectory holds the transformer instances found by a service discovery backend,
+// and hands out clients to them. Backends only replace its list when they detect changes.
+type transformerDirectory struct {
+	transformersAddressesList map[string]*TransformersInstances
+	mutex                     sync.RWMutex
+	pool                      *transformerPool
+	balancer                  *balancer
+	// Updates racing with the backend stop are ignored once closed
+	closed bool
+}
+
+func newTransformerDirectory() *transformerDirectory {
+	return &transformerDirectory{
+		transformersAddressesList: map[string]*TransformersInstances{},
+		pool:                      newTransformerPool(),
+		balancer:                  newBalancer(),
+	}
+}
+
+// Get a client to a healthy instance of the last transformation service of chain, other than the
+// excluded addresses. Instances also serving the previous services of the chain are preferred, the
+// more of them the better. Connections are shared, the client must not be closed but released
+// with Done.
+func (d *transformerDirectory) GetTransformationClient(chain []string, exclude ...string) (*TransformerClient, error) {
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("%w : no service given", ErrNoTransformerAvailable)
+	}
+	name := chain[len(chain)-1]
+
+	// We need to ensure that the backend watching for changes, run by another goroutine, is not
+	// currently modifying the list
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	if d.transformersAddressesList[name] == nil {
+		return nil, fmt.Errorf("%w : no service with name %v found", ErrNoTransformerAvailable, name)
+	}
+
+	excluded := map[string]bool{}
+	for _, address := range exclude {
+		excluded[address] = true
+	}
+
+	// Skip the instances which are unhealthy or not connected, from the instances serving the
+	// longest part of the chain to every instance of the service
+	usable := func(address string) bool {
+		_, healthy := d.pool.get(address)
+		return healthy
+	}
+	address, ok := "", false
+	candidates := d.chainCandidates(chain)
+	for i := len(candidates) - 1; i >= 0 && !ok; i-- {
+		address, ok = d.balancer.pick(candidates[i], excluded, usable)
+	}
+	instance, healthy := d.pool.get(address)
+	if ok && !healthy {
+		// Became unhealthy since it has been picked
+		d.balancer.done(address, nil)
+	}
+	if !ok {
+		// The instances rejecting requests tell when to retry, the delay is passed on
+		if delay, busy := d.balancer.busyDelay(candidates[0]); busy {
+			return nil, fmt.Errorf("%w : %w", ErrNoTransformerAvailable, transformerv2.BusyError(fmt.Sprintf("every instance of service %v is busy", name), delay))
+		}
+	}
+	if !ok || !healthy {
+		return nil, fmt.Errorf("%w : no healthy instance of service %v found", ErrNoTransformerAvailable, name)
+	}
+
+	return &TransformerClient{
+		TransformerServiceClient: instance.clientV2,
+		V1:                       instance.client,
+		Address:                  address,
+		done:                     func(err error) { d.balancer.done(address, err) },
+	}, nil
+}
+
+// Instances serving the end of chain, the i-th set serves its last i+1 services. Sets are not
+// empty, they get smaller as more services are served.
+func (d *transformerDirectory) chainCandidates(chain []string) [][]string {
+	addresses := d.transformersAddressesList[chain[len(chain)-1]].servicesURLs
+	candidates := [][]string{addresses}
+	for i := len(chain) - 2; i >= 0; i-- {
+		instances := d.transformersAddressesList[chain[i]]
+		if instances == nil {
+			break
+		}
+
+		serving := map[string]bool{}
+		for _, address := range instances.servicesURLs {
+			serving[address] = true
+		}
+		narrowed := []string{}
+		for _, address := range addresses {
+			if serving[address] {
+				narrowed = append(narrowed, address)
+			}
+		}
+		if len(narrowed) == 0 {
+			break
+		}
+		addresses = narrowed
+		candidates = append(candidates, addresses)
+	}
+	return candidates
+}
+
+func (d *transformerDirectory) GetExistingServices() []models.TransformerService {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	existingServices := []models.TransformerService{}
+	for name, instances := range d.transformersAddressesList {
+		infos := instances.infos
+		existingServices = append(existingServices, *models.CreateTransformerService(name, infos.Version, infos.Description, infos.Schema, infos.PerViewer))
+	}
+	return existingServices
+}
+
+// Replace the known instances, connecting to the new ones and disconnecting from the others
+func (d *transformerDirectory) setTransformers(list map[string]*TransformersInstances) {
+	addresses := []string{}
+	for _, instances := range list {
+		addresses = append(addresses, instances.servicesURLs...)
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.closed {
+		return
+	}
+	d.transformersAddressesList = list
+	d.pool.update(addresses)
+	d.balancer.retain(addresses)
+}
+
+// Close the connections to every instance
+func (d *transformerDirectory) close() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.closed = true
+	d.transformersAddressesList = map[string]*TransformersInstances{}
+	d.pool.close()
+}
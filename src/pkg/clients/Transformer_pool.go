@@ -0,0 +1,160 @@
+package clients
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/rishirishhh/vought/src/pkg/transformer/v1"
+	transformerv2 "github.com/rishirishhh/vought/src/pkg/transformer/v2"
+)
+
+// Delay before watching again the health of an instance after the watch failed
+const healthRetryInterval time.Duration = 2 * time.Second
+
+// transformerPool holds one gRPC connection per transformer instance, shared by every request
+// sent to it. The health of every instance is watched with the gRPC health checking protocol.
+type transformerPool struct {
+	mutex     sync.RWMutex
+	instances map[string]*transformerInstance
+}
+
+type transformerInstance struct {
+	address string
+	conn    *grpc.ClientConn
+	client  transformer.TransformerServiceClient
+	// Instances which do not serve v2 yet are sent v1 requests
+	clientV2 transformerv2.TransformerServiceClient
+	healthy  atomic.Bool
+	cancel   context.CancelFunc
+}
+
+func newTransformerPool() *transformerPool {
+	return &transformerPool{instances: map[string]*transformerInstance{}}
+}
+
+// Open connections to the new instances and close the ones of the instances which are gone
+func (p *transformerPool) update(addresses []string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	current := map[string]bool{}
+	for _, address := range addresses {
+		current[address] = true
+		if _, ok := p.instances[address]; ok {
+			continue
+		}
+
+		instance, err := newTransformerInstance(address)
+		if err != nil {
+			log.Errorf("Cannot create gRPC connection to transformer %v : %v", address, err)
+			continue
+		}
+		p.instances[address] = instance
+	}
+
+	for address, instance := range p.instances {
+		if !current[address] {
+			instance.close()
+			delete(p.instances, address)
+		}
+	}
+}
+
+// Get the instance at address, ok is false if it is unknown or unhealthy
+func (p *transformerPool) get(address string) (*transformerInstance, bool) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	instance, ok := p.instances[address]
+	if !ok || !instance.healthy.Load() {
+		return nil, false
+	}
+	return instance, true
+}
+
+func (p *transformerPool) close() {
+	p.update(nil)
+}
+
+func newTransformerInstance(address string) (*transformerInstance, error) {
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	instance := &transformerInstance{
+		address:  address,
+		conn:     conn,
+		client:   transformer.NewTransformerServiceClient(conn),
+		clientV2: transformerv2.NewTransformerServiceClient(conn),
+		cancel:   cancel,
+	}
+	// Instances are trusted until their health is known
+	instance.healthy.Store(true)
+
+	go instance.watchHealth(ctx)
+	return instance, nil
+}
+
+func (i *transformerInstance) watchHealth(ctx context.Context) {
+	healthClient := healthpb.NewHealthClient(i.conn)
+
+	for {
+		err := i.recvHealth(ctx, healthClient)
+		if ctx.Err() != nil {
+			return
+		}
+		if status.Code(err) == codes.Unimplemented {
+			log.Warn("Transformer ", i.address, " does not implement health checking, it is considered healthy")
+			i.setHealthy(true)
+			return
+		}
+
+		log.Debug("Cannot watch health of transformer ", i.address, " : ", err)
+		i.setHealthy(false)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(healthRetryInterval):
+		}
+	}
+}
+
+func (i *transformerInstance) recvHealth(ctx context.Context, healthClient healthpb.HealthClient) error {
+	stream, err := healthClient.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return err
+	}
+
+	for {
+		res, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		i.setHealthy(res.GetStatus() == healthpb.HealthCheckResponse_SERVING)
+	}
+}
+
+func (i *transformerInstance) setHealthy(healthy bool) {
+	if i.healthy.Swap(healthy) != healthy {
+		log.Infof("Transformer %v is now healthy : %v", i.address, healthy)
+	}
+}
+
+func (i *transformerInstance) close() {
+	i.cancel()
+	if err := i.conn.Close(); err != nil {
+		log.Error("Cannot close gRPC connection to transformer ", i.address, " : ", err)
+	}
+}
@@ -0,0 +1,186 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/rishirishhh/vought/src/pkg/transformer/v1"
+	transformerv2 "github.com/rishirishhh/vought/src/pkg/transformer/v2"
+)
+
+// Instances a transformation is tried on before giving up
+const maxTransformAttempts int = 3
+
+var ErrNoTransformerAvailable = errors.New("no transformer instance available")
+
+// Format produced by the transformers serving v1 only
+var v1Format = &transformerv2.OutputFormat{Container: "mpegts", VideoCodec: "h264", AudioCodec: "copy"}
+
+// TransformerClient is a client to the transformer instance at Address. Done must be called once
+// the request sent with it is over.
+type TransformerClient struct {
+	transformerv2.TransformerServiceClient
+	V1      transformer.TransformerServiceClient
+	Address string
+	done    func(err error)
+	once    sync.Once
+}
+
+// Release the instance, err is the outcome of the request sent to it
+func (c *TransformerClient) Done(err error) {
+	c.once.Do(func() { c.done(err) })
+}
+
+// TransformStream is a TransformVideo stream on an instance picked by the service discovery
+type TransformStream struct {
+	stream responseStream
+	client *TransformerClient
+	// First response, received when the stream was opened
+	first    *transformerv2.TransformVideoResponse
+	firstErr error
+	// The transformed video part depends on the principal of the request
+	perViewer bool
+}
+
+type responseStream interface {
+	Recv() (*transformerv2.TransformVideoResponse, error)
+}
+
+// Open a TransformVideo stream on an instance of the transformer of the last step of the request,
+// preferably one running the previous steps too. Until the first response is received nothing has
+// been transformed, the request is then retried on other instances, busy instances included.
+// ErrNoTransformerAvailable is returned if no instance can be reached.
+func OpenTransformStream(ctx context.Context, discovery ServiceDiscovery, request *transformerv2.TransformVideoRequest) (*TransformStream, error) {
+	if len(request.GetSteps()) == 0 {
+		return nil, fmt.Errorf("no transformation step given")
+	}
+	chain := make([]string, 0, len(request.GetSteps()))
+	for _, step := range request.GetSteps() {
+		chain = append(chain, step.GetName())
+	}
+	name := chain[len(chain)-1]
+	tried := []string{}
+	var lastErr error
+
+	for attempt := 0; attempt < maxTransformAttempts; attempt++ {
+		client, err := discovery.GetTransformationClient(chain, tried...)
+		if err != nil {
+			if lastErr == nil {
+				return nil, err
+			}
+			break
+		}
+		tried = append(tried, client.Address)
+
+		stream, first, err := openResponseStream(ctx, client, request)
+		if err == nil || err == io.EOF {
+			return &TransformStream{stream: stream, client: client, first: first, firstErr: err, perViewer: first.GetPerViewer()}, nil
+		}
+
+		client.Done(err)
+		_, busy := transformerv2.RetryDelay(err)
+		if ctx.Err() != nil || !(isInstanceFailure(err) || busy) {
+			return nil, err
+		}
+		log.Warnf("Transformation on %v instance %v failed, %v attempts left : %v", name, client.Address, maxTransformAttempts-attempt-1, err)
+		lastErr = err
+	}
+
+	// The last error tells whether the instances are busy
+	return nil, fmt.Errorf("%w : %v : %w", ErrNoTransformerAvailable, name, lastErr)
+}
+
+// Send the request with v2, or with v1 if the instance does not serve v2 and the request does
+// not need it. The first response is received.
+func openResponseStream(ctx context.Context, client *TransformerClient, request *transformerv2.TransformVideoRequest) (responseStream, *transformerv2.TransformVideoResponse, error) {
+	var stream responseStream
+	stream, err := client.TransformVideo(ctx, request)
+	var first *transformerv2.TransformVideoResponse
+	if err == nil {
+		first, err = stream.Recv()
+	}
+
+	v1Request, compatible := toV1Request(request)
+	if status.Code(err) != codes.Unimplemented || !compatible {
+		return stream, first, err
+	}
+
+	log.Debug("Transformer ", client.Address, " does not serve v2, falling back to v1")
+	v1Stream, err := client.V1.TransformVideo(ctx, v1Request)
+	if err != nil {
+		return nil, nil, err
+	}
+	stream = &v1ResponseStream{stream: v1Stream, format: v1Format}
+	first, err = stream.Recv()
+	return stream, first, err
+}
+
+// Requests without parameters, accepting the format of v1, can be sent to v1 instances
+func toV1Request(request *transformerv2.TransformVideoRequest) (*transformer.TransformVideoRequest, bool) {
+	if _, err := transformerv2.NegotiateFormat([]*transformerv2.OutputFormat{v1Format}, request.GetAcceptedFormats()); err != nil {
+		return nil, false
+	}
+
+	names := make([]string, 0, len(request.GetSteps()))
+	for _, step := range request.GetSteps() {
+		if len(step.GetParameters()) > 0 {
+			return nil, false
+		}
+		names = append(names, step.GetName())
+	}
+	return &transformer.TransformVideoRequest{Videopath: request.GetVideopath(), TransformerList: names}, true
+}
+
+// v1ResponseStream converts the responses of v1 instances, the format is sent with the first one
+type v1ResponseStream struct {
+	stream transformer.TransformerService_TransformVideoClient
+	format *transformerv2.OutputFormat
+}
+
+func (s *v1ResponseStream) Recv() (*transformerv2.TransformVideoResponse, error) {
+	res, err := s.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	format := s.format
+	s.format = nil
+	return &transformerv2.TransformVideoResponse{Chunk: res.GetChunk(), Format: format}, nil
+}
+
+// Receive the next transformed chunk, io.EOF at the end of the stream
+func (s *TransformStream) Recv() (*transformerv2.TransformVideoResponse, error) {
+	var res *transformerv2.TransformVideoResponse
+	var err error
+	if s.first != nil || s.firstErr != nil {
+		res, err = s.first, s.firstErr
+		s.first, s.firstErr = nil, nil
+	} else {
+		res, err = s.stream.Recv()
+	}
+
+	if err == io.EOF {
+		s.client.Done(nil)
+	} else if err != nil {
+		s.client.Done(err)
+	}
+	return res, err
+}
+
+// Tell whether the transformed video part depends on the principal of the request, in which case
+// it must not be served to other viewers. It is told by the instance running the steps, whatever
+// the service discovery advertises.
+func (s *TransformStream) PerViewer() bool {
+	return s.perViewer
+}
+
+// Release the instance if the stream has not been read until its end
+func (s *TransformStream) Close() {
+	s.client.Done(nil)
+}
@@ -0,0 +1,212 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/rishirishhh/vought/src/cmd/api/models"
+	transformerv2 "github.com/rishirishhh/vought/src/pkg/transformer/v2"
+)
+
+// fakeInstance sends its responses, then ends its stream with err
+type fakeInstance struct {
+	address   string
+	responses []*transformerv2.TransformVideoResponse
+	err       error
+}
+
+// fakeDiscovery hands out its instances in order, and records the outcome of the requests
+type fakeDiscovery struct {
+	instances []fakeInstance
+	picked    []string
+	outcomes  map[string]error
+}
+
+func (d *fakeDiscovery) GetTransformationClient(chain []string, exclude ...string) (*TransformerClient, error) {
+	excluded := map[string]bool{}
+	for _, address := range exclude {
+		excluded[address] = true
+	}
+	for _, instance := range d.instances {
+		if excluded[instance.address] {
+			continue
+		}
+		address := instance.address
+		d.picked = append(d.picked, address)
+		return &TransformerClient{
+			TransformerServiceClient: fakeTransformerClient{instance: instance},
+			Address:                  address,
+			done:                     func(err error) { d.outcomes[address] = err },
+		}, nil
+	}
+	return nil, fmt.Errorf("%w : no instance left", ErrNoTransformerAvailable)
+}
+
+func (d *fakeDiscovery) GetExistingServices() []models.TransformerService { return nil }
+
+func (d *fakeDiscovery) StartServiceDiscovery(serviceInfos ServiceInfos) error { return nil }
+
+func (d *fakeDiscovery) Stop() {}
+
+type fakeTransformerClient struct {
+	transformerv2.TransformerServiceClient
+	instance fakeInstance
+}
+
+func (c fakeTransformerClient) TransformVideo(ctx context.Context, in *transformerv2.TransformVideoRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[transformerv2.TransformVideoResponse], error) {
+	return &fakeResponseStream{responses: c.instance.responses, err: c.instance.err}, nil
+}
+
+type fakeResponseStream struct {
+	grpc.ClientStream
+	responses []*transformerv2.TransformVideoResponse
+	err       error
+}
+
+func (s *fakeResponseStream) Recv() (*transformerv2.TransformVideoResponse, error) {
+	if len(s.responses) == 0 {
+		if s.err != nil {
+			return nil, s.err
+		}
+		return nil, io.EOF
+	}
+	res := s.responses[0]
+	s.responses = s.responses[1:]
+	return res, nil
+}
+
+func Test_OpenTransformStream(t *testing.T) {
+	part := []*transformerv2.TransformVideoResponse{{Chunk: []byte("trans"), Format: v1Format}, {Chunk: []byte("formed")}}
+	unavailable := status.Error(codes.Unavailable, "down")
+	busy := transformerv2.BusyError("busy", 2*time.Second)
+
+	cases := []struct {
+		Name              string
+		GivenInstances    []fakeInstance
+		ExpectedPicked    []string
+		ExpectedOutcomes  map[string]codes.Code
+		ExpectedPart      string
+		ExpectedPerViewer bool
+		ExpectedCode      codes.Code
+		ExpectedNoneLeft  bool
+		ExpectedBusy      bool
+	}{
+		{
+			Name:             "First instance",
+			GivenInstances:   []fakeInstance{{address: "a:1", responses: part}, {address: "b:1", responses: part}},
+			ExpectedPicked:   []string{"a:1"},
+			ExpectedOutcomes: map[string]codes.Code{"a:1": codes.OK},
+			ExpectedPart:     "transformed",
+		},
+		{
+			Name:             "Failing instance retried",
+			GivenInstances:   []fakeInstance{{address: "a:1", err: unavailable}, {address: "b:1", responses: part}},
+			ExpectedPicked:   []string{"a:1", "b:1"},
+			ExpectedOutcomes: map[string]codes.Code{"a:1": codes.Unavailable, "b:1": codes.OK},
+			ExpectedPart:     "transformed",
+		},
+		{
+			Name:             "Busy instance retried",
+			GivenInstances:   []fakeInstance{{address: "a:1", err: busy}, {address: "b:1", responses: part}},
+			ExpectedPicked:   []string{"a:1", "b:1"},
+			ExpectedOutcomes: map[string]codes.Code{"a:1": codes.ResourceExhausted, "b:1": codes.OK},
+			ExpectedPart:     "transformed",
+		},
+		{
+			Name:              "Per viewer part",
+			GivenInstances:    []fakeInstance{{address: "a:1", responses: []*transformerv2.TransformVideoResponse{{Chunk: []byte("marked"), PerViewer: true}}}},
+			ExpectedPicked:    []string{"a:1"},
+			ExpectedOutcomes:  map[string]codes.Code{"a:1": codes.OK},
+			ExpectedPart:      "marked",
+			ExpectedPerViewer: true,
+		},
+		{
+			Name:             "Empty part",
+			GivenInstances:   []fakeInstance{{address: "a:1"}},
+			ExpectedPicked:   []string{"a:1"},
+			ExpectedOutcomes: map[string]codes.Code{"a:1": codes.OK},
+		},
+		{
+			Name:             "Invalid request not retried",
+			GivenInstances:   []fakeInstance{{address: "a:1", err: status.Error(codes.InvalidArgument, "invalid")}, {address: "b:1", responses: part}},
+			ExpectedPicked:   []string{"a:1"},
+			ExpectedOutcomes: map[string]codes.Code{"a:1": codes.InvalidArgument},
+			ExpectedCode:     codes.InvalidArgument,
+		},
+		{
+			Name:             "Busy next transformers not retried",
+			GivenInstances:   []fakeInstance{{address: "a:1", err: transformerv2.NextBusyError("next busy", time.Second)}, {address: "b:1", responses: part}},
+			ExpectedPicked:   []string{"a:1"},
+			ExpectedOutcomes: map[string]codes.Code{"a:1": codes.Unavailable},
+			ExpectedCode:     codes.Unavailable,
+		},
+		{
+			Name:             "Every attempt failing",
+			GivenInstances:   []fakeInstance{{address: "a:1", err: unavailable}, {address: "b:1", err: unavailable}, {address: "c:1", err: unavailable}, {address: "d:1", responses: part}},
+			ExpectedPicked:   []string{"a:1", "b:1", "c:1"},
+			ExpectedOutcomes: map[string]codes.Code{"a:1": codes.Unavailable, "b:1": codes.Unavailable, "c:1": codes.Unavailable},
+			ExpectedCode:     codes.Unavailable,
+			ExpectedNoneLeft: true,
+		},
+		{
+			Name:             "Every instance busy",
+			GivenInstances:   []fakeInstance{{address: "a:1", err: busy}, {address: "b:1", err: busy}},
+			ExpectedPicked:   []string{"a:1", "b:1"},
+			ExpectedOutcomes: map[string]codes.Code{"a:1": codes.ResourceExhausted, "b:1": codes.ResourceExhausted},
+			ExpectedCode:     codes.ResourceExhausted,
+			ExpectedNoneLeft: true,
+			ExpectedBusy:     true,
+		},
+		{
+			Name:             "No instance",
+			ExpectedOutcomes: map[string]codes.Code{},
+			ExpectedCode:     codes.Unknown,
+			ExpectedNoneLeft: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			discovery := &fakeDiscovery{instances: tt.GivenInstances, outcomes: map[string]error{}}
+			request := &transformerv2.TransformVideoRequest{Videopath: "id/v0/segment0.ts", Steps: []*transformerv2.Step{{Name: "gray"}}}
+
+			stream, err := OpenTransformStream(context.Background(), discovery, request)
+			if tt.ExpectedCode != codes.OK {
+				require.Error(t, err)
+				require.Equal(t, tt.ExpectedCode, status.Code(err))
+				require.Equal(t, tt.ExpectedNoneLeft, errors.Is(err, ErrNoTransformerAvailable))
+				_, isBusy := transformerv2.RetryDelay(err)
+				require.Equal(t, tt.ExpectedBusy, isBusy)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.ExpectedPerViewer, stream.PerViewer())
+				transformed := ""
+				for {
+					res, err := stream.Recv()
+					if err == io.EOF {
+						break
+					}
+					require.NoError(t, err)
+					transformed += string(res.GetChunk())
+				}
+				require.Equal(t, tt.ExpectedPart, transformed)
+			}
+
+			require.Equal(t, tt.ExpectedPicked, discovery.picked)
+			outcomes := map[string]codes.Code{}
+			for address, err := range discovery.outcomes {
+				outcomes[address] = status.Code(err)
+			}
+			require.Equal(t, tt.ExpectedOutcomes, outcomes)
+		})
+	}
+}
@@ -86,11 +86,13 @@ func (Video_VideoStatus) EnumDescriptor() ([]byte, []int) {
 }
 
 type Video struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Status        Video_VideoStatus      `protobuf:"varint,2,opt,name=status,proto3,enum=pkg.contracts.v1.Video_VideoStatus" json:"status,omitempty"`
-	Source        string                 `protobuf:"bytes,3,opt,name=source,proto3" json:"source,omitempty"`
-	CoverPath     string                 `protobuf:"bytes,4,opt,name=cover_path,json=coverPath,proto3" json:"cover_path,omitempty"`
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Id        string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Status    Video_VideoStatus      `protobuf:"varint,2,opt,name=status,proto3,enum=pkg.contracts.v1.Video_VideoStatus" json:"status,omitempty"`
+	Source    string                 `protobuf:"bytes,3,opt,name=source,proto3" json:"source,omitempty"`
+	CoverPath string                 `protobuf:"bytes,4,opt,name=cover_path,json=coverPath,proto3" json:"cover_path,omitempty"`
+	// Why the video could not be uploaded or encoded, set along with a FAIL_* status
+	FailureReason string `protobuf:"bytes,5,opt,name=failure_reason,json=failureReason,proto3" json:"failure_reason,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -153,17 +155,25 @@ func (x *Video) GetCoverPath() string {
 	return ""
 }
 
+func (x *Video) GetFailureReason() string {
+	if x != nil {
+		return x.FailureReason
+	}
+	return ""
+}
+
 var File_src_pkg_contracts_v1_video_proto protoreflect.FileDescriptor
 
 const file_src_pkg_contracts_v1_video_proto_rawDesc = "" +
 	"\n" +
-	" src/pkg/contracts/v1/video.proto\x12\x10pkg.contracts.v1\"\xfc\x02\n" +
+	" src/pkg/contracts/v1/video.proto\x12\x10pkg.contracts.v1\"\xa3\x03\n" +
 	"\x05Video\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12;\n" +
 	"\x06status\x18\x02 \x01(\x0e2#.pkg.contracts.v1.Video.VideoStatusR\x06status\x12\x16\n" +
 	"\x06source\x18\x03 \x01(\tR\x06source\x12\x1d\n" +
 	"\n" +
-	"cover_path\x18\x04 \x01(\tR\tcoverPath\"\xee\x01\n" +
+	"cover_path\x18\x04 \x01(\tR\tcoverPath\x12%\n" +
+	"\x0efailure_reason\x18\x05 \x01(\tR\rfailureReason\"\xee\x01\n" +
 	"\vVideoStatus\x12\x1c\n" +
 	"\x18VIDEO_STATUS_UNSPECIFIED\x10\x00\x12\x1a\n" +
 	"\x16VIDEO_STATUS_UPLOADING\x10\x01\x12\x19\n" +
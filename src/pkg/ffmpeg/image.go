@@ -1,11 +1,30 @@
 package ffmpeg
 
 import (
+	"context"
+	"fmt"
+	"os"
 	"os/exec"
+	"strconv"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	// Poster frames are searched after this ratio of the video, to avoid intros and fade-in
+	posterOffsetRatio float64 = 0.1
+	// Maximum number of seconds scanned to pick a poster frame
+	posterScanDuration int = 30
+)
+
+// Image formats supported for frame extraction, with their encoder
+var frameCodecs = map[string]string{
+	"jpeg": "mjpeg",
+	"png":  "png",
+	"webp": "libwebp",
+}
+
 func ConvertImg(srcpath string, dstpath string) error {
 	_, err := exec.Command("ffmpeg", "-i", srcpath, "-vf",
 		"crop='if(gt(iw, ih), ih, iw)':'if(gt(iw, ih), ih, iw )':'if(gt(iw, ih), (iw-ih)/2, 0)':'if(gt(iw,ih), 0, (ih-iw)/2)', scale=250:250",
@@ -18,3 +37,91 @@ func ConvertImg(srcpath string, dstpath string) error {
 
 	return nil
 }
+
+// Extract a representative frame of the video, skipping black or near-uniform frames
+func ExtractPosterFrame(srcpath string, dstpath string, duration float64) error {
+	offset := posterOffset(duration)
+
+	cmd, args := generatePosterCommand(srcpath, dstpath, offset)
+	log.Debug("FFMPEG command: ", cmd, strings.Join(args, " "))
+	rawOutput, err := exec.Command(cmd, args...).CombinedOutput()
+	log.Debug("FFMPEG output: ", string(rawOutput[:]))
+
+	// Every scanned frame may have been filtered out (dark or static video), take the frame at offset instead
+	if _, statErr := os.Stat(dstpath); err != nil || statErr != nil {
+		log.Debug("No representative frame found, using frame at ", offset, "s")
+		rawOutput, err = exec.Command("ffmpeg", "-y", "-ss", formatSeconds(offset), "-i", srcpath, "-frames:v", "1", dstpath).CombinedOutput()
+		if err != nil {
+			log.Debug("FFMPEG output: ", string(rawOutput[:]))
+			return err
+		}
+	}
+	log.Debug("Poster frame extracted")
+
+	return nil
+}
+
+func generatePosterCommand(srcpath string, dstpath string, offset float64) (string, []string) {
+	// ffmpeg -y -ss <offset> -t 30 -i <srcpath> \
+	//              -vf "entropy,metadata=select:key=lavfi.entropy.normalized_entropy.normal.Y:value=0.4:function=greater,
+	//                   blackframe=amount=0:threshold=32,metadata=select:key=lavfi.blackframe.pblack:value=90:function=less,
+	//                   thumbnail=100" \
+	//              -frames:v 1 <dstpath>
+	filters := []string{
+		// Drop near-uniform frames (low luma entropy)
+		"entropy",
+		"metadata=select:key=lavfi.entropy.normalized_entropy.normal.Y:value=0.4:function=greater",
+		// Drop frames that are mostly black. blackframe only tags frames whose black ratio reaches amount,
+		// so amount=0 is needed for every frame to carry pblack and survive the selection below
+		"blackframe=amount=0:threshold=32",
+		"metadata=select:key=lavfi.blackframe.pblack:value=90:function=less",
+		// Keep the most representative of the remaining frames
+		"thumbnail=100",
+	}
+
+	command := "ffmpeg"
+	args := []string{"-y", "-ss", formatSeconds(offset), "-t", strconv.Itoa(posterScanDuration), "-i", srcpath}
+	args = append(args, "-vf", strings.Join(filters, ","))
+	args = append(args, "-frames:v", "1", dstpath)
+
+	return command, args
+}
+
+// Create the command extracting the frame at offset (in seconds) of a HLS part read from stdin.
+// A width of 0 keeps the source width.
+func CreateFrameCommand(ctx context.Context, offset float64, width uint32, format string) (*exec.Cmd, error) {
+	command, args, err := generateFrameCommand(offset, width, format)
+	if err != nil {
+		return nil, err
+	}
+
+	return exec.CommandContext(ctx, command, args...), nil
+}
+
+func generateFrameCommand(offset float64, width uint32, format string) (string, []string, error) {
+	// ffmpeg -i pipe:0 -ss <offset> -frames:v 1 -vf scale=<width>:-2 -c:v <codec> -f image2pipe pipe:1
+	codec, ok := frameCodecs[format]
+	if !ok {
+		return "", nil, fmt.Errorf("unsupported frame format %v", format)
+	}
+
+	command := "ffmpeg"
+	args := []string{"-i", "pipe:0", "-ss", formatSeconds(offset), "-frames:v", "1"}
+	if width > 0 {
+		args = append(args, "-vf", fmt.Sprintf("scale=%d:-2", width))
+	}
+	args = append(args, "-c:v", codec, "-f", "image2pipe", "pipe:1")
+
+	return command, args, nil
+}
+
+func posterOffset(duration float64) float64 {
+	if duration <= 0 {
+		return 0
+	}
+	return duration * posterOffsetRatio
+}
+
+func formatSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', 3, 64)
+}
@@ -0,0 +1,104 @@
+package ffmpeg
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GeneratePosterCommand(t *testing.T) {
+	cases := []struct {
+		Name          string
+		GivenSrcPath  string
+		GivenDstPath  string
+		GivenDuration float64
+		ExpectCommand string
+		ExpectArgs    string
+	}{
+		{
+			Name:          "Unknown duration",
+			GivenSrcPath:  "source.mp4",
+			GivenDstPath:  "poster.png",
+			GivenDuration: 0,
+			ExpectCommand: "ffmpeg",
+			ExpectArgs:    "-y -ss 0.000 -t 30 -i source.mp4 -vf entropy,metadata=select:key=lavfi.entropy.normalized_entropy.normal.Y:value=0.4:function=greater,blackframe=amount=0:threshold=32,metadata=select:key=lavfi.blackframe.pblack:value=90:function=less,thumbnail=100 -frames:v 1 poster.png",
+		},
+		{
+			Name:          "Two minutes video",
+			GivenSrcPath:  "source.mp4",
+			GivenDstPath:  "poster.png",
+			GivenDuration: 120,
+			ExpectCommand: "ffmpeg",
+			ExpectArgs:    "-y -ss 12.000 -t 30 -i source.mp4 -vf entropy,metadata=select:key=lavfi.entropy.normalized_entropy.normal.Y:value=0.4:function=greater,blackframe=amount=0:threshold=32,metadata=select:key=lavfi.blackframe.pblack:value=90:function=less,thumbnail=100 -frames:v 1 poster.png",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			cmd, args := generatePosterCommand(tt.GivenSrcPath, tt.GivenDstPath, posterOffset(tt.GivenDuration))
+
+			require.Equal(t, tt.ExpectCommand, cmd)
+			require.Equal(t, tt.ExpectArgs, strings.Join(args, " "))
+		})
+	}
+}
+
+func Test_GeneratePosterCommandSkipsBlackLeadIn(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg is not available")
+	}
+
+	dir := t.TempDir()
+	srcpath := filepath.Join(dir, "source.mkv")
+	dstpath := filepath.Join(dir, "poster.png")
+
+	// 3 seconds of black followed by 3 seconds of test pattern
+	output, err := exec.Command("ffmpeg", "-y",
+		"-f", "lavfi", "-i", "color=black:s=160x120:r=25:d=3",
+		"-f", "lavfi", "-i", "testsrc=s=160x120:r=25:d=3",
+		"-filter_complex", "[0:v][1:v]concat=n=2:v=1[v]", "-map", "[v]", srcpath).CombinedOutput()
+	require.NoError(t, err, string(output))
+
+	cmd, args := generatePosterCommand(srcpath, dstpath, 0)
+	output, err = exec.Command(cmd, args...).CombinedOutput()
+	require.NoError(t, err, string(output))
+	require.FileExists(t, dstpath)
+
+	// Average luma of the poster, the black lead-in must have been skipped
+	luma, err := exec.Command("ffmpeg", "-i", dstpath, "-vf", "scale=1:1,format=gray", "-f", "rawvideo", "pipe:1").Output()
+	require.NoError(t, err)
+	require.Len(t, luma, 1)
+	require.Greater(t, luma[0], byte(32))
+}
+
+func Test_GenerateFrameCommand(t *testing.T) {
+	cases := []struct {
+		Name        string
+		GivenOffset float64
+		GivenWidth  uint32
+		GivenFormat string
+		ExpectArgs  string
+		ExpectError bool
+	}{
+		{Name: "Jpeg with source width", GivenOffset: 2.5, GivenWidth: 0, GivenFormat: "jpeg", ExpectArgs: "-i pipe:0 -ss 2.500 -frames:v 1 -c:v mjpeg -f image2pipe pipe:1"},
+		{Name: "Webp scaled", GivenOffset: 0, GivenWidth: 640, GivenFormat: "webp", ExpectArgs: "-i pipe:0 -ss 0.000 -frames:v 1 -vf scale=640:-2 -c:v libwebp -f image2pipe pipe:1"},
+		{Name: "Unsupported format", GivenOffset: 0, GivenWidth: 640, GivenFormat: "gif", ExpectError: true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			cmd, args, err := generateFrameCommand(tt.GivenOffset, tt.GivenWidth, tt.GivenFormat)
+			if tt.ExpectError {
+				require.NotNil(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			require.Equal(t, "ffmpeg", cmd)
+			require.Equal(t, tt.ExpectArgs, strings.Join(args, " "))
+		})
+	}
+}
@@ -49,3 +49,14 @@ func ExtractResolution(filepath string) (resolution, error) {
 
 	return resolution{x, y}, nil
 }
+
+// Extract duration of the video in seconds
+func ExtractDuration(filepath string) (float64, error) {
+	// ffprobe -v error -show_entries format=duration -of default=noprint_wrappers=1:nokey=1 <filepath>
+	rawOutput, err := exec.Command("ffprobe", "-v", "error", "-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", filepath).Output()
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseFloat(strings.TrimSpace(string(rawOutput[:])), 64)
+}
@@ -0,0 +1,88 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PreviewDir          string  = "previews"
+	previewClips        int     = 4
+	previewClipDuration float64 = 1.5
+	previewWidth        int     = 320
+	previewFps          int     = 12
+)
+
+// Formats of the animated previews, with the encoding arguments
+var previewFormats = map[string][]string{
+	"mp4":  {"-c:v", "libx264", "-pix_fmt", "yuv420p", "-movflags", "+faststart"},
+	"webp": {"-c:v", "libwebp", "-loop", "0", "-q:v", "60"},
+}
+
+// Generate short silent looping previews made of clips sampled from several points of the video.
+// Return the path of the generated previews.
+func GeneratePreviews(source string, duration float64) ([]string, error) {
+	if err := os.MkdirAll(PreviewDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	starts := previewClipStarts(duration)
+	previews := []string{}
+	for _, format := range []string{"mp4", "webp"} {
+		dstpath := filepath.Join(PreviewDir, "preview."+format)
+		cmd, args := generatePreviewCommand(source, dstpath, starts, format)
+		log.Debug("FFMPEG command: ", cmd, strings.Join(args, " "))
+		rawOutput, err := exec.Command(cmd, args...).CombinedOutput()
+		log.Debug("FFMPEG output: ", string(rawOutput[:]))
+		if err != nil {
+			return nil, err
+		}
+		previews = append(previews, dstpath)
+	}
+
+	return previews, nil
+}
+
+func previewClipStarts(duration float64) []float64 {
+	// Too short video, the preview is the beginning of the video
+	if duration <= float64(previewClips)*previewClipDuration {
+		return []float64{0}
+	}
+
+	// Clips are centered on evenly spaced points of the video
+	starts := make([]float64, previewClips)
+	for i := range starts {
+		center := duration * float64(i+1) / float64(previewClips+1)
+		starts[i] = math.Max(0, center-previewClipDuration/2)
+	}
+	return starts
+}
+
+func generatePreviewCommand(source string, dstpath string, starts []float64, format string) (string, []string) {
+	// ffmpeg -y -i <source> \
+	//              -vf "fps=12,select='between(t,<start>,<end>)+...',setpts=N/12/TB,scale=320:-2" \
+	//              -an <format args> <dstpath>
+	clips := make([]string, len(starts))
+	for i, start := range starts {
+		clips[i] = fmt.Sprintf("between(t,%v,%v)", formatSeconds(start), formatSeconds(start+previewClipDuration))
+	}
+	filters := []string{
+		fmt.Sprintf("fps=%d", previewFps),
+		fmt.Sprintf("select='%s'", strings.Join(clips, "+")),
+		fmt.Sprintf("setpts=N/%d/TB", previewFps),
+		fmt.Sprintf("scale=%d:-2", previewWidth),
+	}
+
+	command := "ffmpeg"
+	args := []string{"-y", "-i", source, "-vf", strings.Join(filters, ","), "-an"}
+	args = append(args, previewFormats[format]...)
+	args = append(args, dstpath)
+
+	return command, args
+}
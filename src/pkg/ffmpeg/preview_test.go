@@ -0,0 +1,20 @@
+package ffmpeg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PreviewClipStarts(t *testing.T) {
+	require.Equal(t, []float64{0}, previewClipStarts(5))
+	require.Equal(t, []float64{11.25, 23.25, 35.25, 47.25}, previewClipStarts(60))
+}
+
+func Test_GeneratePreviewCommand(t *testing.T) {
+	cmd, args := generatePreviewCommand("source.mp4", "previews/preview.webp", []float64{11.25, 23.25}, "webp")
+
+	require.Equal(t, "ffmpeg", cmd)
+	require.Equal(t, "-y -i source.mp4 -vf fps=12,select='between(t,11.250,12.750)+between(t,23.250,24.750)',setpts=N/12/TB,scale=320:-2 -an -c:v libwebp -loop 0 -q:v 60 previews/preview.webp", strings.Join(args, " "))
+}
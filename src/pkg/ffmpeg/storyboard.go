@@ -0,0 +1,117 @@
+package ffmpeg
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	StoryboardDir      string = "storyboard"
+	StoryboardVTT      string = "storyboard.vtt"
+	storyboardTileW    uint64 = 160
+	storyboardColumns  int    = 5
+	storyboardRows     int    = 5
+	storyboardSpriteFn string = "sprite%d.jpeg"
+)
+
+// Generate tiled sprite sheets with a thumbnail every interval seconds, and the WebVTT
+// storyboard mapping each time range to the thumbnail coordinates in the sprites
+func GenerateStoryboard(source string, res resolution, duration float64, interval uint32) error {
+	if interval == 0 {
+		return errors.New("storyboard interval must be greater than 0")
+	}
+	if res.x == 0 || res.y == 0 {
+		return fmt.Errorf("invalid resolution (%d,%d) for storyboard", res.x, res.y)
+	}
+
+	if err := os.MkdirAll(StoryboardDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	tileW, tileH := storyboardTileSize(res)
+	cmd, args := generateStoryboardCommand(source, tileW, tileH, interval)
+	log.Debug("FFMPEG command: ", cmd, strings.Join(args, " "))
+	rawOutput, err := exec.Command(cmd, args...).CombinedOutput()
+	log.Debug("FFMPEG output: ", string(rawOutput[:]))
+	if err != nil {
+		return err
+	}
+
+	// fps may output one thumbnail more or less than the duration gives, the cues are the
+	// thumbnails actually tiled
+	thumbnails := countShowinfoFrames(string(rawOutput))
+	if thumbnails == 0 {
+		return errors.New("no storyboard thumbnail generated")
+	}
+
+	vtt := generateStoryboardVTT(duration, thumbnails, interval, tileW, tileH)
+	return os.WriteFile(StoryboardVTT, []byte(vtt), 0644) //nolint:gosec
+}
+
+func storyboardTileSize(res resolution) (uint64, uint64) {
+	// Keep the aspect ratio, with an even height as required by the encoder
+	tileH := uint64(math.Round(float64(storyboardTileW)*float64(res.y)/float64(res.x)/2)) * 2
+	if tileH == 0 {
+		tileH = 2
+	}
+	return storyboardTileW, tileH
+}
+
+func generateStoryboardCommand(source string, tileW, tileH uint64, interval uint32) (string, []string) {
+	// ffmpeg -y -i <source> -vf fps=1/<interval>,scale=<tileW>:<tileH>,showinfo,tile=5x5 -q:v 5 -start_number 0 storyboard/sprite%d.jpeg
+	// showinfo logs every thumbnail before it is tiled
+	command := "ffmpeg"
+	args := []string{"-y", "-i", source}
+	args = append(args, "-vf", fmt.Sprintf("fps=1/%d,scale=%d:%d,showinfo,tile=%dx%d", interval, tileW, tileH, storyboardColumns, storyboardRows))
+	args = append(args, "-q:v", "5", "-start_number", "0", filepath.Join(StoryboardDir, storyboardSpriteFn))
+
+	return command, args
+}
+
+// Count the frames logged by the showinfo filter, e.g.
+// [Parsed_showinfo_2 @ 0x5581c0e0] n:   3 pts:     15 pts_time:15 ...
+func countShowinfoFrames(output string) int {
+	frames := 0
+	for _, line := range strings.Split(output, "\n") {
+		if strings.Contains(line, "Parsed_showinfo") && strings.Contains(line, "] n:") {
+			frames++
+		}
+	}
+	return frames
+}
+
+func generateStoryboardVTT(duration float64, thumbnails int, interval uint32, tileW, tileH uint64) string {
+	var vtt strings.Builder
+	vtt.WriteString("WEBVTT\n")
+
+	tilesPerSprite := storyboardColumns * storyboardRows
+	for i := 0; i < thumbnails; i++ {
+		start := float64(i) * float64(interval)
+		end := math.Min(start+float64(interval), duration)
+		if end <= start {
+			// Thumbnail past the duration read from the metadata
+			end = start + float64(interval)
+		}
+
+		sprite := fmt.Sprintf(storyboardSpriteFn, i/tilesPerSprite)
+		x := uint64((i%tilesPerSprite)%storyboardColumns) * tileW
+		y := uint64((i%tilesPerSprite)/storyboardColumns) * tileH
+
+		fmt.Fprintf(&vtt, "\n%s --> %s\n", formatVTTTimestamp(start), formatVTTTimestamp(end))
+		fmt.Fprintf(&vtt, "%s/%s#xywh=%d,%d,%d,%d\n", StoryboardDir, sprite, x, y, tileW, tileH)
+	}
+
+	return vtt.String()
+}
+
+func formatVTTTimestamp(seconds float64) string {
+	millis := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", millis/3600000, (millis/60000)%60, (millis/1000)%60, millis%1000)
+}
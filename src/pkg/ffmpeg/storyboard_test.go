@@ -0,0 +1,76 @@
+package ffmpeg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GenerateStoryboardCommand(t *testing.T) {
+	cases := []struct {
+		Name            string
+		GivenResolution resolution
+		GivenInterval   uint32
+		ExpectArgs      string
+	}{
+		{
+			Name:            "16/9 video every 5 seconds",
+			GivenResolution: resolution{1280, 720},
+			GivenInterval:   5,
+			ExpectArgs:      "-y -i source.mp4 -vf fps=1/5,scale=160:90,showinfo,tile=5x5 -q:v 5 -start_number 0 storyboard/sprite%d.jpeg",
+		},
+		{
+			Name:            "4/3 video every 10 seconds",
+			GivenResolution: resolution{640, 480},
+			GivenInterval:   10,
+			ExpectArgs:      "-y -i source.mp4 -vf fps=1/10,scale=160:120,showinfo,tile=5x5 -q:v 5 -start_number 0 storyboard/sprite%d.jpeg",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			tileW, tileH := storyboardTileSize(tt.GivenResolution)
+			cmd, args := generateStoryboardCommand("source.mp4", tileW, tileH, tt.GivenInterval)
+
+			require.Equal(t, "ffmpeg", cmd)
+			require.Equal(t, tt.ExpectArgs, strings.Join(args, " "))
+		})
+	}
+}
+
+func Test_GenerateStoryboardVTT(t *testing.T) {
+	vtt := generateStoryboardVTT(132.5, 27, 5, 160, 90)
+	cues := strings.Split(vtt, "\n\n")
+
+	// Header + one cue every 5 seconds
+	require.Equal(t, "WEBVTT", cues[0])
+	require.Len(t, cues[1:], 27)
+
+	require.Equal(t, "00:00:00.000 --> 00:00:05.000\nstoryboard/sprite0.jpeg#xywh=0,0,160,90", cues[1])
+	require.Equal(t, "00:00:35.000 --> 00:00:40.000\nstoryboard/sprite0.jpeg#xywh=320,90,160,90", cues[8])
+	require.Equal(t, "00:02:05.000 --> 00:02:10.000\nstoryboard/sprite1.jpeg#xywh=0,0,160,90", cues[26])
+	require.Equal(t, "00:02:10.000 --> 00:02:12.500\nstoryboard/sprite1.jpeg#xywh=160,0,160,90\n", cues[27])
+}
+
+func Test_GenerateStoryboardVTTExtraThumbnail(t *testing.T) {
+	// fps output a thumbnail at the very end of the video
+	vtt := generateStoryboardVTT(10, 3, 5, 160, 90)
+	cues := strings.Split(vtt, "\n\n")
+
+	require.Len(t, cues[1:], 3)
+	require.Equal(t, "00:00:10.000 --> 00:00:15.000\nstoryboard/sprite0.jpeg#xywh=320,0,160,90\n", cues[3])
+}
+
+func Test_CountShowinfoFrames(t *testing.T) {
+	output := `Input #0, matroska,webm, from 'source.mkv':
+[Parsed_showinfo_2 @ 0x5581c0e0] config in time_base: 1/25, frame_rate: 1/5
+[Parsed_showinfo_2 @ 0x5581c0e0] n:   0 pts:      0 pts_time:0       duration:      1 fmt:yuv420p
+[Parsed_showinfo_2 @ 0x5581c0e0] color_range:tv color_space:unknown
+[Parsed_showinfo_2 @ 0x5581c0e0] n:   1 pts:      1 pts_time:5       duration:      1 fmt:yuv420p
+[Parsed_showinfo_2 @ 0x5581c0e0] n:   2 pts:      2 pts_time:10      duration:      1 fmt:yuv420p
+frame=    1 fps=0.0 q=5.0 Lsize=N/A time=00:00:15.00 bitrate=N/A speed= 150x`
+
+	require.Equal(t, 3, countShowinfoFrames(output))
+	require.Equal(t, 0, countShowinfoFrames(""))
+}
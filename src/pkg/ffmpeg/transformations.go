@@ -6,25 +6,55 @@ import (
 	"os/exec"
 )
 
-func CreateFlipCommand(ctx context.Context) *exec.Cmd {
-	// Create Command
-	command := "ffmpeg"
-	args := []string{"-i", "pipe:0"}
-	args = append(args, "-f", "mpegts", "-muxdelay", "0", "-map", "0:0", "-map", "0:1", "-acodec", "copy")
-	args = append(args, "-vcodec", "libx264", "-preset", "fastlibx264", "-preset", "superfast", "-copyts")
-	args = append(args, "-vf", "vflip")
-	args = append(args, "pipe:1")
+// TransformOutput is the format of the video parts produced by the transformation commands
+type TransformOutput struct {
+	Container  string
+	VideoCodec string
+	AudioCodec string
+}
 
-	return exec.CommandContext(ctx, command, args...)
+// Formats the transformation commands can produce, the first one is the default
+var TransformOutputs = []TransformOutput{
+	{Container: "mpegts", VideoCodec: "h264", AudioCodec: "copy"},
+	{Container: "mpegts", VideoCodec: "h264", AudioCodec: "aac"},
+	{Container: "mpegts", VideoCodec: "hevc", AudioCodec: "copy"},
+	{Container: "mpegts", VideoCodec: "hevc", AudioCodec: "aac"},
 }
 
-func CreateGrayCommand(ctx context.Context) *exec.Cmd {
+// Codec of the video parts made by the HLS encoder, they are copied when only their audio is filtered
+const partVideoCodec = "h264"
+
+var videoEncoders = map[string]string{
+	"h264": "libx264",
+	"hevc": "libx265",
+}
+
+// FilterGraphs are the filter graphs applied to the streams of a video part, a stream whose graph
+// is empty is left unchanged
+type FilterGraphs struct {
+	Video string
+	Audio string
+}
+
+// CreateFilterCommand creates the command applying the filter graphs to a video part. The audio
+// stream cannot be copied when it is filtered, output must encode it. The video stream is copied
+// when it is not filtered and output keeps its codec.
+func CreateFilterCommand(ctx context.Context, graphs FilterGraphs, output TransformOutput) *exec.Cmd {
 	// Create command
 	command := "ffmpeg"
 	args := []string{"-i", "pipe:0"}
-	args = append(args, "-f", "mpegts", "-muxdelay", "0", "-map", "0:0", "-map", "0:1", "-acodec", "copy")
-	args = append(args, "-vcodec", "libx264", "-preset", "fastlibx264", "-preset", "superfast", "-copyts")
-	args = append(args, "-vf", "hue=s=0")
+	args = append(args, "-f", output.Container, "-muxdelay", "0", "-map", "0:0", "-map", "0:1", "-acodec", output.AudioCodec)
+	if graphs.Video == "" && output.VideoCodec == partVideoCodec {
+		args = append(args, "-vcodec", "copy", "-copyts")
+	} else {
+		args = append(args, "-vcodec", videoEncoders[output.VideoCodec], "-preset", "superfast", "-copyts")
+	}
+	if graphs.Video != "" {
+		args = append(args, "-vf", graphs.Video)
+	}
+	if graphs.Audio != "" {
+		args = append(args, "-af", graphs.Audio)
+	}
 	args = append(args, "pipe:1")
 	return exec.CommandContext(ctx, command, args...)
 }
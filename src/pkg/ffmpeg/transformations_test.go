@@ -0,0 +1,44 @@
+package ffmpeg
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CreateFilterCommand(t *testing.T) {
+	cases := []struct {
+		Name        string
+		GivenGraphs FilterGraphs
+		GivenOutput TransformOutput
+		ExpectArgs  string
+	}{
+		{
+			Name:        "Video filtered",
+			GivenGraphs: FilterGraphs{Video: "hue=s=0"},
+			GivenOutput: TransformOutputs[0],
+			ExpectArgs:  "ffmpeg -i pipe:0 -f mpegts -muxdelay 0 -map 0:0 -map 0:1 -acodec copy -vcodec libx264 -preset superfast -copyts -vf hue=s=0 pipe:1",
+		},
+		{
+			Name:        "Audio filtered only",
+			GivenGraphs: FilterGraphs{Audio: "volume=2"},
+			GivenOutput: TransformOutput{Container: "mpegts", VideoCodec: "h264", AudioCodec: "aac"},
+			ExpectArgs:  "ffmpeg -i pipe:0 -f mpegts -muxdelay 0 -map 0:0 -map 0:1 -acodec aac -vcodec copy -copyts -af volume=2 pipe:1",
+		},
+		{
+			Name:        "Audio filtered only to another codec",
+			GivenGraphs: FilterGraphs{Audio: "volume=2"},
+			GivenOutput: TransformOutput{Container: "mpegts", VideoCodec: "hevc", AudioCodec: "aac"},
+			ExpectArgs:  "ffmpeg -i pipe:0 -f mpegts -muxdelay 0 -map 0:0 -map 0:1 -acodec aac -vcodec libx265 -preset superfast -copyts -af volume=2 pipe:1",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			cmd := CreateFilterCommand(context.Background(), tt.GivenGraphs, tt.GivenOutput)
+			require.Equal(t, tt.ExpectArgs, strings.Join(cmd.Args, " "))
+		})
+	}
+}
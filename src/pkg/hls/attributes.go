@@ -0,0 +1,131 @@
+package hls
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Attribute of an attribute list (e.g. BANDWIDTH=1280000 or CODECS="avc1.64001e,mp4a.40.2")
+type Attribute struct {
+	Key    string
+	Value  string
+	Quoted bool
+}
+
+// Attributes is an ordered attribute list, as found in tags such as EXT-X-STREAM-INF or EXT-X-KEY.
+// The order and the quoting of the attributes are kept to write the list back as it was read.
+type Attributes []Attribute
+
+func ParseAttributes(s string) (Attributes, error) {
+	attributes := Attributes{}
+
+	for len(s) > 0 {
+		key, rest, found := strings.Cut(s, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid attribute list %q", s)
+		}
+
+		attribute := Attribute{Key: strings.TrimSpace(key)}
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted string for attribute %v", attribute.Key)
+			}
+			attribute.Value = rest[1 : end+1]
+			attribute.Quoted = true
+			rest = rest[end+2:]
+		} else {
+			end := strings.Index(rest, ",")
+			if end < 0 {
+				end = len(rest)
+			}
+			attribute.Value = rest[:end]
+			rest = rest[end:]
+		}
+		attributes = append(attributes, attribute)
+
+		if len(rest) > 0 && rest[0] != ',' {
+			return nil, fmt.Errorf("unexpected %q after attribute %v", rest, attribute.Key)
+		}
+		s = strings.TrimPrefix(rest, ",")
+	}
+
+	return attributes, nil
+}
+
+func (a Attributes) Get(key string) (string, bool) {
+	for _, attribute := range a {
+		if attribute.Key == key {
+			return attribute.Value, true
+		}
+	}
+	return "", false
+}
+
+func (a Attributes) GetUint(key string) (uint64, bool) {
+	value, ok := a.Get(key)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(value, 10, 64)
+	return n, err == nil
+}
+
+// Set the value of an attribute, it is added at the end of the list if it does not exist
+func (a *Attributes) Set(key string, value string, quoted bool) {
+	for i := range *a {
+		if (*a)[i].Key == key {
+			(*a)[i].Value = value
+			(*a)[i].Quoted = quoted
+			return
+		}
+	}
+	*a = append(*a, Attribute{Key: key, Value: value, Quoted: quoted})
+}
+
+func (a *Attributes) Delete(key string) {
+	attributes := (*a)[:0]
+	for _, attribute := range *a {
+		if attribute.Key != key {
+			attributes = append(attributes, attribute)
+		}
+	}
+	*a = attributes
+}
+
+func (a Attributes) String() string {
+	var s strings.Builder
+	for i, attribute := range a {
+		if i > 0 {
+			s.WriteByte(',')
+		}
+		s.WriteString(attribute.Key)
+		s.WriteByte('=')
+		if attribute.Quoted {
+			s.WriteString(`"` + attribute.Value + `"`)
+		} else {
+			s.WriteString(attribute.Value)
+		}
+	}
+	return s.String()
+}
+
+// Resolution of a RESOLUTION attribute (e.g. 1280x720)
+func (a Attributes) Resolution() (uint64, uint64, bool) {
+	value, ok := a.Get("RESOLUTION")
+	if !ok {
+		return 0, 0, false
+	}
+
+	width, height, found := strings.Cut(value, "x")
+	if !found {
+		return 0, 0, false
+	}
+	w, errW := strconv.ParseUint(width, 10, 32)
+	h, errH := strconv.ParseUint(height, 10, 32)
+	if errW != nil || errH != nil {
+		return 0, 0, false
+	}
+	return w, h, true
+}
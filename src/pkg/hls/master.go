@@ -0,0 +1,153 @@
+package hls
+
+import (
+	"strconv"
+	"strings"
+)
+
+type MasterPlaylist struct {
+	Version             int
+	IndependentSegments bool
+	// Tags not interpreted by this package, written back verbatim
+	Tags           []string
+	Media          []*Media
+	Variants       []*Variant
+	IFrameVariants []*IFrameVariant
+
+	// Lines the header tags and the Tags were read from, to write them back in the same order
+	lines    map[string]int
+	tagLines []int
+}
+
+// Media is an alternative rendition (EXT-X-MEDIA), such as an audio or a subtitles track
+type Media struct {
+	Attributes Attributes
+
+	line int
+}
+
+// Variant is a variant stream (EXT-X-STREAM-INF) and the URI of its media playlist
+type Variant struct {
+	Attributes Attributes
+	URI        string
+
+	line int
+}
+
+// IFrameVariant is an I-frame only variant stream (EXT-X-I-FRAME-STREAM-INF)
+type IFrameVariant struct {
+	Attributes Attributes
+
+	line int
+}
+
+func decodeMaster(lines []line) (*MasterPlaylist, error) {
+	master := &MasterPlaylist{lines: map[string]int{}}
+	var pending *Variant
+
+	for _, l := range lines {
+		var err error
+		switch {
+		case pending != nil && isURI(l.text):
+			pending.URI = l.text
+			master.Variants = append(master.Variants, pending)
+			pending = nil
+		case pending != nil:
+			return nil, l.errorf("missing URI after %v", tagStreamInf)
+		case isURI(l.text):
+			return nil, l.errorf("unexpected URI %v", l.text)
+		case strings.HasPrefix(l.text, tagVersion):
+			master.Version, err = parseVersion(l)
+			master.lines[tagVersion] = l.number
+		case l.text == tagIndependentSegments:
+			master.IndependentSegments = true
+			master.lines[tagIndependentSegments] = l.number
+		case strings.HasPrefix(l.text, tagStreamInf):
+			pending = &Variant{line: l.number}
+			pending.Attributes, err = parseAttributesTag(l, tagStreamInf)
+		case strings.HasPrefix(l.text, tagIFrameStreamInf):
+			variant := &IFrameVariant{line: l.number}
+			variant.Attributes, err = parseAttributesTag(l, tagIFrameStreamInf)
+			master.IFrameVariants = append(master.IFrameVariants, variant)
+		case strings.HasPrefix(l.text, tagMedia):
+			media := &Media{line: l.number}
+			media.Attributes, err = parseAttributesTag(l, tagMedia)
+			master.Media = append(master.Media, media)
+		default:
+			master.Tags = append(master.Tags, l.text)
+			master.tagLines = append(master.tagLines, l.number)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if pending != nil {
+		return nil, ErrMissingVariantURI
+	}
+	return master, nil
+}
+
+func (m *MasterPlaylist) Encode() []byte {
+	media := make([]entry, len(m.Media))
+	for i, rendition := range m.Media {
+		media[i] = entry{line: rendition.line, lines: []string{tagMedia + rendition.Attributes.String()}}
+	}
+	variants := make([]entry, len(m.Variants))
+	for i, variant := range m.Variants {
+		variants[i] = entry{line: variant.line, lines: []string{tagStreamInf + variant.Attributes.String(), variant.URI}}
+	}
+	iFrameVariants := make([]entry, len(m.IFrameVariants))
+	for i, variant := range m.IFrameVariants {
+		iFrameVariants[i] = entry{line: variant.line, lines: []string{tagIFrameStreamInf + variant.Attributes.String()}}
+	}
+
+	var w writer
+	w.line(tagHeader)
+	w.entries(
+		tagEntry(m.lines, tagVersion, strconv.Itoa(m.Version), m.Version > 0),
+		tagEntry(m.lines, tagIndependentSegments, "", m.IndependentSegments),
+		tagEntries(m.Tags, m.tagLines),
+		media,
+		variants,
+		iFrameVariants,
+	)
+	return w.Bytes()
+}
+
+func (m *Media) URI() string {
+	uri, _ := m.Attributes.Get("URI")
+	return uri
+}
+
+func (m *Media) SetURI(uri string) {
+	if uri == "" {
+		m.Attributes.Delete("URI")
+		return
+	}
+	m.Attributes.Set("URI", uri, true)
+}
+
+// Bandwidth of the variant in bits per second
+func (v *Variant) Bandwidth() uint64 {
+	bandwidth, _ := v.Attributes.GetUint("BANDWIDTH")
+	return bandwidth
+}
+
+// Resolution of the variant, ok is false if the variant has no video
+func (v *Variant) Resolution() (width uint64, height uint64, ok bool) {
+	return v.Attributes.Resolution()
+}
+
+func (v *IFrameVariant) URI() string {
+	uri, _ := v.Attributes.Get("URI")
+	return uri
+}
+
+func (v *IFrameVariant) SetURI(uri string) {
+	v.Attributes.Set("URI", uri, true)
+}
+
+func (v *IFrameVariant) Resolution() (width uint64, height uint64, ok bool) {
+	return v.Attributes.Resolution()
+}
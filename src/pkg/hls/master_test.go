@@ -0,0 +1,155 @@
+package hls
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_MasterRoundTrip(t *testing.T) {
+	cases := []struct {
+		Name          string
+		GivenPlaylist string
+	}{
+		{
+			Name: "ffmpeg master",
+			GivenPlaylist: `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:BANDWIDTH=1240800,RESOLUTION=640x360,CODECS="avc1.64001e,mp4a.40.2"
+v0/segment_index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=3440800,RESOLUTION=1280x720,CODECS="avc1.64001f,mp4a.40.2"
+v1/segment_index.m3u8
+`,
+		},
+		{
+			Name: "Alternative renditions and I-frame variants",
+			GivenPlaylist: `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-SESSION-DATA:DATA-ID="com.example.title",VALUE="Example"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",LANGUAGE="en",NAME="English",DEFAULT=YES,AUTOSELECT=YES,URI="audio/en.m3u8"
+#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="cc",NAME="English",INSTREAM-ID="CC1"
+#EXT-X-STREAM-INF:BANDWIDTH=2000000,AVERAGE-BANDWIDTH=1800000,RESOLUTION=960x540,FRAME-RATE=29.970,AUDIO="aac",CLOSED-CAPTIONS="cc"
+540p.m3u8?token=abc
+#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=186000,RESOLUTION=960x540,URI="540p_iframes.m3u8"
+`,
+		},
+		{
+			Name: "Interleaved tags",
+			GivenPlaylist: `#EXTM3U
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-VERSION:6
+#EXT-X-STREAM-INF:BANDWIDTH=1240800,RESOLUTION=640x360,AUDIO="aac"
+v0.m3u8
+#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=93000,RESOLUTION=640x360,URI="v0_iframes.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",URI="audio/en.m3u8"
+#EXT-X-SESSION-DATA:DATA-ID="com.example.title",VALUE="Example"
+#EXT-X-STREAM-INF:BANDWIDTH=3440800,RESOLUTION=1280x720,AUDIO="aac"
+v1.m3u8
+#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=186000,RESOLUTION=1280x720,URI="v1_iframes.m3u8"
+`,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			master, err := DecodeMaster(strings.NewReader(tt.GivenPlaylist))
+			require.NoError(t, err)
+			require.Equal(t, tt.GivenPlaylist, string(master.Encode()))
+		})
+	}
+}
+
+func Test_DecodeMaster(t *testing.T) {
+	master, err := DecodeMaster(strings.NewReader(`#EXTM3U
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",URI="audio/en.m3u8"
+
+#EXT-X-STREAM-INF:BANDWIDTH=3440800,RESOLUTION=1280x720,CODECS="avc1.64001f,mp4a.40.2",AUDIO="aac"
+v1/segment_index.m3u8
+#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=186000,URI="iframes.m3u8"
+`))
+	require.NoError(t, err)
+
+	require.Len(t, master.Variants, 1)
+	require.Equal(t, "v1/segment_index.m3u8", master.Variants[0].URI)
+	require.Equal(t, uint64(3440800), master.Variants[0].Bandwidth())
+	codecs, _ := master.Variants[0].Attributes.Get("CODECS")
+	require.Equal(t, "avc1.64001f,mp4a.40.2", codecs)
+	width, height, ok := master.Variants[0].Resolution()
+	require.True(t, ok)
+	require.Equal(t, []uint64{1280, 720}, []uint64{width, height})
+
+	require.Len(t, master.Media, 1)
+	require.Equal(t, "audio/en.m3u8", master.Media[0].URI())
+	require.Len(t, master.IFrameVariants, 1)
+	require.Equal(t, "iframes.m3u8", master.IFrameVariants[0].URI())
+	_, _, ok = master.IFrameVariants[0].Resolution()
+	require.False(t, ok)
+}
+
+func Test_EncodeModifiedMaster(t *testing.T) {
+	master, err := DecodeMaster(strings.NewReader(`#EXTM3U
+#EXT-X-STREAM-INF:BANDWIDTH=1240800
+v0.m3u8
+#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=93000,URI="v0_iframes.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=3440800
+v1.m3u8
+#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=186000,URI="v1_iframes.m3u8"
+`))
+	require.NoError(t, err)
+
+	master.Variants = []*Variant{master.Variants[1], {Attributes: Attributes{{Key: "BANDWIDTH", Value: "640000"}}, URI: "v2.m3u8"}}
+	master.IFrameVariants = master.IFrameVariants[:1]
+	master.Media = append(master.Media, &Media{Attributes: Attributes{{Key: "TYPE", Value: "AUDIO"}}})
+	master.Version = 3
+
+	require.Equal(t, `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA:TYPE=AUDIO
+#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=93000,URI="v0_iframes.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=3440800
+v1.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=640000
+v2.m3u8
+`, string(master.Encode()))
+}
+
+func Test_EncodeNewMaster(t *testing.T) {
+	master := &MasterPlaylist{
+		Version:        3,
+		IFrameVariants: []*IFrameVariant{{Attributes: Attributes{{Key: "URI", Value: "iframes.m3u8", Quoted: true}}}},
+		Variants:       []*Variant{{Attributes: Attributes{{Key: "BANDWIDTH", Value: "1"}}, URI: "v0.m3u8"}},
+		Tags:           []string{"#EXT-X-SESSION-DATA:DATA-ID=\"id\",VALUE=\"value\""},
+	}
+
+	require.Equal(t, `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-SESSION-DATA:DATA-ID="id",VALUE="value"
+#EXT-X-STREAM-INF:BANDWIDTH=1
+v0.m3u8
+#EXT-X-I-FRAME-STREAM-INF:URI="iframes.m3u8"
+`, string(master.Encode()))
+}
+
+func Test_DecodeInvalidMaster(t *testing.T) {
+	cases := []struct {
+		Name          string
+		GivenPlaylist string
+		ExpectError   error
+	}{
+		{Name: "Missing header", GivenPlaylist: "#EXT-X-STREAM-INF:BANDWIDTH=1\nv0.m3u8\n", ExpectError: ErrNotPlaylist},
+		{Name: "Media playlist", GivenPlaylist: "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\ns0.ts\n", ExpectError: ErrUnexpectedKind},
+		{Name: "Missing variant URI", GivenPlaylist: "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1\n", ExpectError: ErrMissingVariantURI},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			_, err := DecodeMaster(strings.NewReader(tt.GivenPlaylist))
+			require.ErrorIs(t, err, tt.ExpectError)
+		})
+	}
+
+	_, err := DecodeMaster(strings.NewReader("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1,CODECS=\"avc1\nv0.m3u8\n"))
+	require.ErrorContains(t, err, "line 2")
+}
@@ -0,0 +1,315 @@
+package hls
+
+import (
+	"strconv"
+	"strings"
+)
+
+type MediaPlaylist struct {
+	Version               int
+	IndependentSegments   bool
+	TargetDuration        uint64
+	MediaSequence         uint64
+	DiscontinuitySequence uint64
+	// VOD or EVENT, empty when the playlist may change
+	PlaylistType string
+	IFramesOnly  bool
+	// Tags not interpreted by this package found before the first segment, written back verbatim
+	Tags     []string
+	Segments []*Segment
+	// Tags not interpreted by this package found after the last segment
+	TrailingTags []string
+	EndList      bool
+
+	// Lines the playlist tags were read from, to write them back in the same order. nil when the
+	// playlist was not decoded, TARGETDURATION and MEDIA-SEQUENCE are then always written.
+	lines         map[string]int
+	tagLines      []int
+	trailingLines []int
+}
+
+type Segment struct {
+	URI      string
+	Duration float64
+	Title    string
+	// Sub-range of the resource at URI, nil when the whole resource is the segment
+	ByteRange *ByteRange
+	// Key and Map are set on the segment they are declared before, they apply to the following
+	// segments until they are declared again
+	Key             *Key
+	Map             *Map
+	Discontinuity   bool
+	ProgramDateTime string
+	// Tags not interpreted by this package, written back verbatim before the segment
+	Tags []string
+
+	// Duration as written in the playlist, kept to write it back unchanged
+	rawDuration string
+	// Lines the segment tags were read from, to write them back in the same order
+	lines    map[string]int
+	tagLines []int
+}
+
+type ByteRange struct {
+	Length uint64
+	// Offset of the range, nil when the range starts after the one of the previous segment
+	Offset *uint64
+}
+
+// Key describes how segments are encrypted (EXT-X-KEY)
+type Key struct {
+	Attributes Attributes
+}
+
+// Map is the media initialization section of the segments (EXT-X-MAP)
+type Map struct {
+	Attributes Attributes
+}
+
+func decodeMedia(lines []line) (*MediaPlaylist, error) {
+	media := &MediaPlaylist{lines: map[string]int{}}
+	var pending *Segment
+
+	// Tags of the segment being read, created on its first tag
+	segment := func(l line, tag string) *Segment {
+		if pending == nil {
+			pending = &Segment{lines: map[string]int{}}
+		}
+		pending.lines[tag] = l.number
+		return pending
+	}
+
+	for _, l := range lines {
+		var err error
+		switch {
+		case isURI(l.text):
+			if pending == nil || pending.rawDuration == "" {
+				return nil, l.errorf("missing %v before URI %v", tagInf, l.text)
+			}
+			pending.URI = l.text
+			media.Segments = append(media.Segments, pending)
+			pending = nil
+		case strings.HasPrefix(l.text, tagVersion):
+			media.Version, err = parseVersion(l)
+			media.lines[tagVersion] = l.number
+		case l.text == tagIndependentSegments:
+			media.IndependentSegments = true
+			media.lines[tagIndependentSegments] = l.number
+		case strings.HasPrefix(l.text, tagTargetDuration):
+			media.TargetDuration, err = parseUintTag(l, tagTargetDuration)
+			media.lines[tagTargetDuration] = l.number
+		case strings.HasPrefix(l.text, tagMediaSequence):
+			media.MediaSequence, err = parseUintTag(l, tagMediaSequence)
+			media.lines[tagMediaSequence] = l.number
+		case strings.HasPrefix(l.text, tagDiscontinuitySequence):
+			media.DiscontinuitySequence, err = parseUintTag(l, tagDiscontinuitySequence)
+			media.lines[tagDiscontinuitySequence] = l.number
+		case strings.HasPrefix(l.text, tagPlaylistType):
+			media.PlaylistType = strings.TrimPrefix(l.text, tagPlaylistType)
+			media.lines[tagPlaylistType] = l.number
+		case l.text == tagIFramesOnly:
+			media.IFramesOnly = true
+			media.lines[tagIFramesOnly] = l.number
+		case l.text == tagEndList:
+			media.EndList = true
+			media.lines[tagEndList] = l.number
+		case strings.HasPrefix(l.text, tagInf):
+			err = segment(l, tagInf).parseInf(l)
+		case strings.HasPrefix(l.text, tagByteRange):
+			segment(l, tagByteRange).ByteRange, err = parseByteRange(l)
+		case l.text == tagDiscontinuity:
+			segment(l, tagDiscontinuity).Discontinuity = true
+		case strings.HasPrefix(l.text, tagProgramDateTime):
+			segment(l, tagProgramDateTime).ProgramDateTime = strings.TrimPrefix(l.text, tagProgramDateTime)
+		case strings.HasPrefix(l.text, tagKey):
+			key := &Key{}
+			key.Attributes, err = parseAttributesTag(l, tagKey)
+			segment(l, tagKey).Key = key
+		case strings.HasPrefix(l.text, tagMap):
+			m := &Map{}
+			m.Attributes, err = parseAttributesTag(l, tagMap)
+			segment(l, tagMap).Map = m
+		case pending != nil:
+			pending.Tags = append(pending.Tags, l.text)
+			pending.tagLines = append(pending.tagLines, l.number)
+		case len(media.Segments) == 0:
+			media.Tags = append(media.Tags, l.text)
+			media.tagLines = append(media.tagLines, l.number)
+		default:
+			media.TrailingTags = append(media.TrailingTags, l.text)
+			media.trailingLines = append(media.trailingLines, l.number)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if pending != nil {
+		return nil, ErrMissingSegmentURI
+	}
+	return media, nil
+}
+
+func (s *Segment) parseInf(l line) error {
+	duration, title, _ := strings.Cut(strings.TrimPrefix(l.text, tagInf), ",")
+
+	d, err := strconv.ParseFloat(duration, 64)
+	if err != nil || d < 0 {
+		return l.errorf("invalid segment duration %v", duration)
+	}
+
+	s.Duration = d
+	s.Title = title
+	s.rawDuration = duration
+	return nil
+}
+
+func parseByteRange(l line) (*ByteRange, error) {
+	length, offset, hasOffset := strings.Cut(strings.TrimPrefix(l.text, tagByteRange), "@")
+
+	byteRange := &ByteRange{}
+	var err error
+	if byteRange.Length, err = strconv.ParseUint(length, 10, 64); err != nil {
+		return nil, l.errorf("invalid byte range length %v", length)
+	}
+	if hasOffset {
+		o, err := strconv.ParseUint(offset, 10, 64)
+		if err != nil {
+			return nil, l.errorf("invalid byte range offset %v", offset)
+		}
+		byteRange.Offset = &o
+	}
+	return byteRange, nil
+}
+
+func parseUintTag(l line, tag string) (uint64, error) {
+	n, err := strconv.ParseUint(strings.TrimPrefix(l.text, tag), 10, 64)
+	if err != nil {
+		return 0, l.errorf("invalid %v value : %v", strings.TrimSuffix(tag, ":"), err)
+	}
+	return n, nil
+}
+
+func (m *MediaPlaylist) Encode() []byte {
+	// A decoded playlist keeps the tags it was read with, even when their value is the default one
+	read := func(tag string) bool {
+		_, ok := m.lines[tag]
+		return ok
+	}
+	decoded := m.lines != nil
+
+	var w writer
+	w.line(tagHeader)
+	w.entries(
+		tagEntry(m.lines, tagVersion, strconv.Itoa(m.Version), m.Version > 0),
+		tagEntry(m.lines, tagIndependentSegments, "", m.IndependentSegments),
+		tagEntry(m.lines, tagTargetDuration, strconv.FormatUint(m.TargetDuration, 10), m.TargetDuration > 0 || read(tagTargetDuration) || !decoded),
+		tagEntry(m.lines, tagMediaSequence, strconv.FormatUint(m.MediaSequence, 10), m.MediaSequence > 0 || read(tagMediaSequence) || !decoded),
+		tagEntry(m.lines, tagDiscontinuitySequence, strconv.FormatUint(m.DiscontinuitySequence, 10), m.DiscontinuitySequence > 0 || read(tagDiscontinuitySequence)),
+		tagEntry(m.lines, tagPlaylistType, m.PlaylistType, m.PlaylistType != ""),
+		tagEntry(m.lines, tagIFramesOnly, "", m.IFramesOnly),
+		tagEntries(m.Tags, m.tagLines),
+	)
+
+	for _, segment := range m.Segments {
+		segment.encode(&w)
+	}
+
+	w.entries(
+		tagEntries(m.TrailingTags, m.trailingLines),
+		tagEntry(m.lines, tagEndList, "", m.EndList),
+	)
+	return w.Bytes()
+}
+
+func (s *Segment) encode(w *writer) {
+	var byteRange string
+	if s.ByteRange != nil {
+		byteRange = strconv.FormatUint(s.ByteRange.Length, 10)
+		if s.ByteRange.Offset != nil {
+			byteRange += "@" + strconv.FormatUint(*s.ByteRange.Offset, 10)
+		}
+	}
+	var key, initMap string
+	if s.Key != nil {
+		key = s.Key.Attributes.String()
+	}
+	if s.Map != nil {
+		initMap = s.Map.Attributes.String()
+	}
+
+	w.entries(
+		tagEntry(s.lines, tagDiscontinuity, "", s.Discontinuity),
+		tagEntry(s.lines, tagKey, key, s.Key != nil),
+		tagEntry(s.lines, tagMap, initMap, s.Map != nil),
+		tagEntry(s.lines, tagProgramDateTime, s.ProgramDateTime, s.ProgramDateTime != ""),
+		tagEntries(s.Tags, s.tagLines),
+		tagEntry(s.lines, tagInf, s.formatDuration()+","+s.Title, true),
+		tagEntry(s.lines, tagByteRange, byteRange, s.ByteRange != nil),
+	)
+	w.line(s.URI)
+}
+
+// Write the duration as it was read, unless it has been changed since
+func (s *Segment) formatDuration() string {
+	if d, err := strconv.ParseFloat(s.rawDuration, 64); err == nil && d == s.Duration {
+		return s.rawDuration
+	}
+	return strconv.FormatFloat(s.Duration, 'f', -1, 64)
+}
+
+// Duration of the playlist in seconds, the sum of its segment durations
+func (m *MediaPlaylist) Duration() float64 {
+	duration := 0.0
+	for _, segment := range m.Segments {
+		duration += segment.Duration
+	}
+	return duration
+}
+
+// Find the segment containing the timestamp t (in seconds). Return its index and its start
+// timestamp, ok is false if t is beyond the end of the playlist.
+func (m *MediaPlaylist) SegmentAt(t float64) (index int, start float64, ok bool) {
+	for i, segment := range m.Segments {
+		if t < start+segment.Duration {
+			return i, start, true
+		}
+		start += segment.Duration
+	}
+	return 0, start, false
+}
+
+// Find the segment at uri. Return its index and its start timestamp (in seconds), ok is false if
+// the playlist has no such segment.
+func (m *MediaPlaylist) FindSegment(uri string) (index int, start float64, ok bool) {
+	for i, segment := range m.Segments {
+		if segment.URI == uri {
+			return i, start, true
+		}
+		start += segment.Duration
+	}
+	return 0, 0, false
+}
+
+func (k *Key) Method() string {
+	method, _ := k.Attributes.Get("METHOD")
+	return method
+}
+
+func (k *Key) URI() string {
+	uri, _ := k.Attributes.Get("URI")
+	return uri
+}
+
+func (k *Key) SetURI(uri string) {
+	k.Attributes.Set("URI", uri, true)
+}
+
+func (m *Map) URI() string {
+	uri, _ := m.Attributes.Get("URI")
+	return uri
+}
+
+func (m *Map) SetURI(uri string) {
+	m.Attributes.Set("URI", uri, true)
+}
@@ -0,0 +1,215 @@
+package hls
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_MediaRoundTrip(t *testing.T) {
+	cases := []struct {
+		Name          string
+		GivenPlaylist string
+	}{
+		{
+			Name: "ffmpeg media playlist",
+			GivenPlaylist: `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:7
+#EXT-X-MEDIA-SEQUENCE:0
+#EXTINF:6.006000,
+segment0.ts
+#EXTINF:6.006000,
+segment1.ts
+#EXTINF:2.335667,
+segment2.ts
+#EXT-X-ENDLIST
+`,
+		},
+		{
+			Name: "Encrypted fMP4 segments with discontinuity",
+			GivenPlaylist: `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-TARGETDURATION:4
+#EXT-X-MEDIA-SEQUENCE:10
+#EXT-X-DISCONTINUITY-SEQUENCE:2
+#EXT-X-PLAYLIST-TYPE:EVENT
+#EXT-X-START:TIME-OFFSET=0
+#EXT-X-KEY:METHOD=AES-128,URI="https://keys.example.com/1",IV=0x0123456789ABCDEF0123456789ABCDEF
+#EXT-X-MAP:URI="init.mp4",BYTERANGE="720@0"
+#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:00.000Z
+#EXTINF:4,first
+#EXT-X-BYTERANGE:1000@720
+part.mp4?v=1
+#EXT-X-DISCONTINUITY
+#EXT-X-KEY:METHOD=NONE
+#EXT-X-GAP
+#EXTINF:3.5,
+#EXT-X-BYTERANGE:1200
+part.mp4
+`,
+		},
+		{
+			Name: "I-frame playlist",
+			GivenPlaylist: `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-I-FRAMES-ONLY
+#EXTINF:6.006,
+#EXT-X-BYTERANGE:9024@376
+segment0.ts
+#EXTINF:6.006,
+#EXT-X-BYTERANGE:8836@564
+segment1.ts
+#EXT-X-ENDLIST
+`,
+		},
+		{
+			Name: "Interleaved tags without media sequence",
+			GivenPlaylist: `#EXTM3U
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-TARGETDURATION:10
+#EXT-X-START:TIME-OFFSET=0
+#EXT-X-VERSION:3
+#EXT-X-DISCONTINUITY-SEQUENCE:0
+#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:00.000Z
+#EXT-X-GAP
+#EXT-X-BYTERANGE:1000@0
+#EXTINF:10,
+#EXT-X-KEY:METHOD=NONE
+segment0.ts
+#EXT-X-ENDLIST
+#EXT-X-DEFINE:NAME="after",VALUE="end"
+`,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			media, err := DecodeMedia(strings.NewReader(tt.GivenPlaylist))
+			require.NoError(t, err)
+			require.Equal(t, tt.GivenPlaylist, string(media.Encode()))
+		})
+	}
+}
+
+func Test_DecodeMedia(t *testing.T) {
+	media, err := DecodeMedia(strings.NewReader(`#EXTM3U
+#EXT-X-TARGETDURATION:4
+#EXT-X-KEY:METHOD=AES-128,URI="key"
+#EXT-X-MAP:URI="init.mp4"
+#EXTINF:4,first
+#EXT-X-BYTERANGE:1000@720
+part.mp4
+#EXT-X-DISCONTINUITY
+#EXTINF:3.5,
+#EXT-X-BYTERANGE:1200
+part.mp4
+#EXT-X-ENDLIST
+`))
+	require.NoError(t, err)
+
+	require.Equal(t, uint64(4), media.TargetDuration)
+	require.True(t, media.EndList)
+	require.Len(t, media.Segments, 2)
+	require.InDelta(t, 7.5, media.Duration(), 1e-9)
+
+	first := media.Segments[0]
+	require.Equal(t, "part.mp4", first.URI)
+	require.Equal(t, "first", first.Title)
+	require.Equal(t, "AES-128", first.Key.Method())
+	require.Equal(t, "key", first.Key.URI())
+	require.Equal(t, "init.mp4", first.Map.URI())
+	require.Equal(t, uint64(1000), first.ByteRange.Length)
+	require.Equal(t, uint64(720), *first.ByteRange.Offset)
+
+	second := media.Segments[1]
+	require.True(t, second.Discontinuity)
+	require.Nil(t, second.Key)
+	require.Nil(t, second.ByteRange.Offset)
+}
+
+func Test_SegmentAt(t *testing.T) {
+	media := &MediaPlaylist{Segments: []*Segment{{URI: "s0.ts", Duration: 6}, {URI: "s1.ts", Duration: 6}, {URI: "s2.ts", Duration: 2.5}}}
+
+	cases := []struct {
+		Name        string
+		GivenTime   float64
+		ExpectIndex int
+		ExpectStart float64
+		ExpectOK    bool
+	}{
+		{Name: "Start of the video", GivenTime: 0, ExpectIndex: 0, ExpectStart: 0, ExpectOK: true},
+		{Name: "Segment boundary", GivenTime: 6, ExpectIndex: 1, ExpectStart: 6, ExpectOK: true},
+		{Name: "Last segment", GivenTime: 14, ExpectIndex: 2, ExpectStart: 12, ExpectOK: true},
+		{Name: "End of the video", GivenTime: 14.5, ExpectStart: 14.5, ExpectOK: false},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			index, start, ok := media.SegmentAt(tt.GivenTime)
+			require.Equal(t, tt.ExpectOK, ok)
+			require.Equal(t, tt.ExpectIndex, index)
+			require.Equal(t, tt.ExpectStart, start)
+		})
+	}
+}
+
+func Test_FindSegment(t *testing.T) {
+	media := &MediaPlaylist{Segments: []*Segment{{URI: "s0.ts", Duration: 6}, {URI: "s1.ts", Duration: 6}, {URI: "s2.ts", Duration: 2.5}}}
+
+	index, start, ok := media.FindSegment("s2.ts")
+	require.True(t, ok)
+	require.Equal(t, 2, index)
+	require.Equal(t, 12.0, start)
+
+	_, _, ok = media.FindSegment("s3.ts")
+	require.False(t, ok)
+}
+
+func Test_EncodeModifiedMedia(t *testing.T) {
+	media, err := DecodeMedia(strings.NewReader("#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6.000000,\ns0.ts\n#EXTINF:6.000000,\ns1.ts\n"))
+	require.NoError(t, err)
+
+	media.Segments[1].Duration = 5.5
+	media.Segments[0].URI += "?token=abc"
+	media.EndList = true
+
+	require.Equal(t, "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6.000000,\ns0.ts?token=abc\n#EXTINF:5.5,\ns1.ts\n#EXT-X-ENDLIST\n", string(media.Encode()))
+}
+
+func Test_EncodeNewMedia(t *testing.T) {
+	media := &MediaPlaylist{
+		Version:        3,
+		TargetDuration: 6,
+		PlaylistType:   "VOD",
+		Segments:       []*Segment{{URI: "s0.ts", Duration: 6, Discontinuity: true, Tags: []string{"#EXT-X-GAP"}}},
+		EndList:        true,
+	}
+
+	require.Equal(t, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:6\n#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-PLAYLIST-TYPE:VOD\n#EXT-X-DISCONTINUITY\n#EXT-X-GAP\n#EXTINF:6,\ns0.ts\n#EXT-X-ENDLIST\n", string(media.Encode()))
+}
+
+func Test_DecodeInvalidMedia(t *testing.T) {
+	cases := []struct {
+		Name          string
+		GivenPlaylist string
+		ExpectError   string
+	}{
+		{Name: "URI without duration", GivenPlaylist: "#EXTM3U\n#EXT-X-TARGETDURATION:6\ns0.ts\n", ExpectError: "line 3 : missing #EXTINF:"},
+		{Name: "Invalid duration", GivenPlaylist: "#EXTM3U\n#EXTINF:abc,\ns0.ts\n", ExpectError: "line 2 : invalid segment duration abc"},
+		{Name: "Invalid byte range", GivenPlaylist: "#EXTM3U\n#EXTINF:6,\n#EXT-X-BYTERANGE:10@x\ns0.ts\n", ExpectError: "line 3 : invalid byte range offset x"},
+		{Name: "Missing segment URI", GivenPlaylist: "#EXTM3U\n#EXTINF:6,\n", ExpectError: ErrMissingSegmentURI.Error()},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			_, err := DecodeMedia(strings.NewReader(tt.GivenPlaylist))
+			require.ErrorContains(t, err, tt.ExpectError)
+		})
+	}
+}
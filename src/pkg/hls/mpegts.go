@@ -0,0 +1,56 @@
+package hls
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Size of the packets of an MPEG transport stream
+const tsPacketSize = 188
+
+// Clock of the MPEG-TS timestamps, in Hz
+const tsClockRate = 90000
+
+var (
+	ErrInvalidTransportStream = errors.New("invalid MPEG transport stream")
+	ErrNoTimestamp            = errors.New("no video timestamp found")
+)
+
+// Find the presentation timestamp, in seconds, of the first video frame of an MPEG-TS segment.
+// Only the beginning of the segment is needed, ErrNoTimestamp is returned if data ends before
+// the first video frame.
+func FirstVideoPTS(data []byte) (float64, error) {
+	for offset := 0; offset+tsPacketSize <= len(data); offset += tsPacketSize {
+		packet := data[offset : offset+tsPacketSize]
+		if packet[0] != 0x47 {
+			return 0, fmt.Errorf("%w : no sync byte at offset %v", ErrInvalidTransportStream, offset)
+		}
+
+		// Only the packets starting a PES packet carry its header
+		payloadStart := packet[1]&0x40 != 0
+		adaptationField := packet[3]&0x20 != 0
+		hasPayload := packet[3]&0x10 != 0
+		if !payloadStart || !hasPayload {
+			continue
+		}
+		payload := packet[4:]
+		if adaptationField {
+			length := int(payload[0])
+			if 1+length >= len(payload) {
+				continue
+			}
+			payload = payload[1+length:]
+		}
+
+		// PES header : start code, video stream id, PTS flag
+		if len(payload) < 14 || payload[0] != 0 || payload[1] != 0 || payload[2] != 1 {
+			continue
+		}
+		if payload[3]&0xF0 != 0xE0 || payload[7]&0x80 == 0 {
+			continue
+		}
+		pts := uint64(payload[9]>>1&0x07)<<30 | uint64(payload[10])<<22 | uint64(payload[11]>>1)<<15 | uint64(payload[12])<<7 | uint64(payload[13]>>1)
+		return float64(pts) / tsClockRate, nil
+	}
+	return 0, ErrNoTimestamp
+}
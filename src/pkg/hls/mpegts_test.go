@@ -0,0 +1,52 @@
+package hls
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Create a transport stream packet starting a PES packet of stream id with the given PTS
+func pesPacket(streamID byte, pts uint64, adaptationLength int) []byte {
+	packet := make([]byte, tsPacketSize)
+	packet[0], packet[1], packet[2], packet[3] = 0x47, 0x41, 0x00, 0x10
+	payload := packet[4:]
+	if adaptationLength > 0 {
+		packet[3] |= 0x20
+		payload[0] = byte(adaptationLength)
+		payload = payload[1+adaptationLength:]
+	}
+	copy(payload, []byte{0, 0, 1, streamID, 0, 0, 0x80, 0x80, 5})
+	payload[9] = byte(0x21 | (pts>>30&0x07)<<1)
+	payload[10] = byte(pts >> 22)
+	payload[11] = byte(0x01 | (pts>>15&0x7F)<<1)
+	payload[12] = byte(pts >> 7)
+	payload[13] = byte(0x01 | (pts&0x7F)<<1)
+	return packet
+}
+
+func Test_FirstVideoPTS(t *testing.T) {
+	audio := pesPacket(0xC0, 90000, 0)
+	video := pesPacket(0xE0, 126000, 7)
+	continuation := make([]byte, tsPacketSize)
+	continuation[0], continuation[3] = 0x47, 0x10
+
+	cases := []struct {
+		Name        string
+		GivenData   []byte
+		ExpectPTS   float64
+		ExpectError error
+	}{
+		{Name: "Video after audio", GivenData: append(append(append([]byte{}, continuation...), audio...), video...), ExpectPTS: 1.4},
+		{Name: "Truncated before video", GivenData: append(append([]byte{}, audio...), video[:100]...), ExpectError: ErrNoTimestamp},
+		{Name: "Not a transport stream", GivenData: make([]byte, tsPacketSize), ExpectError: ErrInvalidTransportStream},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			pts, err := FirstVideoPTS(tt.GivenData)
+			require.ErrorIs(t, err, tt.ExpectError)
+			require.Equal(t, tt.ExpectPTS, pts)
+		})
+	}
+}
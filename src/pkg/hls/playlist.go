@@ -0,0 +1,228 @@
+// Package hls parses and writes HLS playlists (RFC 8216). Tags this package does not interpret
+// are kept verbatim, so that a decoded playlist is written back as it was read.
+package hls
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNotPlaylist     = errors.New("not an HLS playlist, missing #EXTM3U")
+	ErrUnexpectedKind  = errors.New("unexpected playlist kind")
+	ErrUnknownPlaylist = errors.New("cannot tell a master from a media playlist")
+
+	ErrMissingVariantURI = errors.New("missing URI after the last " + tagStreamInf)
+	ErrMissingSegmentURI = errors.New("missing URI after the last " + tagInf)
+)
+
+const (
+	tagHeader                = "#EXTM3U"
+	tagVersion               = "#EXT-X-VERSION:"
+	tagIndependentSegments   = "#EXT-X-INDEPENDENT-SEGMENTS"
+	tagStreamInf             = "#EXT-X-STREAM-INF:"
+	tagIFrameStreamInf       = "#EXT-X-I-FRAME-STREAM-INF:"
+	tagMedia                 = "#EXT-X-MEDIA:"
+	tagTargetDuration        = "#EXT-X-TARGETDURATION:"
+	tagMediaSequence         = "#EXT-X-MEDIA-SEQUENCE:"
+	tagDiscontinuitySequence = "#EXT-X-DISCONTINUITY-SEQUENCE:"
+	tagPlaylistType          = "#EXT-X-PLAYLIST-TYPE:"
+	tagIFramesOnly           = "#EXT-X-I-FRAMES-ONLY"
+	tagEndList               = "#EXT-X-ENDLIST"
+	tagInf                   = "#EXTINF:"
+	tagByteRange             = "#EXT-X-BYTERANGE:"
+	tagDiscontinuity         = "#EXT-X-DISCONTINUITY"
+	tagProgramDateTime       = "#EXT-X-PROGRAM-DATE-TIME:"
+	tagKey                   = "#EXT-X-KEY:"
+	tagMap                   = "#EXT-X-MAP:"
+)
+
+// Playlist is either a *MasterPlaylist or a *MediaPlaylist
+type Playlist interface {
+	Encode() []byte
+}
+
+// Decode a master or a media playlist, depending on the tags it contains
+func Decode(r io.Reader) (Playlist, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	lines, err := readLines(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range lines {
+		switch {
+		case strings.HasPrefix(l.text, tagStreamInf), strings.HasPrefix(l.text, tagIFrameStreamInf), strings.HasPrefix(l.text, tagMedia):
+			return decodeMaster(lines)
+		case strings.HasPrefix(l.text, tagInf), strings.HasPrefix(l.text, tagTargetDuration):
+			return decodeMedia(lines)
+		}
+	}
+	return nil, ErrUnknownPlaylist
+}
+
+func DecodeMaster(r io.Reader) (*MasterPlaylist, error) {
+	playlist, err := Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	master, ok := playlist.(*MasterPlaylist)
+	if !ok {
+		return nil, fmt.Errorf("%w : media playlist instead of master playlist", ErrUnexpectedKind)
+	}
+	return master, nil
+}
+
+func DecodeMedia(r io.Reader) (*MediaPlaylist, error) {
+	playlist, err := Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	media, ok := playlist.(*MediaPlaylist)
+	if !ok {
+		return nil, fmt.Errorf("%w : master playlist instead of media playlist", ErrUnexpectedKind)
+	}
+	return media, nil
+}
+
+type line struct {
+	number int
+	text   string
+}
+
+func (l line) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d : %s", l.number, fmt.Sprintf(format, args...))
+}
+
+// Read the non blank lines of a playlist, after the #EXTM3U header
+func readLines(data []byte) ([]line, error) {
+	var lines []line
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	number := 0
+	for scanner.Scan() {
+		number++
+		text := strings.TrimSpace(scanner.Text())
+		if number == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if text == "" {
+			continue
+		}
+		lines = append(lines, line{number: number, text: text})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(lines) == 0 || lines[0].text != tagHeader {
+		return nil, ErrNotPlaylist
+	}
+	return lines[1:], nil
+}
+
+func parseVersion(l line) (int, error) {
+	version, err := strconv.Atoi(strings.TrimPrefix(l.text, tagVersion))
+	if err != nil {
+		return 0, l.errorf("invalid version : %v", err)
+	}
+	return version, nil
+}
+
+func parseAttributesTag(l line, tag string) (Attributes, error) {
+	attributes, err := ParseAttributes(strings.TrimPrefix(l.text, tag))
+	if err != nil {
+		return nil, l.errorf("%v", err)
+	}
+	return attributes, nil
+}
+
+func isURI(text string) bool {
+	return !strings.HasPrefix(text, "#")
+}
+
+type writer struct {
+	bytes.Buffer
+}
+
+func (w *writer) line(s string) {
+	w.WriteString(s)
+	w.WriteByte('\n')
+}
+
+// entry is a tag, and the URI following it, to write back at the line it was read from. The line is
+// 0 when the tag was not read from a playlist.
+type entry struct {
+	line  int
+	lines []string
+}
+
+// Entry of a tag with a single value, no entry when the tag is not set
+func tagEntry(lines map[string]int, tag string, value string, set bool) []entry {
+	if !set {
+		return nil
+	}
+	return []entry{{line: lines[tag], lines: []string{tag + value}}}
+}
+
+// Entries of the tags not interpreted by this package, tagLines are the lines they were read from
+func tagEntries(tags []string, tagLines []int) []entry {
+	entries := make([]entry, len(tags))
+	for i, tag := range tags {
+		entries[i].lines = []string{tag}
+		if i < len(tagLines) {
+			entries[i].line = tagLines[i]
+		}
+	}
+	return entries
+}
+
+// Write lists of entries merged by the line they were read from, so that a decoded playlist keeps
+// the order of its tags. Each list is written in its own order. An entry not read from a playlist
+// is written next to the other entries of its list, or after the entries of the previous lists when
+// none of its list was read.
+func (w *writer) entries(lists ...[]entry) {
+	last := 0
+	for _, list := range lists {
+		previous := 0
+		for i := range list {
+			if list[i].line == 0 {
+				list[i].line = previous
+			}
+			previous = list[i].line
+		}
+		next := last
+		for i := len(list) - 1; i >= 0; i-- {
+			if list[i].line == 0 {
+				list[i].line = next
+			}
+			next = list[i].line
+			last = max(last, list[i].line)
+		}
+	}
+
+	for {
+		first := -1
+		for i, list := range lists {
+			if len(list) > 0 && (first < 0 || list[0].line < lists[first][0].line) {
+				first = i
+			}
+		}
+		if first < 0 {
+			return
+		}
+		for _, l := range lists[first][0].lines {
+			w.line(l)
+		}
+		lists[first] = lists[first][1:]
+	}
+}
@@ -0,0 +1,170 @@
+package subtitles
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidSubtitles = errors.New("invalid subtitles")
+
+var trackName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,31}$`)
+
+// Names of the tracks of a video, such as a language code
+func IsValidTrack(track string) bool {
+	return trackName.MatchString(track)
+}
+
+// S3 key of a subtitle track of a video, tracks are stored as WebVTT
+func TrackKey(videoID string, track string) string {
+	return videoID + "/subtitles/" + track + ".vtt"
+}
+
+// Cue is a text shown from Start to End, in seconds from the start of the video
+type Cue struct {
+	Start float64
+	End   float64
+	// Lines of the cue, with the b, i and u tags only
+	Text string
+}
+
+// Tags kept in the cues, the others (voices, classes, timestamps...) are removed
+var keptTags = map[string]bool{"b": true, "i": true, "u": true}
+
+var tagPattern = regexp.MustCompile(`</?([A-Za-z]*)[^>]*>`)
+
+// Parse a WebVTT or SRT track. WebVTT is recognised by its header, the cue settings, styles and
+// regions are ignored.
+func Parse(data []byte) ([]Cue, error) {
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+	vtt := strings.HasPrefix(lines[0], "WEBVTT")
+
+	cues := []Cue{}
+	for i := 0; i < len(lines); i++ {
+		if !strings.Contains(lines[i], "-->") {
+			continue
+		}
+		start, end, err := parseTiming(lines[i])
+		if err != nil {
+			return nil, fmt.Errorf("%w : line %v : %v", ErrInvalidSubtitles, i+1, err)
+		}
+
+		text := []string{}
+		for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+			i++
+			text = append(text, cleanText(lines[i], vtt))
+		}
+		cues = append(cues, Cue{Start: start, End: end, Text: strings.Join(text, "\n")})
+	}
+
+	if len(cues) == 0 {
+		return nil, fmt.Errorf("%w : no cue found", ErrInvalidSubtitles)
+	}
+	return cues, nil
+}
+
+// Parse "start --> end [settings]"
+func parseTiming(line string) (float64, float64, error) {
+	rawStart, rest, _ := strings.Cut(line, "-->")
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return 0, 0, fmt.Errorf("missing end of cue")
+	}
+
+	start, err := parseTimestamp(strings.TrimSpace(rawStart))
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parseTimestamp(fields[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("cue ends before it starts")
+	}
+	return start, end, nil
+}
+
+// Parse [hh:]mm:ss.ttt, with a comma in SRT
+func parseTimestamp(timestamp string) (float64, error) {
+	parts := strings.Split(strings.Replace(timestamp, ",", ".", 1), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %v", timestamp)
+	}
+
+	seconds := 0.0
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil || value < 0 || (i < len(parts)-1 && strings.Contains(part, ".")) {
+			return 0, fmt.Errorf("invalid timestamp %v", timestamp)
+		}
+		seconds = seconds*60 + value
+	}
+	return seconds, nil
+}
+
+func cleanText(line string, vtt bool) string {
+	line = tagPattern.ReplaceAllStringFunc(line, func(tag string) string {
+		name := strings.ToLower(tagPattern.FindStringSubmatch(tag)[1])
+		if !keptTags[name] {
+			return ""
+		}
+		if strings.HasPrefix(tag, "</") {
+			return "</" + name + ">"
+		}
+		return "<" + name + ">"
+	})
+	if vtt {
+		line = html.UnescapeString(line)
+	}
+	return line
+}
+
+// Cues shown between start and end
+func Window(cues []Cue, start float64, end float64) []Cue {
+	window := []Cue{}
+	for _, cue := range cues {
+		if cue.End > start && cue.Start < end {
+			window = append(window, cue)
+		}
+	}
+	return window
+}
+
+func FormatVTT(cues []Cue) []byte {
+	var vtt strings.Builder
+	vtt.WriteString("WEBVTT\n")
+	for _, cue := range cues {
+		fmt.Fprintf(&vtt, "\n%s --> %s\n%s\n", formatTimestamp(cue.Start, "."), formatTimestamp(cue.End, "."), html.EscapeString(cue.Text))
+	}
+	return []byte(unescapeTags(vtt.String()))
+}
+
+func FormatSRT(cues []Cue) []byte {
+	var srt strings.Builder
+	for i, cue := range cues {
+		fmt.Fprintf(&srt, "%d\n%s --> %s\n%s\n\n", i+1, formatTimestamp(cue.Start, ","), formatTimestamp(cue.End, ","), cue.Text)
+	}
+	return []byte(srt.String())
+}
+
+// The kept tags are written as is in WebVTT
+func unescapeTags(vtt string) string {
+	for tag := range keptTags {
+		vtt = strings.ReplaceAll(vtt, "&lt;"+tag+"&gt;", "<"+tag+">")
+		vtt = strings.ReplaceAll(vtt, "&lt;/"+tag+"&gt;", "</"+tag+">")
+	}
+	return vtt
+}
+
+// Format hh:mm:ss.ttt, separator being the one of the milliseconds
+func formatTimestamp(seconds float64, separator string) string {
+	milliseconds := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", milliseconds/3600000, milliseconds/60000%60, milliseconds/1000%60, separator, milliseconds%1000)
+}
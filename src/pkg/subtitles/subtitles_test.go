@@ -0,0 +1,51 @@
+package subtitles
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Parse(t *testing.T) {
+	cases := []struct {
+		Name        string
+		GivenTrack  string
+		ExpectCues  []Cue
+		ExpectError error
+	}{
+		{
+			Name:       "WebVTT",
+			GivenTrack: "WEBVTT\n\nNOTE a comment\n\nintro\n00:01.500 --> 00:03.000 align:start\n<v Roger>Hello &amp; <b.loud>welcome</b>\n\n01:00:00.000 --> 01:00:02.250\nBye\n",
+			ExpectCues: []Cue{{Start: 1.5, End: 3, Text: "Hello & <b>welcome</b>"}, {Start: 3600, End: 3602.25, Text: "Bye"}},
+		},
+		{
+			Name:       "SRT with CRLF",
+			GivenTrack: "1\r\n00:00:01,000 --> 00:00:02,500\r\nFirst line\r\n<i>Second line</i>\r\n\r\n2\r\n00:00:04,000 --> 00:00:05,000\r\nNext\r\n",
+			ExpectCues: []Cue{{Start: 1, End: 2.5, Text: "First line\n<i>Second line</i>"}, {Start: 4, End: 5, Text: "Next"}},
+		},
+		{Name: "Invalid timestamp", GivenTrack: "WEBVTT\n\n00:01.5x --> 00:02.000\nText\n", ExpectError: ErrInvalidSubtitles},
+		{Name: "Cue ending before its start", GivenTrack: "WEBVTT\n\n00:02.000 --> 00:01.000\nText\n", ExpectError: ErrInvalidSubtitles},
+		{Name: "No cue", GivenTrack: "WEBVTT\n", ExpectError: ErrInvalidSubtitles},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			cues, err := Parse([]byte(tt.GivenTrack))
+			require.ErrorIs(t, err, tt.ExpectError)
+			require.Equal(t, tt.ExpectCues, cues)
+		})
+	}
+}
+
+func Test_Format(t *testing.T) {
+	cues := []Cue{{Start: 1.5, End: 3, Text: "Tom & <b>Jerry</b>"}, {Start: 3723.004, End: 3724, Text: "Bye"}}
+
+	vtt := FormatVTT(cues)
+	require.Equal(t, "WEBVTT\n\n00:00:01.500 --> 00:00:03.000\nTom &amp; <b>Jerry</b>\n\n01:02:03.004 --> 01:02:04.000\nBye\n", string(vtt))
+	parsed, err := Parse(vtt)
+	require.NoError(t, err)
+	require.Equal(t, cues, parsed)
+
+	require.Equal(t, "1\n00:00:01,500 --> 00:00:03,000\nTom & <b>Jerry</b>\n\n2\n01:02:03,004 --> 01:02:04,000\nBye\n\n", string(FormatSRT(cues)))
+	require.Equal(t, cues[1:], Window(cues, 3, 3800))
+}
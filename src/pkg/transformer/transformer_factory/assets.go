@@ -0,0 +1,115 @@
+package transformer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/rishirishhh/vought/src/pkg/clients"
+)
+
+// Delay during which a local copy of an asset is used without checking it on S3
+const assetRefreshInterval = time.Minute
+
+// assetCache keeps local copies of the S3 objects read by the filter graphs, such as watermark
+// images, ffmpeg opening them by path. A copy is replaced when its ETag changes on S3.
+type assetCache struct {
+	s3Client clients.IS3Client
+	mutex    sync.Mutex
+	dir      string
+	assets   map[string]*asset
+}
+
+type asset struct {
+	path      string
+	etag      string
+	checkedAt time.Time
+}
+
+func newAssetCache(s3Client clients.IS3Client) *assetCache {
+	return &assetCache{s3Client: s3Client, assets: map[string]*asset{}}
+}
+
+// Get the path of the local copy of the object at key, downloading it if needed
+func (c *assetCache) fetch(ctx context.Context, key string) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cached, ok := c.assets[key]
+	if ok && time.Since(cached.checkedAt) < assetRefreshInterval {
+		return cached.path, nil
+	}
+
+	head, err := c.s3Client.HeadObject(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if ok && head.ETag == cached.etag {
+		cached.checkedAt = time.Now()
+		return cached.path, nil
+	}
+
+	if c.dir == "" {
+		dir, err := os.MkdirTemp("", "vought-assets-")
+		if err != nil {
+			return "", err
+		}
+		c.dir = dir
+	}
+
+	// The copy is replaced atomically, running commands keep reading the previous one
+	sum := sha256.Sum256([]byte(key))
+	assetPath := filepath.Join(c.dir, hex.EncodeToString(sum[:8])+path.Ext(key))
+	if err := c.download(ctx, key, assetPath); err != nil {
+		return "", err
+	}
+	log.Debug("Fetched asset ", key, " into ", assetPath)
+
+	c.assets[key] = &asset{path: assetPath, etag: head.ETag, checkedAt: time.Now()}
+	return assetPath, nil
+}
+
+func (c *assetCache) download(ctx context.Context, key string, assetPath string) error {
+	object, err := c.s3Client.GetObject(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+
+	file, err := os.CreateTemp(c.dir, "download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := io.Copy(file, object); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), assetPath)
+}
+
+// Remove the local copies
+func (c *assetCache) close() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.dir != "" {
+		if err := os.RemoveAll(c.dir); err != nil {
+			log.Error("Cannot remove assets directory : ", err)
+		}
+	}
+	c.dir = ""
+	c.assets = map[string]*asset{}
+}
@@ -0,0 +1,697 @@
+package transformer
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/rishirishhh/vought/src/pkg/subtitles"
+	transformerv2 "github.com/rishirishhh/vought/src/pkg/transformer/v2"
+)
+
+// Filter is a transformation of the catalogue, run as an ffmpeg video filter graph
+type Filter struct {
+	Name        string
+	Version     string
+	Description string
+	Parameters  []*transformerv2.ParameterSpec
+	// Check the parameters depending on each other, the specs have been checked already
+	Validate func(p parameters) error
+	// Prepare what the graph needs besides the parameters, such as local files, by name
+	Prepare func(p parameters, env chainEnv) (map[string]string, error)
+	// Create the ffmpeg filter graph of a step
+	Graph func(p parameters) string
+	// The graph depends on the principal of the request, its output must not be shared
+	PerViewer bool
+	// The graph filters the audio stream instead of the video stream
+	Audio bool
+}
+
+// Filters served by the transformers, looked up by name. Filters must keep the duration of the
+// segments, which is why none changes the speed: the playlists would not match the parts anymore.
+var filterCatalogue = []Filter{
+	{
+		Name:        "gray",
+		Version:     "1.1.0",
+		Description: "Convert the video to shades of gray",
+		Graph:       func(p parameters) string { return "hue=s=0" },
+	},
+	{
+		Name:        "flip",
+		Version:     "1.1.0",
+		Description: "Flip the video upside down",
+		Graph:       func(p parameters) string { return "vflip" },
+	},
+	{
+		Name:        "mirror",
+		Version:     "1.0.0",
+		Description: "Mirror the video horizontally",
+		Graph:       func(p parameters) string { return "hflip" },
+	},
+	{
+		Name:        "rotate",
+		Version:     "1.0.0",
+		Description: "Rotate the video clockwise, the frame is enlarged to fit the rotated video",
+		Parameters: []*transformerv2.ParameterSpec{
+			doubleSpec("angle", "Angle of the rotation, in degrees", 90, -360, 360),
+		},
+		Graph: func(p parameters) string {
+			angle := formatNumber(p.double("angle") * math.Pi / 180)
+			return evenSize(fmt.Sprintf("rotate=a=%v:ow=rotw(%v):oh=roth(%v)", angle, angle, angle))
+		},
+	},
+	{
+		Name:        "blur",
+		Version:     "1.0.0",
+		Description: "Blur the video with a gaussian blur",
+		Parameters: []*transformerv2.ParameterSpec{
+			doubleSpec("radius", "Standard deviation of the blur, in pixels", 5, 0.5, 50),
+		},
+		Graph: func(p parameters) string {
+			return "gblur=sigma=" + formatNumber(p.double("radius"))
+		},
+	},
+	{
+		Name:        "sharpen",
+		Version:     "1.0.0",
+		Description: "Sharpen the video with an unsharp mask",
+		Parameters: []*transformerv2.ParameterSpec{
+			doubleSpec("amount", "Strength of the sharpening", 1, 0, 5),
+		},
+		Graph: func(p parameters) string {
+			return "unsharp=luma_msize_x=5:luma_msize_y=5:luma_amount=" + formatNumber(p.double("amount"))
+		},
+	},
+	{
+		Name:        "brightness",
+		Version:     "1.0.0",
+		Description: "Adjust the brightness and the contrast of the video",
+		Parameters: []*transformerv2.ParameterSpec{
+			doubleSpec("brightness", "Brightness added to the video, 0 keeps it unchanged", 0, -1, 1),
+			doubleSpec("contrast", "Contrast multiplier, 1 keeps it unchanged", 1, 0, 3),
+		},
+		Graph: func(p parameters) string {
+			return fmt.Sprintf("eq=brightness=%v:contrast=%v", formatNumber(p.double("brightness")), formatNumber(p.double("contrast")))
+		},
+	},
+	{
+		Name:        "sepia",
+		Version:     "1.0.0",
+		Description: "Give the video a sepia tone",
+		Parameters: []*transformerv2.ParameterSpec{
+			doubleSpec("intensity", "Intensity of the tone, 0 keeps the video unchanged", 1, 0, 1),
+		},
+		Graph: func(p parameters) string {
+			// Mix of the sepia matrix and of the identity
+			i := p.double("intensity")
+			sepia := [3][3]float64{{0.393, 0.769, 0.189}, {0.349, 0.686, 0.168}, {0.272, 0.534, 0.131}}
+			coefficients := []interface{}{}
+			for row := range sepia {
+				for column := range sepia[row] {
+					identity := 0.0
+					if row == column {
+						identity = 1
+					}
+					coefficients = append(coefficients, formatNumber(i*sepia[row][column]+(1-i)*identity))
+				}
+			}
+			return fmt.Sprintf("colorchannelmixer=rr=%v:rg=%v:rb=%v:gr=%v:gg=%v:gb=%v:br=%v:bg=%v:bb=%v", coefficients...)
+		},
+	},
+	{
+		Name:        "crop",
+		Version:     "1.0.0",
+		Description: "Crop the video to a box, given as fractions of the frame so that every quality is cropped alike",
+		Parameters: []*transformerv2.ParameterSpec{
+			doubleSpec("x", "Left side of the box", 0, 0, 1),
+			doubleSpec("y", "Top side of the box", 0, 0, 1),
+			doubleSpec("width", "Width of the box", 1, 0.05, 1),
+			doubleSpec("height", "Height of the box", 1, 0.05, 1),
+		},
+		Validate: func(p parameters) error {
+			if p.double("x")+p.double("width") > 1 || p.double("y")+p.double("height") > 1 {
+				return fmt.Errorf("the box exceeds the frame")
+			}
+			return nil
+		},
+		Graph: func(p parameters) string {
+			return evenSize(fmt.Sprintf("crop=w=iw*%v:h=ih*%v:x=iw*%v:y=ih*%v",
+				formatNumber(p.double("width")), formatNumber(p.double("height")), formatNumber(p.double("x")), formatNumber(p.double("y"))))
+		},
+	},
+	{
+		Name:        "scale",
+		Version:     "1.0.0",
+		Description: "Scale the video, the aspect ratio is kept if only one dimension is given",
+		Parameters: []*transformerv2.ParameterSpec{
+			intSpec("width", "Width of the video, in pixels", 16, 7680),
+			intSpec("height", "Height of the video, in pixels", 16, 4320),
+		},
+		Validate: func(p parameters) error {
+			if !p.isSet("width") && !p.isSet("height") {
+				return fmt.Errorf("width or height must be given")
+			}
+			return nil
+		},
+		Graph: func(p parameters) string {
+			// -2 keeps the aspect ratio with an even size
+			width, height := "-2", "-2"
+			if p.isSet("width") {
+				width = strconv.FormatInt(p.int("width"), 10)
+			}
+			if p.isSet("height") {
+				height = strconv.FormatInt(p.int("height"), 10)
+			}
+			return evenSize(fmt.Sprintf("scale=w=%v:h=%v", width, height))
+		},
+	},
+	{
+		Name:        "denoise",
+		Version:     "1.0.0",
+		Description: "Reduce the noise of the video",
+		Parameters: []*transformerv2.ParameterSpec{
+			doubleSpec("strength", "Spatial strength of the denoising", 4, 0, 20),
+		},
+		Graph: func(p parameters) string {
+			return "hqdn3d=luma_spatial=" + formatNumber(p.double("strength"))
+		},
+	},
+	{
+		Name:        "negate",
+		Version:     "1.0.0",
+		Description: "Invert the colors of the video",
+		Graph:       func(p parameters) string { return "negate" },
+	},
+	{
+		Name:        "vignette",
+		Version:     "1.0.0",
+		Description: "Darken the corners of the video",
+		Parameters: []*transformerv2.ParameterSpec{
+			doubleSpec("angle", "Lens angle, in degrees, the larger the darker", 36, 1, 89),
+		},
+		Graph: func(p parameters) string {
+			return "vignette=angle=" + formatNumber(p.double("angle")*math.Pi/180)
+		},
+	},
+	{
+		Name:        "watermark",
+		Version:     "1.0.0",
+		Description: "Overlay an image of the watermarks/ folder of the bucket",
+		Parameters: []*transformerv2.ParameterSpec{
+			{Name: "image", Type: transformerv2.ParameterType_PARAMETER_TYPE_STRING, Description: "File name of the image", Required: true},
+			stringSpec("position", "", "bottom-right", overlayPositions...),
+			doubleSpec("opacity", "", 0.8, 0, 1),
+			doubleSpec("scale", "Width, as a fraction of the video width", 0.15, 0.01, 1),
+		},
+		Validate: func(p parameters) error {
+			if !objectName.MatchString(p.string("image")) {
+				return fmt.Errorf("invalid image name %q", p.string("image"))
+			}
+			return nil
+		},
+		Prepare: func(p parameters, env chainEnv) (map[string]string, error) {
+			image, err := env.fetchAsset(watermarksFolder + p.string("image"))
+			return map[string]string{"image": image}, err
+		},
+		Graph: func(p parameters) string {
+			// The image is a second input of the graph, scaled relatively to the video
+			x, y := overlayPosition(p.string("position"), "W", "H", "w", "h")
+			return fmt.Sprintf("null[%v];movie=filename=%v,format=rgba,colorchannelmixer=aa=%v[%v];[%v][%v]scale2ref=w=main_w*%v:h=ow/a[%v][%v];[%v][%v]overlay=x=%v:y=%v",
+				p.label("base"),
+				p.prepared("image"), formatNumber(p.double("opacity")), p.label("image"),
+				p.label("image"), p.label("base"), formatNumber(p.double("scale")), p.label("scaled"), p.label("video"),
+				p.label("video"), p.label("scaled"), x, y)
+		},
+	},
+	{
+		Name:        "forensic",
+		Version:     "1.0.0",
+		Description: "Burn in the viewer, the playback session and the time, to trace leaked videos",
+		Parameters: []*transformerv2.ParameterSpec{
+			boolSpec("viewer", "", true),
+			boolSpec("session", "", true),
+			boolSpec("time", "", true),
+			stringSpec("position", "", "moving", "top-left", "top-right", "bottom-left", "bottom-right", "center", "moving"),
+			doubleSpec("opacity", "", 0.4, 0, 1),
+			doubleSpec("size", "Text height, fraction of the video height", 0.03, 0.01, 0.2),
+		},
+		Validate: func(p parameters) error {
+			if !p.bool("viewer") && !p.bool("session") && !p.bool("time") {
+				return fmt.Errorf("nothing to burn in")
+			}
+			return nil
+		},
+		Graph: func(p parameters) string {
+			text := []string{}
+			if p.bool("viewer") {
+				viewer := sanitizeText(p.principal.GetViewerId())
+				if viewer == "" {
+					viewer = "anonymous"
+				}
+				text = append(text, "viewer "+viewer)
+			}
+			if p.bool("session") && p.principal.GetSessionId() != "" {
+				text = append(text, "session "+sanitizeText(p.principal.GetSessionId()))
+			}
+			if p.bool("time") {
+				// Expanded by drawtext to the time the frame is rendered
+				text = append(text, "%{gmtime} UTC")
+			}
+
+			x, y := overlayPosition(p.string("position"), "w", "h", "tw", "th")
+			opacity := formatNumber(p.double("opacity"))
+			return fmt.Sprintf("drawtext=text='%v':fontsize=h*%v:fontcolor=white@%v:shadowcolor=black@%v:shadowx=1:shadowy=1:x='%v':y='%v'",
+				strings.Join(text, "  "), formatNumber(p.double("size")), opacity, opacity, x, y)
+		},
+		PerViewer: true,
+	},
+	{
+		Name:        "subtitles",
+		Version:     "1.0.0",
+		Description: "Burn in a subtitle track attached to the video",
+		Parameters: []*transformerv2.ParameterSpec{
+			{Name: "track", Type: transformerv2.ParameterType_PARAMETER_TYPE_STRING, Description: "Name of the track", Required: true},
+			stringSpec("position", "", "bottom", "bottom", "top"),
+			doubleSpec("size", "Text height, fraction of the video height", 0.055, 0.02, 0.2),
+		},
+		Validate: func(p parameters) error {
+			if !subtitles.IsValidTrack(p.string("track")) {
+				return fmt.Errorf("invalid track name %q", p.string("track"))
+			}
+			return nil
+		},
+		Prepare: func(p parameters, env chainEnv) (map[string]string, error) {
+			segment, err := env.segment()
+			if err != nil {
+				return nil, err
+			}
+			track, err := env.readObject(subtitles.TrackKey(segment.videoID, p.string("track")))
+			if err != nil {
+				return nil, err
+			}
+			cues, err := subtitles.Parse(track)
+			if err != nil {
+				return nil, err
+			}
+
+			// Cues of the segment, moved to the timestamps of its frames
+			cues = subtitles.Window(cues, segment.start, segment.end)
+			if len(cues) == 0 {
+				return map[string]string{}, nil
+			}
+			for i := range cues {
+				cues[i].Start += segment.origin
+				cues[i].End += segment.origin
+			}
+			file, err := env.tempFile("subtitles-*.srt", subtitles.FormatSRT(cues))
+			return map[string]string{"cues": file}, err
+		},
+		Graph: func(p parameters) string {
+			if p.prepared("cues") == "" {
+				return "null"
+			}
+			// Bottom or top center, libass sizes the text relatively to a frame 288 lines high
+			alignment := 2
+			if p.string("position") == "top" {
+				alignment = 8
+			}
+			return fmt.Sprintf("subtitles=filename=%v:force_style='FontSize=%v,Alignment=%v'", p.prepared("cues"), formatNumber(math.Round(p.double("size")*288)), alignment)
+		},
+	},
+	{
+		Name:        "compose",
+		Version:     "1.0.0",
+		Description: "Compose the video with the same time window of another video",
+		Parameters: []*transformerv2.ParameterSpec{
+			{Name: "video", Type: transformerv2.ParameterType_PARAMETER_TYPE_STRING, Required: true, Format: transformerv2.FormatVideoID},
+			stringSpec("layout", "", "side-by-side", "side-by-side", "wipe", "pip-top-left", "pip-top-right", "pip-bottom-left", "pip-bottom-right"),
+			// Start in the other video in seconds, width of the picture in picture, position of
+			// the wipe. The schema is kept within the Consul metadata limit without descriptions.
+			doubleSpec("offset", "", 0, 0, 86400),
+			doubleSpec("scale", "", 0.3, 0.1, 0.5),
+			doubleSpec("split", "", 0.5, 0.05, 0.95),
+		},
+		Validate: func(p parameters) error {
+			if !objectName.MatchString(p.string("video")) {
+				return fmt.Errorf("invalid video ID %q", p.string("video"))
+			}
+			return nil
+		},
+		Prepare: func(p parameters, env chainEnv) (map[string]string, error) {
+			segment, err := env.segment()
+			if err != nil {
+				return nil, err
+			}
+			offset := p.double("offset")
+			window, err := env.window(p.string("video"), segment.rendition, segment.start+offset, segment.end+offset)
+			if err != nil {
+				return nil, err
+			}
+			if len(window.segments) == 0 {
+				return map[string]string{}, nil
+			}
+
+			// Segments of a rendition are parts of one transport stream, they are concatenated
+			other := []byte{}
+			for _, key := range window.segments {
+				data, err := env.readObject(key)
+				if err != nil {
+					return nil, err
+				}
+				other = append(other, data...)
+			}
+			file, err := env.tempFile("compose-*.ts", other)
+
+			// The frames of the other video are moved to the timestamps of the frames shown
+			// along with them
+			shift := segment.origin - window.origin - offset
+			return map[string]string{"other": file, "shift": formatNumber(shift)}, err
+		},
+		Graph: func(p parameters) string {
+			layout := p.string("layout")
+			if p.prepared("other") == "" {
+				// Beyond the end of the other video, side by side keeps the size of the frame
+				if layout == "side-by-side" {
+					return "pad=w=iw*2:h=ih"
+				}
+				return "null"
+			}
+
+			base := "null"
+			if layout == "side-by-side" {
+				base = "pad=w=iw*2:h=ih"
+			}
+			other := fmt.Sprintf("%v[%v];movie=filename=%v,setpts=PTS+(%v)/TB[%v];[%v][%v]",
+				base, p.label("base"), p.prepared("other"), p.prepared("shift"), p.label("other"), p.label("other"), p.label("base"))
+			scaled := fmt.Sprintf("[%v][%v]", p.label("scaled"), p.label("video"))
+
+			switch {
+			case strings.HasPrefix(layout, "pip-"):
+				x, y := overlayPosition(strings.TrimPrefix(layout, "pip-"), "W", "H", "w", "h")
+				return fmt.Sprintf("%vscale2ref=w=trunc(main_w*%v/2)*2:h=trunc(ow/a/2)*2%v;[%v][%v]overlay=x=%v:y=%v:eof_action=pass",
+					other, formatNumber(p.double("scale")), scaled, p.label("video"), p.label("scaled"), x, y)
+			case layout == "wipe":
+				split := formatNumber(p.double("split"))
+				return fmt.Sprintf("%vscale2ref=w=main_w:h=main_h%v;[%v]crop=w=iw*(1-%v):h=ih:x=iw*%v:y=0[%v];[%v][%v]overlay=x=W*%v:y=0:eof_action=pass,drawbox=x=iw*%v-1:y=0:w=2:h=ih:color=white@0.8:t=fill",
+					other, scaled, p.label("scaled"), split, split, p.label("right"), p.label("video"), p.label("right"), split, split)
+			default:
+				return fmt.Sprintf("%vscale2ref=w=main_w/2:h=main_h%v;[%v][%v]overlay=x=W/2:y=0:eof_action=pass",
+					other, scaled, p.label("video"), p.label("scaled"))
+			}
+		},
+	},
+	{
+		Name:        "loudnorm",
+		Version:     "1.0.0",
+		Description: "Normalise the loudness of the audio, each segment being normalised on its own",
+		Parameters: []*transformerv2.ParameterSpec{
+			doubleSpec("target", "Integrated loudness, in LUFS", -16, -70, -5),
+			doubleSpec("peak", "Maximum true peak, in dBTP", -1.5, -9, 0),
+			doubleSpec("range", "Loudness range, in LU", 11, 1, 50),
+		},
+		Audio: true,
+		Graph: func(p parameters) string {
+			// loudnorm upsamples the audio to 192 kHz
+			return fmt.Sprintf("loudnorm=I=%v:TP=%v:LRA=%v,aresample=48000", formatNumber(p.double("target")), formatNumber(p.double("peak")), formatNumber(p.double("range")))
+		},
+	},
+	{
+		Name:        "volume",
+		Version:     "1.0.0",
+		Description: "Change the volume of the audio",
+		Parameters: []*transformerv2.ParameterSpec{
+			doubleSpec("gain", "Gain, in dB", 0, -30, 30),
+		},
+		Audio: true,
+		Graph: func(p parameters) string {
+			return fmt.Sprintf("volume=%vdB", formatNumber(p.double("gain")))
+		},
+	},
+	{
+		Name:        "mute",
+		Version:     "1.0.0",
+		Description: "Silence the audio",
+		Audio:       true,
+		Graph:       func(p parameters) string { return "volume=0" },
+	},
+	{
+		Name:        "downmix",
+		Version:     "1.0.0",
+		Description: "Downmix the audio channels to stereo or mono",
+		Parameters: []*transformerv2.ParameterSpec{
+			stringSpec("layout", "", "stereo", "stereo", "mono"),
+		},
+		Audio: true,
+		Graph: func(p parameters) string {
+			return "aformat=channel_layouts=" + p.string("layout")
+		},
+	},
+	{
+		Name:        "bleep",
+		Version:     "1.0.0",
+		Description: "Replace time ranges of the audio with a tone",
+		Parameters: []*transformerv2.ParameterSpec{
+			{Name: "ranges", Type: transformerv2.ParameterType_PARAMETER_TYPE_STRING, Required: true, Description: "start-end in seconds of the video, separated by ;"},
+			doubleSpec("frequency", "Frequency of the tone, in Hz", 1000, 200, 4000),
+		},
+		Audio: true,
+		Validate: func(p parameters) error {
+			_, err := parseRanges(p.string("ranges"))
+			return err
+		},
+		Prepare: func(p parameters, env chainEnv) (map[string]string, error) {
+			segment, err := env.segment()
+			if err != nil {
+				return nil, err
+			}
+			ranges, _ := parseRanges(p.string("ranges"))
+
+			// Samples keep their timestamps, the time t of the video is heard at origin + t
+			conditions := []string{}
+			for _, r := range ranges {
+				if r[1] > segment.start && r[0] < segment.end {
+					conditions = append(conditions, fmt.Sprintf("between(t,%v,%v)", formatNumber(segment.origin+r[0]), formatNumber(segment.origin+r[1])))
+				}
+			}
+			return map[string]string{"condition": strings.Join(conditions, "+")}, nil
+		},
+		Graph: func(p parameters) string {
+			if p.prepared("condition") == "" {
+				return "anull"
+			}
+			return fmt.Sprintf("aeval='if(%v,0.2*sin(2*PI*%v*t),val(ch))':c=same", p.prepared("condition"), formatNumber(p.double("frequency")))
+		},
+	},
+}
+
+// Parse time ranges, start-end in seconds separated by ;
+func parseRanges(raw string) ([][2]float64, error) {
+	ranges := [][2]float64{}
+	for _, field := range strings.Split(raw, ";") {
+		rawStart, rawEnd, ok := strings.Cut(strings.TrimSpace(field), "-")
+		start, startErr := strconv.ParseFloat(rawStart, 64)
+		end, endErr := strconv.ParseFloat(rawEnd, 64)
+		if !ok || startErr != nil || endErr != nil || start < 0 || end <= start {
+			return nil, fmt.Errorf("invalid range %q", field)
+		}
+		ranges = append(ranges, [2]float64{start, end})
+	}
+	return ranges, nil
+}
+
+// Watermark images are read from this folder only, so that any object of the bucket cannot be
+// overlaid
+const watermarksFolder = "watermarks/"
+
+// Names of the objects a parameter refers to, they cannot leave their folder
+var objectName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+var overlayPositions = []string{"top-left", "top-right", "bottom-left", "bottom-right", "center"}
+
+// Expressions of the position of an overlay of size w x h on a frame of size width x height,
+// with a margin of 2% of the frame height
+func overlayPosition(position string, width string, height string, w string, h string) (string, string) {
+	margin := height + "*0.02"
+	switch position {
+	case "top-left":
+		return margin, margin
+	case "top-right":
+		return fmt.Sprintf("%v-%v-%v", width, w, margin), margin
+	case "bottom-left":
+		return margin, fmt.Sprintf("%v-%v-%v", height, h, margin)
+	case "center":
+		return fmt.Sprintf("(%v-%v)/2", width, w), fmt.Sprintf("(%v-%v)/2", height, h)
+	case "moving":
+		// Bounce across the frame, so that the overlay cannot be cropped out
+		return fmt.Sprintf("(%v-%v)*abs(mod(t/20,2)-1)", width, w), fmt.Sprintf("(%v-%v)*abs(mod(t/13,2)-1)", height, h)
+	default:
+		return fmt.Sprintf("%v-%v-%v", width, w, margin), fmt.Sprintf("%v-%v-%v", height, h, margin)
+	}
+}
+
+// Keep the characters that need no escaping in a filter graph, the others are replaced
+func sanitizeText(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("@._-", r)) {
+			return r
+		}
+		return '_'
+	}, text)
+}
+
+// Get the filters of the catalogue by name, every filter if no name is given
+func catalogueFilters(names []string) ([]Filter, error) {
+	if len(names) == 0 {
+		return filterCatalogue, nil
+	}
+
+	filters := []Filter{}
+	for _, name := range names {
+		filter, ok := findFilter(filterCatalogue, name)
+		if !ok {
+			return nil, fmt.Errorf("Unknown filter %v", name)
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+func findFilter(filters []Filter, name string) (Filter, bool) {
+	for _, filter := range filters {
+		if filter.Name == name {
+			return filter, true
+		}
+	}
+	return Filter{}, false
+}
+
+// Check the parameters of step, prepare its values and create its filter graph. index is the
+// position of the step in the fused graph.
+func (f Filter) graph(step *transformerv2.Step, index int, env chainEnv) (string, error) {
+	if err := transformerv2.ValidateStep(step, f.Parameters); err != nil {
+		return "", err
+	}
+
+	// The advertised schemas may not tell which parameters are videos, the principal is checked
+	// against the specs of the filter
+	for _, video := range transformerv2.ReferencedVideos(step, f.Parameters) {
+		if !env.principal.CanAccess(video) {
+			return "", status.Errorf(codes.PermissionDenied, "%v cannot read video %v", f.Name, video)
+		}
+	}
+
+	p := parameters{step: step, specs: f.Parameters, index: index, principal: env.principal}
+	if f.Validate != nil {
+		if err := f.Validate(p); err != nil {
+			return "", fmt.Errorf("%w : %v : %v", transformerv2.ErrInvalidParameter, f.Name, err)
+		}
+	}
+
+	if f.Prepare != nil {
+		prepared, err := f.Prepare(p, env)
+		if err != nil {
+			return "", err
+		}
+		p.preparedValues = prepared
+	}
+	return f.Graph(p), nil
+}
+
+// parameters gives the values of the parameters of a step, or their defaults, and what the step
+// is run with
+type parameters struct {
+	step      *transformerv2.Step
+	specs     []*transformerv2.ParameterSpec
+	index     int
+	principal *transformerv2.Principal
+	// Values made by Filter.Prepare, by name
+	preparedValues map[string]string
+}
+
+func (p parameters) isSet(name string) bool {
+	_, ok := p.step.GetParameters()[name]
+	return ok
+}
+
+func (p parameters) double(name string) float64 {
+	return transformerv2.ParameterValue(p.step, p.specs, name).GetDoubleValue()
+}
+
+func (p parameters) int(name string) int64 {
+	return transformerv2.ParameterValue(p.step, p.specs, name).GetIntValue()
+}
+
+func (p parameters) bool(name string) bool {
+	return transformerv2.ParameterValue(p.step, p.specs, name).GetBoolValue()
+}
+
+func (p parameters) string(name string) string {
+	return transformerv2.ParameterValue(p.step, p.specs, name).GetStringValue()
+}
+
+func (p parameters) prepared(name string) string {
+	return p.preparedValues[name]
+}
+
+// Name a pad of a graph with several inputs, labels must be unique in the fused graph
+func (p parameters) label(name string) string {
+	return fmt.Sprintf("%v%v", name, p.index)
+}
+
+func doubleSpec(name string, description string, defaultValue float64, minimum float64, maximum float64) *transformerv2.ParameterSpec {
+	return &transformerv2.ParameterSpec{
+		Name:         name,
+		Type:         transformerv2.ParameterType_PARAMETER_TYPE_DOUBLE,
+		Description:  description,
+		DefaultValue: &transformerv2.Value{Kind: &transformerv2.Value_DoubleValue{DoubleValue: defaultValue}},
+		Minimum:      proto.Float64(minimum),
+		Maximum:      proto.Float64(maximum),
+	}
+}
+
+func boolSpec(name string, description string, defaultValue bool) *transformerv2.ParameterSpec {
+	return &transformerv2.ParameterSpec{
+		Name:         name,
+		Type:         transformerv2.ParameterType_PARAMETER_TYPE_BOOL,
+		Description:  description,
+		DefaultValue: &transformerv2.Value{Kind: &transformerv2.Value_BoolValue{BoolValue: defaultValue}},
+	}
+}
+
+func stringSpec(name string, description string, defaultValue string, allowedValues ...string) *transformerv2.ParameterSpec {
+	return &transformerv2.ParameterSpec{
+		Name:          name,
+		Type:          transformerv2.ParameterType_PARAMETER_TYPE_STRING,
+		Description:   description,
+		DefaultValue:  &transformerv2.Value{Kind: &transformerv2.Value_StringValue{StringValue: defaultValue}},
+		AllowedValues: allowedValues,
+	}
+}
+
+// Integer parameters without default value
+func intSpec(name string, description string, minimum float64, maximum float64) *transformerv2.ParameterSpec {
+	return &transformerv2.ParameterSpec{
+		Name:        name,
+		Type:        transformerv2.ParameterType_PARAMETER_TYPE_INT,
+		Description: description,
+		Minimum:     proto.Float64(minimum),
+		Maximum:     proto.Float64(maximum),
+	}
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// The encoders need even dimensions, filters changing the size of the frame round it down
+func evenSize(graph string) string {
+	return graph + ",scale=trunc(iw/2)*2:trunc(ih/2)*2"
+}
@@ -0,0 +1,295 @@
+package transformer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/rishirishhh/vought/src/pkg/ffmpeg"
+	transformerv2 "github.com/rishirishhh/vought/src/pkg/transformer/v2"
+)
+
+func Test_FilterGraph(t *testing.T) {
+	cases := []struct {
+		Name          string
+		GivenFilter   string
+		ExpectedGraph string
+		ExpectedErr   error
+	}{
+		{Name: "Gray", GivenFilter: "gray", ExpectedGraph: "hue=s=0"},
+		{Name: "Blur default radius", GivenFilter: "blur", ExpectedGraph: "gblur=sigma=5"},
+		{Name: "Blur radius", GivenFilter: "blur:radius=2.5", ExpectedGraph: "gblur=sigma=2.5"},
+		{Name: "Blur radius out of bounds", GivenFilter: "blur:radius=100", ExpectedErr: transformerv2.ErrInvalidParameter},
+		{Name: "Rotate", GivenFilter: "rotate:angle=180", ExpectedGraph: "rotate=a=3.141592653589793:ow=rotw(3.141592653589793):oh=roth(3.141592653589793),scale=trunc(iw/2)*2:trunc(ih/2)*2"},
+		{Name: "Brightness", GivenFilter: "brightness:contrast=1.5", ExpectedGraph: "eq=brightness=0:contrast=1.5"},
+		{Name: "Sepia identity", GivenFilter: "sepia:intensity=0", ExpectedGraph: "colorchannelmixer=rr=1:rg=0:rb=0:gr=0:gg=1:gb=0:br=0:bg=0:bb=1"},
+		{Name: "Crop", GivenFilter: "crop:x=0.25,width=0.5", ExpectedGraph: "crop=w=iw*0.5:h=ih*1:x=iw*0.25:y=ih*0,scale=trunc(iw/2)*2:trunc(ih/2)*2"},
+		{Name: "Crop box out of frame", GivenFilter: "crop:x=0.75,width=0.5", ExpectedErr: transformerv2.ErrInvalidParameter},
+		{Name: "Scale height", GivenFilter: "scale:height=720", ExpectedGraph: "scale=w=-2:h=720,scale=trunc(iw/2)*2:trunc(ih/2)*2"},
+		{Name: "Scale without size", GivenFilter: "scale", ExpectedErr: transformerv2.ErrInvalidParameter},
+		{Name: "Unknown parameter", GivenFilter: "denoise:sigma=2", ExpectedErr: transformerv2.ErrUnknownParameter},
+		{Name: "Loudness", GivenFilter: "loudnorm:target=-23", ExpectedGraph: "loudnorm=I=-23:TP=-1.5:LRA=11,aresample=48000"},
+		{Name: "Volume", GivenFilter: "volume:gain=-6.5", ExpectedGraph: "volume=-6.5dB"},
+		{Name: "Mute", GivenFilter: "mute", ExpectedGraph: "volume=0"},
+		{Name: "Downmix", GivenFilter: "downmix:layout=mono", ExpectedGraph: "aformat=channel_layouts=mono"},
+		{Name: "Bleep reversed range", GivenFilter: "bleep:ranges=12-10", ExpectedErr: transformerv2.ErrInvalidParameter},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			name, rawParameters, err := transformerv2.ParseFilter(tt.GivenFilter)
+			require.NoError(t, err)
+			filter, ok := findFilter(filterCatalogue, name)
+			require.True(t, ok)
+
+			// Steps are typed without being validated, as a v2 client may send them
+			typeSpecs := []*transformerv2.ParameterSpec{}
+			for _, spec := range filter.Parameters {
+				typeSpecs = append(typeSpecs, &transformerv2.ParameterSpec{Name: spec.GetName(), Type: spec.GetType()})
+			}
+			step, err := transformerv2.NewStep(name, rawParameters, typeSpecs)
+			if err != nil {
+				require.ErrorIs(t, err, tt.ExpectedErr)
+				return
+			}
+
+			graph, err := filter.graph(step, 0, chainEnv{})
+			require.ErrorIs(t, err, tt.ExpectedErr)
+			require.Equal(t, tt.ExpectedGraph, graph)
+		})
+	}
+}
+
+func Test_FilterCatalogue(t *testing.T) {
+	transformer, err := GetTransformer(nil, nil, nil, Limits{})
+	require.NoError(t, err)
+
+	names := map[string]bool{}
+	for _, infos := range transformer.Infos() {
+		require.False(t, names[infos.Name], "%v is declared twice", infos.Name)
+		names[infos.Name] = true
+
+		// Consul limits metadata values to 512 characters
+		require.LessOrEqual(t, len(infos.Schema), 512, infos.Name)
+		require.LessOrEqual(t, len(infos.Description), 512, infos.Name)
+
+		description, err := transformer.Describe(infos.Name)
+		require.NoError(t, err)
+		require.Equal(t, infos.Name, description.GetName())
+		require.NotEmpty(t, description.GetOutputFormats())
+	}
+
+	_, err = GetTransformer([]string{"gray", "unknown"}, nil, nil, Limits{})
+	require.Error(t, err)
+
+	gray, err := GetTransformer([]string{"gray"}, nil, nil, Limits{})
+	require.NoError(t, err)
+	description, err := gray.Describe("")
+	require.NoError(t, err)
+	require.Equal(t, "gray", description.GetName())
+}
+
+func Test_PlanChain(t *testing.T) {
+	served, err := catalogueFilters([]string{"gray", "flip", "blur", "volume"})
+	require.NoError(t, err)
+
+	steps := func(names ...string) []*transformerv2.Step {
+		s := []*transformerv2.Step{}
+		for _, name := range names {
+			s = append(s, &transformerv2.Step{Name: name})
+		}
+		return s
+	}
+
+	cases := []struct {
+		Name              string
+		GivenSteps        []*transformerv2.Step
+		ExpectedGraphs    ffmpeg.FilterGraphs
+		ExpectedRemaining []string
+	}{
+		{Name: "Single step", GivenSteps: steps("gray"), ExpectedGraphs: ffmpeg.FilterGraphs{Video: "hue=s=0"}, ExpectedRemaining: []string{}},
+		{Name: "Fused chain", GivenSteps: steps("gray", "blur", "flip"), ExpectedGraphs: ffmpeg.FilterGraphs{Video: "hue=s=0,gblur=sigma=5,vflip"}, ExpectedRemaining: []string{}},
+		{Name: "Steps served elsewhere", GivenSteps: steps("gray", "mirror", "blur", "flip"), ExpectedGraphs: ffmpeg.FilterGraphs{Video: "gblur=sigma=5,vflip"}, ExpectedRemaining: []string{"gray", "mirror"}},
+		{Name: "Audio and video steps", GivenSteps: steps("gray", "volume", "flip"), ExpectedGraphs: ffmpeg.FilterGraphs{Video: "hue=s=0,vflip", Audio: "volume=0dB"}, ExpectedRemaining: []string{}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			graphs, remaining, err := planChain(served, tt.GivenSteps, chainEnv{})
+			require.NoError(t, err)
+			require.Equal(t, tt.ExpectedGraphs, graphs)
+
+			remainingNames := []string{}
+			for _, step := range remaining {
+				remainingNames = append(remainingNames, step.GetName())
+			}
+			require.Equal(t, tt.ExpectedRemaining, remainingNames)
+		})
+	}
+
+	_, _, err = planChain(served, []*transformerv2.Step{{Name: "blur", Parameters: map[string]*transformerv2.Value{"radius": {Kind: &transformerv2.Value_DoubleValue{DoubleValue: 100}}}}}, chainEnv{})
+	require.ErrorIs(t, err, transformerv2.ErrInvalidParameter)
+}
+
+func Test_OverlayGraph(t *testing.T) {
+	served, err := catalogueFilters([]string{"gray", "watermark", "forensic", "subtitles", "compose"})
+	require.NoError(t, err)
+
+	tracks := map[string]string{
+		"1234/subtitles/en.vtt": "WEBVTT\n\n00:01.000 --> 00:02.000\nBefore\n\n00:05.000 --> 00:07.000\nHello\n\n00:11.500 --> 00:13.000\nBye\n\n00:20.000 --> 00:21.000\nAfter\n",
+		"1234/subtitles/fr.vtt": "WEBVTT\n\n00:20.000 --> 00:21.000\nPlus tard\n",
+	}
+	fetched := []string{}
+	written := ""
+	env := chainEnv{
+		principal: &transformerv2.Principal{ViewerId: "alice's laptop", SessionId: "0123abcd"},
+		fetchAsset: func(key string) (string, error) {
+			fetched = append(fetched, key)
+			return "/tmp/assets/logo.png", nil
+		},
+		readObject: func(key string) ([]byte, error) {
+			fetched = append(fetched, key)
+			return []byte(tracks[key]), nil
+		},
+		segment: func() (segmentTiming, error) {
+			return segmentTiming{videoID: "1234", rendition: "v1", start: 6, end: 12, origin: 1.4}, nil
+		},
+		window: func(videoID string, rendition string, start float64, end float64) (renditionWindow, error) {
+			if videoID != "5678" || start >= 20 {
+				return renditionWindow{origin: 0.9}, nil
+			}
+			return renditionWindow{segments: []string{videoID + "/" + rendition + "/segment1.ts", videoID + "/" + rendition + "/segment2.ts"}, origin: 0.9}, nil
+		},
+		tempFile: func(pattern string, content []byte) (string, error) {
+			written = string(content)
+			return "/tmp/" + strings.Replace(pattern, "-*", "", 1), nil
+		},
+	}
+	tracks["5678/v1/segment1.ts"], tracks["5678/v1/segment2.ts"] = "first", "second"
+	stringValue := func(s string) *transformerv2.Value {
+		return &transformerv2.Value{Kind: &transformerv2.Value_StringValue{StringValue: s}}
+	}
+	doubleValue := func(d float64) *transformerv2.Value {
+		return &transformerv2.Value{Kind: &transformerv2.Value_DoubleValue{DoubleValue: d}}
+	}
+
+	cases := []struct {
+		Name            string
+		GivenSteps      []*transformerv2.Step
+		ExpectedGraph   string
+		ExpectedFetched []string
+		ExpectedFile    string
+		ExpectedErr     error
+	}{
+		{
+			Name:            "Watermark fused after a filter",
+			GivenSteps:      []*transformerv2.Step{{Name: "gray"}, {Name: "watermark", Parameters: map[string]*transformerv2.Value{"image": stringValue("logo.png"), "position": stringValue("top-left")}}},
+			ExpectedGraph:   "hue=s=0,null[base1];movie=filename=/tmp/assets/logo.png,format=rgba,colorchannelmixer=aa=0.8[image1];[image1][base1]scale2ref=w=main_w*0.15:h=ow/a[scaled1][video1];[video1][scaled1]overlay=x=H*0.02:y=H*0.02",
+			ExpectedFetched: []string{"watermarks/logo.png"},
+		},
+		{
+			Name:            "Watermark outside of its folder",
+			GivenSteps:      []*transformerv2.Step{{Name: "watermark", Parameters: map[string]*transformerv2.Value{"image": stringValue("../1234/part.ts")}}},
+			ExpectedFetched: []string{},
+			ExpectedErr:     transformerv2.ErrInvalidParameter,
+		},
+		{
+			Name:            "Forensic text of the principal",
+			GivenSteps:      []*transformerv2.Step{{Name: "forensic", Parameters: map[string]*transformerv2.Value{"position": stringValue("center")}}},
+			ExpectedGraph:   "drawtext=text='viewer alice_s_laptop  session 0123abcd  %{gmtime} UTC':fontsize=h*0.03:fontcolor=white@0.4:shadowcolor=black@0.4:shadowx=1:shadowy=1:x='(w-tw)/2':y='(h-th)/2'",
+			ExpectedFetched: []string{},
+		},
+		{
+			Name:            "Subtitles of the segment",
+			GivenSteps:      []*transformerv2.Step{{Name: "subtitles", Parameters: map[string]*transformerv2.Value{"track": stringValue("en"), "position": stringValue("top")}}},
+			ExpectedGraph:   "subtitles=filename=/tmp/subtitles.srt:force_style='FontSize=16,Alignment=8'",
+			ExpectedFetched: []string{"1234/subtitles/en.vtt"},
+			ExpectedFile:    "1\n00:00:06,400 --> 00:00:08,400\nHello\n\n2\n00:00:12,900 --> 00:00:14,400\nBye\n\n",
+		},
+		{
+			Name:            "No subtitles in the segment",
+			GivenSteps:      []*transformerv2.Step{{Name: "subtitles", Parameters: map[string]*transformerv2.Value{"track": stringValue("fr")}}},
+			ExpectedGraph:   "null",
+			ExpectedFetched: []string{"1234/subtitles/fr.vtt"},
+		},
+		{
+			Name:            "Picture in picture of another video",
+			GivenSteps:      []*transformerv2.Step{{Name: "compose", Parameters: map[string]*transformerv2.Value{"video": stringValue("5678"), "layout": stringValue("pip-bottom-right"), "offset": doubleValue(10)}}},
+			ExpectedGraph:   "null[base0];movie=filename=/tmp/compose.ts,setpts=PTS+(-9.5)/TB[other0];[other0][base0]scale2ref=w=trunc(main_w*0.3/2)*2:h=trunc(ow/a/2)*2[scaled0][video0];[video0][scaled0]overlay=x=W-w-H*0.02:y=H-h-H*0.02:eof_action=pass",
+			ExpectedFetched: []string{"5678/v1/segment1.ts", "5678/v1/segment2.ts"},
+			ExpectedFile:    "firstsecond",
+		},
+		{
+			Name:            "Side by side beyond the end of the other video",
+			GivenSteps:      []*transformerv2.Step{{Name: "gray"}, {Name: "compose", Parameters: map[string]*transformerv2.Value{"video": stringValue("5678"), "offset": doubleValue(20)}}},
+			ExpectedGraph:   "hue=s=0,pad=w=iw*2:h=ih",
+			ExpectedFetched: []string{},
+		},
+		{
+			Name:            "Compose with an invalid video",
+			GivenSteps:      []*transformerv2.Step{{Name: "compose", Parameters: map[string]*transformerv2.Value{"video": stringValue("../watermarks")}}},
+			ExpectedFetched: []string{},
+			ExpectedErr:     transformerv2.ErrInvalidParameter,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			fetched, written = []string{}, ""
+			graphs, _, err := planChain(served, tt.GivenSteps, env)
+			require.ErrorIs(t, err, tt.ExpectedErr)
+			require.Equal(t, tt.ExpectedGraph, graphs.Video)
+			require.Equal(t, tt.ExpectedFetched, fetched)
+			require.Equal(t, tt.ExpectedFile, written)
+		})
+	}
+
+	// Principals bound to a video cannot compose another one, whatever the advertised schema
+	fetched = []string{}
+	env.principal = &transformerv2.Principal{ViewerId: "alice", VideoId: "1234"}
+	_, _, err = planChain(served, []*transformerv2.Step{{Name: "compose", Parameters: map[string]*transformerv2.Value{"video": stringValue("5678")}}}, env)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	require.Empty(t, fetched)
+}
+
+func Test_AudioGraph(t *testing.T) {
+	served, err := catalogueFilters([]string{"bleep"})
+	require.NoError(t, err)
+
+	env := chainEnv{
+		segment: func() (segmentTiming, error) {
+			return segmentTiming{videoID: "1234", start: 6, end: 12, origin: 1.4}, nil
+		},
+	}
+	stringValue := func(s string) *transformerv2.Value {
+		return &transformerv2.Value{Kind: &transformerv2.Value_StringValue{StringValue: s}}
+	}
+
+	cases := []struct {
+		Name          string
+		GivenSteps    []*transformerv2.Step
+		ExpectedGraph string
+	}{
+		{
+			Name:          "Bleep ranges of the segment",
+			GivenSteps:    []*transformerv2.Step{{Name: "bleep", Parameters: map[string]*transformerv2.Value{"ranges": stringValue("1-2;5.5-6.5;11-13")}}},
+			ExpectedGraph: "aeval='if(between(t,6.9,7.9)+between(t,12.4,14.4),0.2*sin(2*PI*1000*t),val(ch))':c=same",
+		},
+		{
+			Name:          "No range in the segment",
+			GivenSteps:    []*transformerv2.Step{{Name: "bleep", Parameters: map[string]*transformerv2.Value{"ranges": stringValue("20-21")}}},
+			ExpectedGraph: "anull",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			graphs, _, err := planChain(served, tt.GivenSteps, env)
+			require.NoError(t, err)
+			require.Equal(t, ffmpeg.FilterGraphs{Audio: tt.ExpectedGraph}, graphs)
+		})
+	}
+}
@@ -1,20 +0,0 @@
-package transformer
-
-import (
-	"github.com/rishirishhh/vought/src/pkg/clients"
-	"github.com/rishirishhh/vought/src/pkg/ffmpeg"
-)
-
-type FlipServer struct {
-	TransformerServer
-}
-
-func newFlipServer(s3Client clients.IS3Client, discoveryClient clients.ServiceDiscovery) ITransformerServer {
-	return &FlipServer{
-		TransformerServer: TransformerServer{
-			DiscoveryClient:         discoveryClient,
-			S3Client:                s3Client,
-			CreateTransformationCmd: ffmpeg.CreateFlipCommand,
-		},
-	}
-}
@@ -0,0 +1,77 @@
+package transformer
+
+import (
+	"errors"
+	"io"
+	"os/exec"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var inFlightTransformations = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "transformer_transformations_in_flight",
+	Help: "The number of transformations running",
+})
+
+var queuedTransformations = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "transformer_transformations_queued",
+	Help: "The number of transformations waiting for a worker",
+})
+
+var rejectedTransformations = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "transformer_transformations_rejected_total",
+	Help: "The total number of transformations rejected because every worker was busy",
+})
+
+var filterDuration = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "transformer_filter_duration_seconds",
+		Help:    "Duration of the successful transformations running a filter, the filters fused in one pass share its duration",
+		Buckets: prometheus.ExponentialBuckets(0.1, 2, 10),
+	},
+	[]string{"filter"},
+)
+
+var transformedBytes = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "transformer_bytes_total",
+		Help: "The total number of bytes of video parts, by direction: in to ffmpeg, out to the clients",
+	},
+	[]string{"direction"},
+)
+
+var ffmpegExits = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "transformer_ffmpeg_exits_total",
+		Help: "The total number of ffmpeg commands run, by exit code. Commands killed on cancellation exit with signal, the ones which could not start with error.",
+	},
+	[]string{"code"},
+)
+
+// Exit code label of the error of an ffmpeg command
+func exitCode(err error) string {
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return "0"
+	case errors.As(err, &exitErr) && exitErr.ExitCode() >= 0:
+		return strconv.Itoa(exitErr.ExitCode())
+	case errors.As(err, &exitErr):
+		return "signal"
+	}
+	return "error"
+}
+
+// countingReader adds the bytes read from reader to counter
+type countingReader struct {
+	reader  io.Reader
+	counter prometheus.Counter
+}
+
+func (r countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.counter.Add(float64(n))
+	return n, err
+}
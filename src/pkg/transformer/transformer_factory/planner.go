@@ -0,0 +1,52 @@
+package transformer
+
+import (
+	"strings"
+
+	"github.com/rishirishhh/vought/src/pkg/ffmpeg"
+	transformerv2 "github.com/rishirishhh/vought/src/pkg/transformer/v2"
+)
+
+// Plan the run of a chain of steps: the last steps whose filters are served are fused into one
+// filter graph per stream, run in a single ffmpeg pass. The previous steps are left to the
+// transformers serving them, their output is the input of the graphs.
+func planChain(filters []Filter, steps []*transformerv2.Step, env chainEnv) (ffmpeg.FilterGraphs, []*transformerv2.Step, error) {
+	first := len(steps)
+	for first > 0 {
+		if _, ok := findFilter(filters, steps[first-1].GetName()); !ok {
+			break
+		}
+		first--
+	}
+
+	videoGraphs, audioGraphs := []string{}, []string{}
+	for index, step := range steps[first:] {
+		filter, _ := findFilter(filters, step.GetName())
+		graph, err := filter.graph(step, index, env)
+		if err != nil {
+			return ffmpeg.FilterGraphs{}, nil, err
+		}
+		if filter.Audio {
+			audioGraphs = append(audioGraphs, graph)
+		} else {
+			videoGraphs = append(videoGraphs, graph)
+		}
+	}
+	graphs := ffmpeg.FilterGraphs{Video: strings.Join(videoGraphs, ","), Audio: strings.Join(audioGraphs, ",")}
+	return graphs, steps[:first], nil
+}
+
+// chainEnv is what the steps of a chain are run with, besides their parameters
+type chainEnv struct {
+	principal *transformerv2.Principal
+	// Fetch an asset from S3, returning the path of its local copy
+	fetchAsset func(key string) (string, error)
+	// Read an object from S3
+	readObject func(key string) ([]byte, error)
+	// Locate the transformed segment in its video
+	segment func() (segmentTiming, error)
+	// Find a time window of a rendition of another video
+	window func(videoID string, rendition string, start float64, end float64) (renditionWindow, error)
+	// Create a file removed once the request is over, returning its path
+	tempFile func(pattern string, content []byte) (string, error)
+}
@@ -0,0 +1,51 @@
+package transformer
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/rishirishhh/vought/src/pkg/transformer/v1"
+	transformerv2 "github.com/rishirishhh/vought/src/pkg/transformer/v2"
+)
+
+var _ transformer.TransformerServiceServer = &v1Server{}
+var _ transformerv2.TransformerServiceServer = &v2Server{}
+
+// v1Server serves the clients sending filter names, run as steps without parameters in the
+// default output format
+type v1Server struct {
+	transformer.UnimplementedTransformerServiceServer
+	transformer ITransformerServer
+}
+
+func (s *v1Server) TransformVideo(args *transformer.TransformVideoRequest, stream transformer.TransformerService_TransformVideoServer) error {
+	log.Debug("Beginning Transformation")
+
+	steps := make([]*transformerv2.Step, 0, len(args.GetTransformerList()))
+	for _, name := range args.GetTransformerList() {
+		steps = append(steps, &transformerv2.Step{Name: name})
+	}
+	request := &transformerv2.TransformVideoRequest{
+		Videopath: args.GetVideopath(),
+		Steps:     steps,
+	}
+
+	return s.transformer.TransformVideo(stream.Context(), request, func(res *transformerv2.TransformVideoResponse) error {
+		return stream.Send(&transformer.TransformVideoResponse{Chunk: res.GetChunk()})
+	})
+}
+
+type v2Server struct {
+	transformerv2.UnimplementedTransformerServiceServer
+	transformer ITransformerServer
+}
+
+func (s *v2Server) TransformVideo(request *transformerv2.TransformVideoRequest, stream transformerv2.TransformerService_TransformVideoServer) error {
+	log.Debug("Beginning Transformation")
+	return s.transformer.TransformVideo(stream.Context(), request, stream.Send)
+}
+
+func (s *v2Server) Describe(ctx context.Context, request *transformerv2.DescribeRequest) (*transformerv2.DescribeResponse, error) {
+	return s.transformer.Describe(request.GetName())
+}
@@ -0,0 +1,164 @@
+package transformer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/rishirishhh/vought/src/pkg/clients"
+	"github.com/rishirishhh/vought/src/pkg/hls"
+)
+
+// Playlist of the segments of a rendition, written by the encoder next to them
+const renditionPlaylist = "segment_index.m3u8"
+
+// Rendition of the lowest quality, every video has it
+const baseRendition = "v0"
+
+// Bytes of the first segment read to find the timestamp of the first frame
+const originProbeSize = 64 * 1024
+
+// Renditions whose origin is remembered, the oldest ones are forgotten beyond
+const maxOrigins = 10000
+
+// segmentTiming places a segment in its video
+type segmentTiming struct {
+	videoID   string
+	rendition string
+	// Window of the segment in the video, in seconds
+	start float64
+	end   float64
+	// Timestamp of the first frame of the video, in seconds. Frames keep their timestamps through
+	// the transformations (-copyts), the time t of the video is shown at origin + t.
+	origin float64
+}
+
+// segmentLocator finds the window of the segments in their video, from the playlist of their
+// rendition. The origins of the renditions never change, they are remembered.
+type segmentLocator struct {
+	s3Client clients.IS3Client
+	mutex    sync.Mutex
+	origins  map[string]float64
+	order    []string
+}
+
+func newSegmentLocator(s3Client clients.IS3Client) *segmentLocator {
+	return &segmentLocator{s3Client: s3Client, origins: map[string]float64{}}
+}
+
+// Locate the segment at videopath, <videoID>/<rendition>/<segment>
+func (l *segmentLocator) locate(ctx context.Context, videopath string) (segmentTiming, error) {
+	rendition, segmentURI := path.Split(videopath)
+	videoID, _, _ := strings.Cut(videopath, "/")
+
+	media, err := l.playlist(ctx, rendition)
+	if err != nil {
+		return segmentTiming{}, err
+	}
+	index, start, ok := media.FindSegment(segmentURI)
+	if !ok {
+		return segmentTiming{}, fmt.Errorf("segment %v is not in the playlist of its rendition", videopath)
+	}
+	origin, err := l.origin(ctx, rendition, media)
+	if err != nil {
+		return segmentTiming{}, err
+	}
+
+	return segmentTiming{
+		videoID:   videoID,
+		rendition: path.Base(rendition),
+		start:     start,
+		end:       start + media.Segments[index].Duration,
+		origin:    origin,
+	}, nil
+}
+
+// renditionWindow is a time window of a rendition
+type renditionWindow struct {
+	// S3 keys of the segments overlapping the window, empty if it is beyond the end of the video
+	segments []string
+	// Timestamp of the first frame of the rendition, in seconds
+	origin float64
+}
+
+// Find the segments of the rendition at the S3 prefix rendition overlapping the window from start
+// to end, in seconds
+func (l *segmentLocator) window(ctx context.Context, rendition string, start float64, end float64) (renditionWindow, error) {
+	media, err := l.playlist(ctx, rendition)
+	if err != nil {
+		return renditionWindow{}, err
+	}
+	origin, err := l.origin(ctx, rendition, media)
+	if err != nil {
+		return renditionWindow{}, err
+	}
+
+	window := renditionWindow{origin: origin}
+	segmentStart := 0.0
+	for _, segment := range media.Segments {
+		segmentEnd := segmentStart + segment.Duration
+		if segmentEnd > start && segmentStart < end {
+			window.segments = append(window.segments, rendition+segment.URI)
+		}
+		segmentStart = segmentEnd
+	}
+	return window, nil
+}
+
+// Read the playlist of the rendition at the S3 prefix rendition
+func (l *segmentLocator) playlist(ctx context.Context, rendition string) (*hls.MediaPlaylist, error) {
+	object, err := l.s3Client.GetObject(ctx, rendition+renditionPlaylist)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read playlist of %v : %w", rendition, err)
+	}
+	defer object.Close()
+
+	media, err := hls.DecodeMedia(object)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read playlist of %v : %w", rendition, err)
+	}
+	if len(media.Segments) == 0 {
+		return nil, fmt.Errorf("playlist of %v has no segment", rendition)
+	}
+	return media, nil
+}
+
+// Timestamp of the first frame of the rendition, read from the beginning of its first segment
+func (l *segmentLocator) origin(ctx context.Context, rendition string, media *hls.MediaPlaylist) (float64, error) {
+	l.mutex.Lock()
+	origin, ok := l.origins[rendition]
+	l.mutex.Unlock()
+	if ok {
+		return origin, nil
+	}
+
+	object, err := l.s3Client.GetObjectRange(ctx, rendition+media.Segments[0].URI, fmt.Sprintf("bytes=0-%d", originProbeSize-1))
+	if err != nil {
+		return 0, fmt.Errorf("cannot read first segment of %v : %w", rendition, err)
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read first segment of %v : %w", rendition, err)
+	}
+	origin, err = hls.FirstVideoPTS(data)
+	if err != nil {
+		return 0, fmt.Errorf("cannot find first timestamp of %v : %w", rendition, err)
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if _, ok := l.origins[rendition]; !ok {
+		l.origins[rendition] = origin
+		l.order = append(l.order, rendition)
+		if len(l.order) > maxOrigins {
+			delete(l.origins, l.order[0])
+			l.order = l.order[1:]
+		}
+	}
+	return origin, nil
+}
@@ -2,35 +2,50 @@ package transformer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
-	"os/exec"
+	"os"
+	"time"
 
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/rishirishhh/vought/src/pkg/clients"
 	"github.com/rishirishhh/vought/src/pkg/ffmpeg"
 	"github.com/rishirishhh/vought/src/pkg/transformer/v1"
+	transformerv2 "github.com/rishirishhh/vought/src/pkg/transformer/v2"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 )
 
 const MAX_CHUNK_SIZE int = 32000
 
 type ITransformerServer interface {
-	StartRPCServer(ctx context.Context, srv transformer.TransformerServiceServer, port uint32) error
-	TransformVideo(ctx context.Context, args *transformer.TransformVideoRequest, stream transformer.TransformerService_TransformVideoServer) error
+	StartRPCServer(ctx context.Context, port uint32) error
+	TransformVideo(ctx context.Context, request *transformerv2.TransformVideoRequest, send func(*transformerv2.TransformVideoResponse) error) error
+	Describe(name string) (*transformerv2.DescribeResponse, error)
+	Infos() []clients.TransformerInfos
 	Stop()
 }
 
+// TransformerServer runs the steps of the filters it serves
 type TransformerServer struct {
-	CreateTransformationCmd func(ctx context.Context) *exec.Cmd
-	DiscoveryClient         clients.ServiceDiscovery
-	S3Client                clients.IS3Client
+	Filters         []Filter
+	DiscoveryClient clients.ServiceDiscovery
+	S3Client        clients.IS3Client
+	assets          *assetCache
+	segments        *segmentLocator
+	Limits          Limits
+	workers         *workerPool
 }
 
-func (t TransformerServer) StartRPCServer(ctx context.Context, srv transformer.TransformerServiceServer, port uint32) error {
+// Serve the v1 and v2 transformer services, v1 requests are run as steps without parameters
+func (t TransformerServer) StartRPCServer(ctx context.Context, port uint32) error {
 	lis, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%v", port))
 	if err != nil {
 		log.Error("failed to listen : ", err)
@@ -40,14 +55,20 @@ func (t TransformerServer) StartRPCServer(ctx context.Context, srv transformer.T
 	grpcServer := grpc.NewServer()
 	defer grpcServer.Stop()
 
+	// Clients watch the health of the server to stop sending it requests
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+
 	// Check for context
 	go func() {
 		<-ctx.Done()
 		log.Info("Gracefully shutdown grpcServer\n")
+		healthServer.Shutdown()
 		grpcServer.Stop()
 	}()
 
-	transformer.RegisterTransformerServiceServer(grpcServer, srv)
+	transformer.RegisterTransformerServiceServer(grpcServer, &v1Server{transformer: t})
+	transformerv2.RegisterTransformerServiceServer(grpcServer, &v2Server{transformer: t})
 	if err := grpcServer.Serve(lis); err != nil {
 		log.Error("Cannot create gRPC server : ", err)
 		return err
@@ -56,94 +77,293 @@ func (t TransformerServer) StartRPCServer(ctx context.Context, srv transformer.T
 	return nil
 }
 
-func (t TransformerServer) TransformVideo(ctx context.Context, args *transformer.TransformVideoRequest, stream transformer.TransformerService_TransformVideoServer) error {
-	// Transformer will start video transformation, remove itself from the list
-	args.TransformerList = args.TransformerList[:len(args.TransformerList)-1]
-	if len(args.TransformerList) == 0 {
+// Transform the video part of request. Once its input has started, the transformation waits for a
+// free worker, it is rejected with RESOURCE_EXHAUSTED if too many are waiting. The next
+// transformers being busy is told with UNAVAILABLE. It stops, killing ffmpeg and the requests sent
+// to the next transformers, when ctx is canceled or after the timeout of the server. Errors are
+// returned as gRPC statuses.
+func (t TransformerServer) TransformVideo(ctx context.Context, request *transformerv2.TransformVideoRequest, send func(*transformerv2.TransformVideoResponse) error) error {
+	// Transformer runs the last steps it serves in one pass, the previous ones are sent to the
+	// next transformer
+	if len(request.GetSteps()) == 0 {
+		return status.Error(codes.InvalidArgument, "no step to run")
+	}
+	last := request.GetSteps()[len(request.GetSteps())-1]
+	if _, ok := findFilter(t.Filters, last.GetName()); !ok {
+		return status.Errorf(codes.InvalidArgument, "filter %v is not served by this transformer", last.GetName())
+	}
+
+	// The deadline of the client is kept if it is sooner, it is propagated to the next transformers
+	if t.Limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.Limits.Timeout)
+		defer cancel()
+	}
+
+	env, cleanup := t.newChainEnv(ctx, request)
+	defer cleanup()
+	graphs, previousSteps, err := planChain(t.Filters, request.GetSteps(), env)
+	if err != nil {
+		// Errors preparing the files have their own status
+		if _, ok := status.FromError(err); ok {
+			return transformError(ctx, err)
+		}
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	log.Debugf("Running %v steps in one pass : video %q, audio %q", len(request.GetSteps())-len(previousSteps), graphs.Video, graphs.Audio)
+
+	format, err := transformerv2.NegotiateFormat(outputFormats(graphs.Audio != ""), request.GetAcceptedFormats())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	output := ffmpeg.TransformOutput{Container: format.GetContainer(), VideoCodec: format.GetVideoCodec(), AudioCodec: format.GetAudioCodec()}
+
+	// The first failure cancels the others: ffmpeg is killed and the stream of the next
+	// transformer is closed
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	group, groupCtx := errgroup.WithContext(runCtx)
+
+	// Whether the output depends on the principal is told to the client, the steps run by the next
+	// transformers tell it the same way
+	perViewer := false
+	for _, step := range request.GetSteps()[len(previousSteps):] {
+		if filter, _ := findFilter(t.Filters, step.GetName()); filter.PerViewer {
+			perViewer = true
+		}
+	}
+
+	var input io.Reader
+	var nextPart *clients.TransformStream
+	if len(previousSteps) == 0 {
 		// Retrieve the video part from aws S3
-		videoPart, err := t.S3Client.GetObject(ctx, args.GetVideopath())
+		videoPart, err := t.S3Client.GetObject(groupCtx, request.GetVideopath())
 		if err != nil {
 			log.Error("Failed to open video on S3 : ", err)
-			return err
+			return transformError(ctx, status.Errorf(codes.FailedPrecondition, "cannot read %v : %v", request.GetVideopath(), err))
 		}
-
-		transformedVideoPartReader, transformedVideoPartWriter := io.Pipe()
-		go func() {
-			defer transformedVideoPartWriter.Close()
-			if err := ffmpeg.TransformHLSPart(t.CreateTransformationCmd(ctx), videoPart, transformedVideoPartWriter); err != nil {
-				log.Error("Cannot run ffmpeg command : ", err)
-			}
-		}()
-
-		return t.sendVideoPartStream(transformedVideoPartReader, stream)
+		defer videoPart.Close()
+		input = videoPart
 
 	} else {
-		// Ask next transformer for videoPart. We will receive it as stream
-		videoPart, err := t.sendToNextTransformer(ctx, args)
+		// Ask next transformer for videoPart. We will receive it as stream, in its default format
+		nextRequest := &transformerv2.TransformVideoRequest{
+			Videopath: request.GetVideopath(),
+			Steps:     previousSteps,
+			Principal: request.GetPrincipal(),
+		}
+		nextPart, err = t.sendToNextTransformer(groupCtx, nextRequest)
 		if err != nil {
 			log.Error("Cannot send to next transformer : ", err)
-			return err
+			return transformError(ctx, nextTransformerError(err))
 		}
+		defer nextPart.Close()
+		perViewer = perViewer || nextPart.PerViewer()
+	}
 
-		// Create transformation command and init a pipe for stdin
-		cmd := t.CreateTransformationCmd(ctx)
-		stdinWriter, err := cmd.StdinPipe()
-		if err != nil {
-			log.Error("Cannot create pipe stdin : ", err)
+	// The worker is taken once the input has started, so that a transformation waiting for the
+	// next transformers does not hold one. The next transformers hold theirs while it waits.
+	release, err := t.workers.acquire(ctx)
+	if err != nil {
+		log.Warn("Transformation of ", request.GetVideopath(), " not started : ", err)
+		return err
+	}
+	defer release()
+	start := time.Now()
+
+	if nextPart != nil {
+		// Receive next transformer response, it is the input of the command
+		inputReader, inputWriter := io.Pipe()
+		group.Go(func() error {
+			err := t.recvVideoPartStream(inputWriter, nextPart)
+			inputWriter.CloseWithError(err)
 			return err
+		})
+		input = inputReader
+	}
+
+	// Run the transformation command while we are receiving the video part
+	transformedVideoPartReader, transformedVideoPartWriter := io.Pipe()
+	group.Go(func() error {
+		err := ffmpeg.TransformHLSPart(ffmpeg.CreateFilterCommand(groupCtx, graphs, output), countingReader{reader: input, counter: transformedBytes.WithLabelValues("in")}, transformedVideoPartWriter)
+		if err != nil && groupCtx.Err() != nil {
+			// ffmpeg was killed since the transformation stopped, it did not fail
+			err = groupCtx.Err()
+			transformedVideoPartWriter.CloseWithError(err)
+			return err
+		}
+		ffmpegExits.WithLabelValues(exitCode(err)).Inc()
+		if err != nil {
+			log.Error("Cannot run ffmpeg command : ", err)
+			err = fmt.Errorf("ffmpeg failed : %w", err)
 		}
+		transformedVideoPartWriter.CloseWithError(err)
+		return err
+	})
 
-		// Receive next transformer response, write it into stdin pipe
-		go func() {
-			defer stdinWriter.Close()
-			err := t.recvVideoPartStream(stdinWriter, videoPart)
-			if err != nil {
-				log.Error("Failed to write : ", err)
-				return
-			}
-		}()
+	sendErr := t.sendVideoPartStream(transformedVideoPartReader, format, perViewer, send)
+	if sendErr != nil {
+		cancel()
+		transformedVideoPartReader.CloseWithError(sendErr)
+	}
+	if err := transformationError(group.Wait(), sendErr); err != nil {
+		return transformError(ctx, err)
+	}
 
-		// Run the transformation command while we are receiving the file
-		transformedVideoPartReader, transformedVideoPartWriter := io.Pipe()
-		go func() {
-			defer transformedVideoPartWriter.Close()
+	// Every filter run in the pass took its whole duration
+	for _, step := range request.GetSteps()[len(previousSteps):] {
+		filterDuration.WithLabelValues(step.GetName()).Observe(time.Since(start).Seconds())
+	}
+	return nil
+}
 
-			// Execute command
-			cmd.Stdout = transformedVideoPartWriter
-			err = cmd.Start()
-			if err != nil {
-				log.Error("Cannot start command")
-				return
+// Cause of the failure of a transformation. The first error of the group is the cause of the
+// others, unless the response could not be sent: the group is then canceled.
+func transformationError(groupErr error, sendErr error) error {
+	if sendErr != nil && (groupErr == nil || errors.Is(groupErr, context.Canceled)) {
+		return sendErr
+	}
+	return groupErr
+}
+
+// Status of the error of a transformation. The end of the request is the cause of the errors
+// happening after it, they are reported as canceled or deadline exceeded.
+func transformError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// Create the environment the steps of request are run with. The temporary files it creates are
+// removed by cleanup.
+func (t TransformerServer) newChainEnv(ctx context.Context, request *transformerv2.TransformVideoRequest) (chainEnv, func()) {
+	var tempFiles []string
+	cleanup := func() {
+		for _, file := range tempFiles {
+			if err := os.Remove(file); err != nil {
+				log.Error("Cannot remove temporary file : ", err)
 			}
+		}
+	}
 
-			// Wait end of ffmpeg command
-			err = cmd.Wait()
+	var timing *segmentTiming
+	env := chainEnv{
+		principal: request.GetPrincipal(),
+		fetchAsset: func(key string) (string, error) {
+			assetPath, err := t.assets.fetch(ctx, key)
+			if err != nil {
+				log.Error("Cannot fetch asset ", key, " : ", err)
+				return "", status.Errorf(codes.FailedPrecondition, "cannot fetch %v : %v", key, err)
+			}
+			return assetPath, nil
+		},
+		readObject: func(key string) ([]byte, error) {
+			object, err := t.S3Client.GetObject(ctx, key)
+			if err != nil {
+				log.Error("Cannot read ", key, " : ", err)
+				return nil, status.Errorf(codes.FailedPrecondition, "cannot read %v : %v", key, err)
+			}
+			defer object.Close()
+			return io.ReadAll(object)
+		},
+		segment: func() (segmentTiming, error) {
+			if timing == nil {
+				located, err := t.segments.locate(ctx, request.GetVideopath())
+				if err != nil {
+					log.Error("Cannot locate segment ", request.GetVideopath(), " : ", err)
+					return segmentTiming{}, status.Error(codes.FailedPrecondition, err.Error())
+				}
+				timing = &located
+			}
+			return *timing, nil
+		},
+		window: func(videoID string, rendition string, start float64, end float64) (renditionWindow, error) {
+			// Videos have the same renditions unless the resolutions of their sources differ,
+			// the base one is read otherwise
+			window, err := t.segments.window(ctx, videoID+"/"+rendition+"/", start, end)
+			if err != nil && rendition != baseRendition {
+				log.Warn("Cannot read rendition ", rendition, " of video ", videoID, ", reading ", baseRendition, " : ", err)
+				window, err = t.segments.window(ctx, videoID+"/"+baseRendition+"/", start, end)
+			}
 			if err != nil {
-				log.Error("Cannot wait command")
-				return
+				log.Error("Cannot locate window of video ", videoID, " : ", err)
+				return renditionWindow{}, status.Error(codes.FailedPrecondition, err.Error())
+			}
+			return window, nil
+		},
+		tempFile: func(pattern string, content []byte) (string, error) {
+			file, err := os.CreateTemp("", pattern)
+			if err != nil {
+				return "", status.Errorf(codes.Internal, "cannot create temporary file : %v", err)
+			}
+			tempFiles = append(tempFiles, file.Name())
+			_, err = file.Write(content)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
 			}
-		}()
+			if err != nil {
+				return "", status.Errorf(codes.Internal, "cannot write temporary file : %v", err)
+			}
+			return file.Name(), nil
+		},
+	}
+	return env, cleanup
+}
 
-		return t.sendVideoPartStream(transformedVideoPartReader, stream)
+// Describe a served filter, name may be omitted if only one is served
+func (t TransformerServer) Describe(name string) (*transformerv2.DescribeResponse, error) {
+	if name == "" && len(t.Filters) == 1 {
+		name = t.Filters[0].Name
+	}
+	filter, ok := findFilter(t.Filters, name)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "filter %q is not served by this transformer", name)
 	}
+
+	return &transformerv2.DescribeResponse{
+		Name:          filter.Name,
+		Version:       filter.Version,
+		Description:   filter.Description,
+		Parameters:    filter.Parameters,
+		OutputFormats: outputFormats(filter.Audio),
+	}, nil
+}
+
+// Describe the served filters to the service discovery, the parameter schemas are built from
+// their specs
+func (t TransformerServer) Infos() []clients.TransformerInfos {
+	infos := make([]clients.TransformerInfos, 0, len(t.Filters))
+	for _, filter := range t.Filters {
+		schema, err := transformerv2.ParametersSchema(filter.Parameters)
+		if err != nil {
+			log.Error("Cannot create parameter schema of filter ", filter.Name, " : ", err)
+		}
+		infos = append(infos, clients.TransformerInfos{
+			Name:        filter.Name,
+			Version:     filter.Version,
+			Description: filter.Description,
+			Schema:      schema,
+			PerViewer:   filter.PerViewer,
+		})
+	}
+	return infos
 }
 
 func (t TransformerServer) Stop() {
 	t.DiscoveryClient.Stop()
+	t.assets.close()
 }
 
-func (t TransformerServer) sendToNextTransformer(ctx context.Context, args *transformer.TransformVideoRequest) (transformer.TransformerService_TransformVideoClient, error) {
-	// Select client for tranformation and update list
-	clientName := args.TransformerList[len(args.TransformerList)-1]
-
-	clientRPC, err := t.createRPCClient(clientName)
-	if err != nil {
-		log.Errorf("Cannot create RPC Client %v : %v", clientName, err)
-		return nil, err
-	}
-
-	// Ask for next video part transformation
-	streamResponse, err := clientRPC.TransformVideo(ctx, args)
+func (t TransformerServer) sendToNextTransformer(ctx context.Context, args *transformerv2.TransformVideoRequest) (*clients.TransformStream, error) {
+	// Ask for next video part transformation, on another instance if the first one fails
+	streamResponse, err := clients.OpenTransformStream(ctx, t.DiscoveryClient, args)
 	if err != nil {
 		log.Error("Failed to transform video : ", err)
 		return nil, err
@@ -152,9 +372,9 @@ func (t TransformerServer) sendToNextTransformer(ctx context.Context, args *tran
 	return streamResponse, nil
 }
 
-func (t TransformerServer) sendVideoPartStream(transformedVideoPartReader *io.PipeReader, stream transformer.TransformerService_TransformVideoServer) error {
-	defer stream.Context().Done()
-
+// Send the transformed video part, the format and whether it depends on the principal are sent
+// with the first chunk
+func (t TransformerServer) sendVideoPartStream(transformedVideoPartReader *io.PipeReader, format *transformerv2.OutputFormat, perViewer bool, send func(*transformerv2.TransformVideoResponse) error) error {
 	buf := make([]byte, MAX_CHUNK_SIZE)
 	for {
 		nbRead, err := transformedVideoPartReader.Read(buf)
@@ -167,19 +387,26 @@ func (t TransformerServer) sendVideoPartStream(transformedVideoPartReader *io.Pi
 		}
 
 		if nbRead != 0 {
-			videoPart := transformer.TransformVideoResponse{
-				Chunk: buf[:nbRead],
+			videoPart := transformerv2.TransformVideoResponse{
+				Chunk:     buf[:nbRead],
+				Format:    format,
+				PerViewer: perViewer,
 			}
+			format = nil
+			perViewer = false
 
-			if err := stream.Send(&videoPart); err != nil {
+			if err := send(&videoPart); err != nil {
 				log.Error("Cannot send transformed video : ", err)
 				return err
 			}
+			transformedBytes.WithLabelValues("out").Add(float64(nbRead))
 		}
 	}
 }
 
-func (t TransformerServer) recvVideoPartStream(transformedVideoPart io.Writer, stream transformer.TransformerService_TransformVideoClient) error {
+func (t TransformerServer) recvVideoPartStream(transformedVideoPart io.Writer, stream *clients.TransformStream) error {
+	defer stream.Close()
+
 	for {
 		res, err := stream.Recv()
 		if err != nil {
@@ -201,19 +428,18 @@ func (t TransformerServer) recvVideoPartStream(transformedVideoPart io.Writer, s
 	return nil
 }
 
-func (t TransformerServer) createRPCClient(clientName string) (transformer.TransformerServiceClient, error) {
-	// Retrieve service address and port
-	tfServices, err := t.DiscoveryClient.GetTransformationService(clientName)
-	if err != nil {
-		log.Errorf("Cannot get address for service name %v : %v", clientName, err)
-		return nil, err
-	}
-
-	conn, err := grpc.Dial(tfServices, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		log.Errorf("Cannot open TCP connection with grpc %v transformer server : %v", clientName, err)
-		return nil, err
+// Formats the transformations can produce, the audio is encoded if it is filtered
+func outputFormats(filteredAudio bool) []*transformerv2.OutputFormat {
+	formats := make([]*transformerv2.OutputFormat, 0, len(ffmpeg.TransformOutputs))
+	for _, output := range ffmpeg.TransformOutputs {
+		if filteredAudio && output.AudioCodec == "copy" {
+			continue
+		}
+		formats = append(formats, &transformerv2.OutputFormat{
+			Container:  output.Container,
+			VideoCodec: output.VideoCodec,
+			AudioCodec: output.AudioCodec,
+		})
 	}
-
-	return transformer.NewTransformerServiceClient(conn), nil
+	return formats
 }
@@ -1,17 +1,24 @@
 package transformer
 
 import (
-	"fmt"
-
 	"github.com/rishirishhh/vought/src/pkg/clients"
 )
 
-func GetTransformer(transformerType string, s3Client clients.IS3Client, discoveryClient clients.ServiceDiscovery) (ITransformerServer, error) {
-	if transformerType == "Flip" {
-		return newFlipServer(s3Client, discoveryClient), nil
-	}
-	if transformerType == "Gray" {
-		return newGrayServer(s3Client, discoveryClient), nil
+// GetTransformer creates a transformer serving the named filters of the catalogue, or all of them
+// if no name is given. Its work is bounded by limits.
+func GetTransformer(filterNames []string, s3Client clients.IS3Client, discoveryClient clients.ServiceDiscovery, limits Limits) (ITransformerServer, error) {
+	filters, err := catalogueFilters(filterNames)
+	if err != nil {
+		return nil, err
 	}
-	return nil, fmt.Errorf("Unknown transformer")
+
+	return &TransformerServer{
+		Filters:         filters,
+		DiscoveryClient: discoveryClient,
+		S3Client:        s3Client,
+		assets:          newAssetCache(s3Client),
+		segments:        newSegmentLocator(s3Client),
+		Limits:          limits,
+		workers:         newWorkerPool(limits),
+	}, nil
 }
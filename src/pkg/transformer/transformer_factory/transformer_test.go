@@ -0,0 +1,36 @@
+package transformer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_TransformationError(t *testing.T) {
+	ffmpegErr := errors.New("ffmpeg failed : exit status 1")
+	recvErr := status.Error(codes.Unavailable, "next transformer left")
+	sendErr := status.Error(codes.Canceled, "client left")
+
+	cases := []struct {
+		Name          string
+		GivenGroupErr error
+		GivenSendErr  error
+		ExpectError   error
+	}{
+		{Name: "Success"},
+		{Name: "ffmpeg failure", GivenGroupErr: ffmpegErr, GivenSendErr: ffmpegErr, ExpectError: ffmpegErr},
+		{Name: "Next transformer failure", GivenGroupErr: recvErr, GivenSendErr: context.Canceled, ExpectError: recvErr},
+		{Name: "Client left during the transformation", GivenGroupErr: context.Canceled, GivenSendErr: sendErr, ExpectError: sendErr},
+		{Name: "Client left at the end of the transformation", GivenSendErr: sendErr, ExpectError: sendErr},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			require.Equal(t, tt.ExpectError, transformationError(tt.GivenGroupErr, tt.GivenSendErr))
+		})
+	}
+}
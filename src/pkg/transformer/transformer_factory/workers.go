@@ -0,0 +1,129 @@
+package transformer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/rishirishhh/vought/src/pkg/clients"
+	transformerv2 "github.com/rishirishhh/vought/src/pkg/transformer/v2"
+)
+
+// Bounds of the delay after which a rejected request is worth retrying
+const (
+	minRetryDelay = 100 * time.Millisecond
+	maxRetryDelay = 30 * time.Second
+)
+
+// Limits bound the work of a transformer, zero values do not limit it
+type Limits struct {
+	// Maximum duration of a transformation
+	Timeout time.Duration
+	// Transformations run at once, each one runs an ffmpeg process
+	Workers int
+	// Transformations waiting for a worker, the next ones are rejected
+	Queue int
+}
+
+// workerPool limits the transformations run at once. Requests beyond its workers wait in a bounded
+// queue, the ones beyond the queue are rejected with RESOURCE_EXHAUSTED.
+type workerPool struct {
+	workers chan struct{}
+	queue   int
+	mutex   sync.Mutex
+	queued  int
+	// Average duration of the transformations, the time it takes for a worker to be free
+	averageDuration time.Duration
+}
+
+// Create a pool of workers, nil if they are not limited
+func newWorkerPool(limits Limits) *workerPool {
+	if limits.Workers <= 0 {
+		return nil
+	}
+	return &workerPool{workers: make(chan struct{}, limits.Workers), queue: limits.Queue}
+}
+
+// Wait for a free worker, the returned function releases it. The wait ends with ctx.
+func (p *workerPool) acquire(ctx context.Context) (func(), error) {
+	if p == nil {
+		inFlightTransformations.Inc()
+		return func() { inFlightTransformations.Dec() }, nil
+	}
+
+	select {
+	case p.workers <- struct{}{}:
+		return p.started(), nil
+	default:
+	}
+
+	p.mutex.Lock()
+	if p.queued >= p.queue {
+		retryDelay := p.retryDelay()
+		p.mutex.Unlock()
+		rejectedTransformations.Inc()
+		return nil, transformerv2.BusyError("every worker of the transformer is busy", retryDelay)
+	}
+	p.queued++
+	p.mutex.Unlock()
+	queuedTransformations.Inc()
+
+	defer func() {
+		p.mutex.Lock()
+		p.queued--
+		p.mutex.Unlock()
+		queuedTransformations.Dec()
+	}()
+
+	select {
+	case p.workers <- struct{}{}:
+		return p.started(), nil
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// A worker starts a transformation, the returned function releases it
+func (p *workerPool) started() func() {
+	inFlightTransformations.Inc()
+	start := time.Now()
+	return func() {
+		duration := time.Since(start)
+		p.mutex.Lock()
+		if p.averageDuration == 0 {
+			p.averageDuration = duration
+		} else {
+			p.averageDuration = (p.averageDuration*9 + duration) / 10
+		}
+		p.mutex.Unlock()
+
+		inFlightTransformations.Dec()
+		<-p.workers
+	}
+}
+
+// Status of a request the next transformers failed. Their being busy is told with UNAVAILABLE, so
+// that clients do not back off from this transformer, along with the delay after which the
+// request is worth retrying.
+func nextTransformerError(err error) error {
+	if retryDelay, busy := transformerv2.RetryAfter(err); busy {
+		return transformerv2.NextBusyError(err.Error(), retryDelay)
+	}
+	if errors.Is(err, clients.ErrNoTransformerAvailable) {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return err
+}
+
+// Time for the queued transformations to start, p.mutex must be held
+func (p *workerPool) retryDelay() time.Duration {
+	if p.averageDuration == 0 {
+		return transformerv2.DefaultRetryDelay
+	}
+	delay := p.averageDuration * time.Duration(p.queued/cap(p.workers)+1)
+	return min(max(delay, minRetryDelay), maxRetryDelay)
+}
@@ -0,0 +1,84 @@
+package transformer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/rishirishhh/vought/src/pkg/clients"
+	transformerv2 "github.com/rishirishhh/vought/src/pkg/transformer/v2"
+)
+
+func Test_WorkerPool(t *testing.T) {
+	pool := newWorkerPool(Limits{Workers: 1, Queue: 1})
+
+	release, err := pool.acquire(context.Background())
+	require.NoError(t, err)
+
+	// The second transformation waits for the first one, the third one is rejected
+	acquired := make(chan func())
+	go func() {
+		release, _ := pool.acquire(context.Background())
+		acquired <- release
+	}()
+	require.Eventually(t, func() bool {
+		pool.mutex.Lock()
+		defer pool.mutex.Unlock()
+		return pool.queued == 1
+	}, time.Second, time.Millisecond)
+
+	_, err = pool.acquire(context.Background())
+	delay, busy := transformerv2.RetryDelay(err)
+	require.True(t, busy)
+	require.Equal(t, transformerv2.DefaultRetryDelay, delay)
+
+	release()
+	releaseSecond := <-acquired
+	require.NotNil(t, releaseSecond)
+
+	// A client leaving stops waiting
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = pool.acquire(ctx)
+	require.Equal(t, codes.Canceled, status.Code(err))
+	releaseSecond()
+
+	// Without limit, transformations never wait
+	release, err = newWorkerPool(Limits{}).acquire(context.Background())
+	require.NoError(t, err)
+	release()
+}
+
+func Test_NextTransformerError(t *testing.T) {
+	cases := []struct {
+		Name          string
+		GivenErr      error
+		ExpectedCode  codes.Code
+		ExpectedDelay time.Duration
+		ExpectedRetry bool
+	}{
+		{Name: "Next transformer busy", GivenErr: transformerv2.BusyError("busy", 2*time.Second), ExpectedCode: codes.Unavailable, ExpectedDelay: 2 * time.Second, ExpectedRetry: true},
+		{Name: "Every next instance busy", GivenErr: fmt.Errorf("%w : gray : %w", clients.ErrNoTransformerAvailable, transformerv2.BusyError("busy", time.Second)), ExpectedCode: codes.Unavailable, ExpectedDelay: time.Second, ExpectedRetry: true},
+		{Name: "Transformers after the next one busy", GivenErr: transformerv2.NextBusyError("next busy", 3*time.Second), ExpectedCode: codes.Unavailable, ExpectedDelay: 3 * time.Second, ExpectedRetry: true},
+		{Name: "No next instance", GivenErr: fmt.Errorf("%w : gray", clients.ErrNoTransformerAvailable), ExpectedCode: codes.Unavailable},
+		{Name: "Invalid request", GivenErr: status.Error(codes.InvalidArgument, "invalid"), ExpectedCode: codes.InvalidArgument},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			err := nextTransformerError(tt.GivenErr)
+			require.Equal(t, tt.ExpectedCode, status.Code(err))
+			// Clients must not take the transformer for a busy one
+			_, busy := transformerv2.RetryDelay(err)
+			require.False(t, busy)
+			delay, retry := transformerv2.RetryAfter(err)
+			require.Equal(t, tt.ExpectedRetry, retry)
+			require.Equal(t, tt.ExpectedDelay, delay)
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package transformer
+
+import (
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Delay after which a request rejected by a busy transformer is retried, when it gives none
+const DefaultRetryDelay = time.Second
+
+// BusyError is the RESOURCE_EXHAUSTED status of a request rejected because the transformer is
+// busy. It tells the client when the request is worth retrying.
+func BusyError(message string, retryDelay time.Duration) error {
+	return retryError(codes.ResourceExhausted, message, retryDelay)
+}
+
+// NextBusyError is the UNAVAILABLE status of a request a transformer cannot run because the next
+// transformers of the chain are busy. The transformer itself is not busy, it tells the client when
+// the request is worth retrying.
+func NextBusyError(message string, retryDelay time.Duration) error {
+	return retryError(codes.Unavailable, message, retryDelay)
+}
+
+func retryError(code codes.Code, message string, retryDelay time.Duration) error {
+	busy := status.New(code, message)
+	detailed, err := busy.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryDelay)})
+	if err != nil {
+		return busy.Err()
+	}
+	return detailed.Err()
+}
+
+// RetryDelay tells whether err rejects a request because the transformer is busy, and after
+// which delay the request is worth retrying
+func RetryDelay(err error) (time.Duration, bool) {
+	busy, ok := status.FromError(err)
+	if !ok || busy.Code() != codes.ResourceExhausted {
+		return 0, false
+	}
+	if retryDelay, ok := retryInfo(busy); ok {
+		return retryDelay, true
+	}
+	return DefaultRetryDelay, true
+}
+
+// RetryAfter tells whether err rejects a request because the transformer or the next ones are
+// busy, and after which delay the request is worth retrying
+func RetryAfter(err error) (time.Duration, bool) {
+	if retryDelay, busy := RetryDelay(err); busy {
+		return retryDelay, true
+	}
+	rejected, ok := status.FromError(err)
+	if !ok || rejected.Code() != codes.Unavailable {
+		return 0, false
+	}
+	return retryInfo(rejected)
+}
+
+func retryInfo(rejected *status.Status) (time.Duration, bool) {
+	for _, detail := range rejected.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			return info.GetRetryDelay().AsDuration(), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,62 @@
+package transformer
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_RetryDelay(t *testing.T) {
+	cases := []struct {
+		Name          string
+		GivenErr      error
+		ExpectedDelay time.Duration
+		ExpectedBusy  bool
+	}{
+		{Name: "Busy", GivenErr: BusyError("busy", 1500*time.Millisecond), ExpectedDelay: 1500 * time.Millisecond, ExpectedBusy: true},
+		{Name: "Wrapped busy", GivenErr: fmt.Errorf("no instance : %w", BusyError("busy", 2*time.Second)), ExpectedDelay: 2 * time.Second, ExpectedBusy: true},
+		{Name: "Busy without delay", GivenErr: status.Error(codes.ResourceExhausted, "busy"), ExpectedDelay: DefaultRetryDelay, ExpectedBusy: true},
+		{Name: "Next transformers busy", GivenErr: NextBusyError("next busy", time.Second)},
+		{Name: "Other status", GivenErr: status.Error(codes.Unavailable, "down")},
+		{Name: "Not a status", GivenErr: errors.New("failed")},
+		{Name: "No error"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			delay, busy := RetryDelay(tt.GivenErr)
+			require.Equal(t, tt.ExpectedBusy, busy)
+			require.Equal(t, tt.ExpectedDelay, delay)
+		})
+	}
+}
+
+func Test_RetryAfter(t *testing.T) {
+	cases := []struct {
+		Name          string
+		GivenErr      error
+		ExpectedDelay time.Duration
+		ExpectedRetry bool
+	}{
+		{Name: "Busy", GivenErr: BusyError("busy", 1500*time.Millisecond), ExpectedDelay: 1500 * time.Millisecond, ExpectedRetry: true},
+		{Name: "Busy without delay", GivenErr: status.Error(codes.ResourceExhausted, "busy"), ExpectedDelay: DefaultRetryDelay, ExpectedRetry: true},
+		{Name: "Next transformers busy", GivenErr: NextBusyError("next busy", 3*time.Second), ExpectedDelay: 3 * time.Second, ExpectedRetry: true},
+		{Name: "Wrapped next transformers busy", GivenErr: fmt.Errorf("cannot transform : %w", NextBusyError("next busy", time.Second)), ExpectedDelay: time.Second, ExpectedRetry: true},
+		{Name: "Unavailable without delay", GivenErr: status.Error(codes.Unavailable, "down")},
+		{Name: "Not a status", GivenErr: errors.New("failed")},
+		{Name: "No error"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			delay, retry := RetryAfter(tt.GivenErr)
+			require.Equal(t, tt.ExpectedRetry, retry)
+			require.Equal(t, tt.ExpectedDelay, delay)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package transformer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported output format")
+
+// NegotiateFormat picks the output format of a transformation: the first accepted format, by
+// order of preference, which the transformer supports. The default format, the first supported
+// one, is picked when no format is given.
+func NegotiateFormat(supported []*OutputFormat, accepted []*OutputFormat) (*OutputFormat, error) {
+	if len(supported) == 0 {
+		return nil, fmt.Errorf("%w : the transformer supports no format", ErrUnsupportedFormat)
+	}
+	if len(accepted) == 0 {
+		return supported[0], nil
+	}
+
+	for _, format := range accepted {
+		for _, candidate := range supported {
+			if formatMatches(format, candidate) {
+				return candidate, nil
+			}
+		}
+	}
+
+	formats := make([]string, 0, len(accepted))
+	for _, format := range accepted {
+		formats = append(formats, FormatString(format))
+	}
+	return nil, fmt.Errorf("%w : none of %v", ErrUnsupportedFormat, strings.Join(formats, ", "))
+}
+
+// FormatString formats an output format as container/video_codec/audio_codec, * for any value
+func FormatString(format *OutputFormat) string {
+	fields := []string{format.GetContainer(), format.GetVideoCodec(), format.GetAudioCodec()}
+	for i, field := range fields {
+		if field == "" {
+			fields[i] = "*"
+		}
+	}
+	return strings.Join(fields, "/")
+}
+
+// Empty fields of the accepted format match any value
+func formatMatches(accepted *OutputFormat, candidate *OutputFormat) bool {
+	return fieldMatches(accepted.GetContainer(), candidate.GetContainer()) &&
+		fieldMatches(accepted.GetVideoCodec(), candidate.GetVideoCodec()) &&
+		fieldMatches(accepted.GetAudioCodec(), candidate.GetAudioCodec())
+}
+
+func fieldMatches(accepted string, candidate string) bool {
+	return accepted == "" || accepted == candidate
+}
@@ -0,0 +1,353 @@
+package transformer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidFilter    = errors.New("invalid filter")
+	ErrUnknownParameter = errors.New("unknown parameter")
+	ErrMissingParameter = errors.New("missing parameter")
+	ErrInvalidParameter = errors.New("invalid parameter")
+)
+
+// ParseFilter parses a filter of the API, name[:key=value[,key=value...]]. Values are kept as
+// strings, they are typed by NewStep with the parameters of the transformer.
+func ParseFilter(filter string) (string, map[string]string, error) {
+	name, rawParameters, hasParameters := strings.Cut(filter, ":")
+	if name == "" {
+		return "", nil, fmt.Errorf("%w : %q has no name", ErrInvalidFilter, filter)
+	}
+
+	parameters := map[string]string{}
+	if !hasParameters {
+		return name, parameters, nil
+	}
+	for _, parameter := range strings.Split(rawParameters, ",") {
+		key, value, ok := strings.Cut(parameter, "=")
+		if !ok || key == "" {
+			return "", nil, fmt.Errorf("%w : %q is not a key=value pair", ErrInvalidFilter, parameter)
+		}
+		if _, ok := parameters[key]; ok {
+			return "", nil, fmt.Errorf("%w : %v is given twice", ErrInvalidFilter, key)
+		}
+		parameters[key] = value
+	}
+	return name, parameters, nil
+}
+
+// NewStep types the parameters of a parsed filter with the transformer specs, and validates them
+func NewStep(name string, parameters map[string]string, specs []*ParameterSpec) (*Step, error) {
+	step := &Step{Name: name, Parameters: map[string]*Value{}}
+	for key, raw := range parameters {
+		spec := findSpec(specs, key)
+		if spec == nil {
+			return nil, fmt.Errorf("%w : %v of %v", ErrUnknownParameter, key, name)
+		}
+		value, err := parseValue(spec.GetType(), raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w : %v of %v : %v", ErrInvalidParameter, key, name, err)
+		}
+		step.Parameters[key] = value
+	}
+
+	if err := ValidateStep(step, specs); err != nil {
+		return nil, err
+	}
+	return step, nil
+}
+
+// ValidateStep checks the parameters of step against the transformer specs
+func ValidateStep(step *Step, specs []*ParameterSpec) error {
+	for key := range step.GetParameters() {
+		if findSpec(specs, key) == nil {
+			return fmt.Errorf("%w : %v of %v", ErrUnknownParameter, key, step.GetName())
+		}
+	}
+
+	for _, spec := range specs {
+		value, ok := step.GetParameters()[spec.GetName()]
+		if !ok {
+			if spec.GetRequired() {
+				return fmt.Errorf("%w : %v of %v", ErrMissingParameter, spec.GetName(), step.GetName())
+			}
+			continue
+		}
+		if err := validateValue(spec, value); err != nil {
+			return fmt.Errorf("%w : %v of %v : %v", ErrInvalidParameter, spec.GetName(), step.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// ParameterValue returns the value of a parameter of step, or its default value
+func ParameterValue(step *Step, specs []*ParameterSpec, name string) *Value {
+	if value, ok := step.GetParameters()[name]; ok {
+		return value
+	}
+	if spec := findSpec(specs, name); spec != nil {
+		return spec.GetDefaultValue()
+	}
+	return nil
+}
+
+// StepFilter formats step as a filter of the API. Parameters are sorted, equal steps have equal
+// filters.
+func StepFilter(step *Step) string {
+	if len(step.GetParameters()) == 0 {
+		return step.GetName()
+	}
+
+	keys := make([]string, 0, len(step.GetParameters()))
+	for key := range step.GetParameters() {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parameters := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parameters = append(parameters, key+"="+formatValue(step.GetParameters()[key]))
+	}
+	return step.GetName() + ":" + strings.Join(parameters, ",")
+}
+
+func findSpec(specs []*ParameterSpec, name string) *ParameterSpec {
+	for _, spec := range specs {
+		if spec.GetName() == name {
+			return spec
+		}
+	}
+	return nil
+}
+
+func parseValue(parameterType ParameterType, raw string) (*Value, error) {
+	switch parameterType {
+	case ParameterType_PARAMETER_TYPE_INT:
+		i, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", raw)
+		}
+		return &Value{Kind: &Value_IntValue{IntValue: i}}, nil
+	case ParameterType_PARAMETER_TYPE_DOUBLE:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("%q is not a number", raw)
+		}
+		return &Value{Kind: &Value_DoubleValue{DoubleValue: f}}, nil
+	case ParameterType_PARAMETER_TYPE_BOOL:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", raw)
+		}
+		return &Value{Kind: &Value_BoolValue{BoolValue: b}}, nil
+	case ParameterType_PARAMETER_TYPE_STRING:
+		return &Value{Kind: &Value_StringValue{StringValue: raw}}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %v", parameterType)
+}
+
+func formatValue(value *Value) string {
+	switch kind := value.GetKind().(type) {
+	case *Value_IntValue:
+		return strconv.FormatInt(kind.IntValue, 10)
+	case *Value_DoubleValue:
+		return strconv.FormatFloat(kind.DoubleValue, 'g', -1, 64)
+	case *Value_BoolValue:
+		return strconv.FormatBool(kind.BoolValue)
+	case *Value_StringValue:
+		return kind.StringValue
+	}
+	return ""
+}
+
+func validateValue(spec *ParameterSpec, value *Value) error {
+	var number float64
+	switch kind := value.GetKind().(type) {
+	case *Value_IntValue:
+		if spec.GetType() != ParameterType_PARAMETER_TYPE_INT {
+			return fmt.Errorf("an integer is given, %v is expected", spec.GetType())
+		}
+		number = float64(kind.IntValue)
+	case *Value_DoubleValue:
+		if spec.GetType() != ParameterType_PARAMETER_TYPE_DOUBLE {
+			return fmt.Errorf("a number is given, %v is expected", spec.GetType())
+		}
+		if math.IsNaN(kind.DoubleValue) || math.IsInf(kind.DoubleValue, 0) {
+			return fmt.Errorf("%v is not a number", kind.DoubleValue)
+		}
+		number = kind.DoubleValue
+	case *Value_BoolValue:
+		if spec.GetType() != ParameterType_PARAMETER_TYPE_BOOL {
+			return fmt.Errorf("a boolean is given, %v is expected", spec.GetType())
+		}
+		return nil
+	case *Value_StringValue:
+		if spec.GetType() != ParameterType_PARAMETER_TYPE_STRING {
+			return fmt.Errorf("a string is given, %v is expected", spec.GetType())
+		}
+		if len(spec.GetAllowedValues()) > 0 && !contains(spec.GetAllowedValues(), kind.StringValue) {
+			return fmt.Errorf("%q is not one of %v", kind.StringValue, strings.Join(spec.GetAllowedValues(), ", "))
+		}
+		return nil
+	default:
+		return fmt.Errorf("no value given")
+	}
+
+	if spec.Minimum != nil && number < spec.GetMinimum() {
+		return fmt.Errorf("%v is lower than %v", formatValue(value), spec.GetMinimum())
+	}
+	if spec.Maximum != nil && number > spec.GetMaximum() {
+		return fmt.Errorf("%v is greater than %v", formatValue(value), spec.GetMaximum())
+	}
+	return nil
+}
+
+// Format of the string parameters holding the ID of a video
+const FormatVideoID = "video-id"
+
+// IDs of the videos the parameters of step refer to
+func ReferencedVideos(step *Step, specs []*ParameterSpec) []string {
+	videos := []string{}
+	for _, spec := range specs {
+		if spec.GetFormat() != FormatVideoID {
+			continue
+		}
+		if value, ok := step.GetParameters()[spec.GetName()]; ok {
+			videos = append(videos, value.GetStringValue())
+		}
+	}
+	return videos
+}
+
+// Tell whether the principal may read the video, requests without principal are not restricted
+func (p *Principal) CanAccess(videoID string) bool {
+	return p.GetVideoId() == "" || p.GetVideoId() == videoID
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// JSON schema of the parameters, advertised by the service discovery
+type parametersSchema struct {
+	Type                 string                     `json:"type"`
+	Properties           map[string]parameterSchema `json:"properties"`
+	Required             []string                   `json:"required,omitempty"`
+	AdditionalProperties bool                       `json:"additionalProperties"`
+}
+
+type parameterSchema struct {
+	Type        string   `json:"type"`
+	Description string   `json:"description,omitempty"`
+	Default     any      `json:"default,omitempty"`
+	Minimum     *float64 `json:"minimum,omitempty"`
+	Maximum     *float64 `json:"maximum,omitempty"`
+	Enum        []string `json:"enum,omitempty"`
+	Format      string   `json:"format,omitempty"`
+}
+
+var schemaTypes = map[ParameterType]string{
+	ParameterType_PARAMETER_TYPE_INT:    "integer",
+	ParameterType_PARAMETER_TYPE_DOUBLE: "number",
+	ParameterType_PARAMETER_TYPE_BOOL:   "boolean",
+	ParameterType_PARAMETER_TYPE_STRING: "string",
+}
+
+// ParametersSchema converts the parameter specs of a transformer to a JSON schema
+func ParametersSchema(specs []*ParameterSpec) (string, error) {
+	schema := parametersSchema{Type: "object", Properties: map[string]parameterSchema{}}
+	for _, spec := range specs {
+		schemaType, ok := schemaTypes[spec.GetType()]
+		if !ok {
+			return "", fmt.Errorf("parameter %v has an unsupported type %v", spec.GetName(), spec.GetType())
+		}
+
+		property := parameterSchema{
+			Type:        schemaType,
+			Description: spec.GetDescription(),
+			Minimum:     spec.Minimum,
+			Maximum:     spec.Maximum,
+			Enum:        spec.GetAllowedValues(),
+			Format:      spec.GetFormat(),
+		}
+		if spec.GetDefaultValue() != nil {
+			switch kind := spec.GetDefaultValue().GetKind().(type) {
+			case *Value_IntValue:
+				property.Default = kind.IntValue
+			case *Value_DoubleValue:
+				property.Default = kind.DoubleValue
+			case *Value_BoolValue:
+				property.Default = kind.BoolValue
+			case *Value_StringValue:
+				property.Default = kind.StringValue
+			}
+		}
+		schema.Properties[spec.GetName()] = property
+		if spec.GetRequired() {
+			schema.Required = append(schema.Required, spec.GetName())
+		}
+	}
+
+	content, err := json.Marshal(schema)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// ParametersFromSchema converts a JSON schema made by ParametersSchema back to parameter specs,
+// sorted by name. An empty schema has no parameters.
+func ParametersFromSchema(schema string) ([]*ParameterSpec, error) {
+	if schema == "" {
+		return nil, nil
+	}
+
+	decoded := parametersSchema{}
+	if err := json.Unmarshal([]byte(schema), &decoded); err != nil {
+		return nil, err
+	}
+
+	specs := []*ParameterSpec{}
+	for name, property := range decoded.Properties {
+		spec := &ParameterSpec{
+			Name:          name,
+			Description:   property.Description,
+			Required:      contains(decoded.Required, name),
+			Minimum:       property.Minimum,
+			Maximum:       property.Maximum,
+			AllowedValues: property.Enum,
+			Format:        property.Format,
+		}
+		for parameterType, schemaType := range schemaTypes {
+			if schemaType == property.Type {
+				spec.Type = parameterType
+			}
+		}
+		if spec.Type == ParameterType_PARAMETER_TYPE_UNSPECIFIED {
+			return nil, fmt.Errorf("parameter %v has an unsupported type %v", name, property.Type)
+		}
+
+		if property.Default != nil {
+			value, err := parseValue(spec.Type, fmt.Sprint(property.Default))
+			if err != nil {
+				return nil, fmt.Errorf("default value of parameter %v : %w", name, err)
+			}
+			spec.DefaultValue = value
+		}
+		specs = append(specs, spec)
+	}
+
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+	return specs, nil
+}
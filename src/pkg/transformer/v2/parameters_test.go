@@ -0,0 +1,150 @@
+package transformer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func blurSpecs() []*ParameterSpec {
+	return []*ParameterSpec{
+		{
+			Name:         "radius",
+			Type:         ParameterType_PARAMETER_TYPE_DOUBLE,
+			Description:  "Radius of the blur, in pixels",
+			DefaultValue: &Value{Kind: &Value_DoubleValue{DoubleValue: 5}},
+			Minimum:      proto.Float64(0),
+			Maximum:      proto.Float64(50),
+		},
+		{
+			Name:     "region",
+			Type:     ParameterType_PARAMETER_TYPE_STRING,
+			Required: true,
+			AllowedValues: []string{
+				"all", "faces",
+			},
+		},
+		{
+			Name: "steps",
+			Type: ParameterType_PARAMETER_TYPE_INT,
+		},
+		{
+			Name:   "mask",
+			Type:   ParameterType_PARAMETER_TYPE_STRING,
+			Format: FormatVideoID,
+		},
+		{
+			Name: "luma_only",
+			Type: ParameterType_PARAMETER_TYPE_BOOL,
+		},
+	}
+}
+
+func Test_NewStep(t *testing.T) {
+	cases := []struct {
+		Name           string
+		GivenFilter    string
+		ExpectedFilter string
+		ExpectedErr    error
+	}{
+		{Name: "All parameters", GivenFilter: "blur:steps=2,region=faces,radius=2.5,luma_only=true", ExpectedFilter: "blur:luma_only=true,radius=2.5,region=faces,steps=2"},
+		{Name: "Default parameters", GivenFilter: "blur:region=all", ExpectedFilter: "blur:region=all"},
+		{Name: "Missing required parameter", GivenFilter: "blur", ExpectedErr: ErrMissingParameter},
+		{Name: "Unknown parameter", GivenFilter: "blur:region=all,sigma=2", ExpectedErr: ErrUnknownParameter},
+		{Name: "Out of bounds", GivenFilter: "blur:region=all,radius=51", ExpectedErr: ErrInvalidParameter},
+		{Name: "Not allowed value", GivenFilter: "blur:region=sky", ExpectedErr: ErrInvalidParameter},
+		{Name: "Invalid integer", GivenFilter: "blur:region=all,steps=2.5", ExpectedErr: ErrInvalidParameter},
+		{Name: "Invalid number", GivenFilter: "blur:region=all,radius=NaN", ExpectedErr: ErrInvalidParameter},
+		{Name: "Not a pair", GivenFilter: "blur:region", ExpectedErr: ErrInvalidFilter},
+		{Name: "Given twice", GivenFilter: "blur:region=all,region=faces", ExpectedErr: ErrInvalidFilter},
+		{Name: "No name", GivenFilter: ":region=all", ExpectedErr: ErrInvalidFilter},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			name, parameters, err := ParseFilter(tt.GivenFilter)
+			if err == nil {
+				var step *Step
+				step, err = NewStep(name, parameters, blurSpecs())
+				if tt.ExpectedErr == nil {
+					require.NoError(t, err)
+					require.Equal(t, tt.ExpectedFilter, StepFilter(step))
+				}
+			}
+			require.ErrorIs(t, err, tt.ExpectedErr)
+		})
+	}
+}
+
+func Test_ValidateStepTypes(t *testing.T) {
+	step := &Step{
+		Name: "blur",
+		Parameters: map[string]*Value{
+			"region": {Kind: &Value_StringValue{StringValue: "all"}},
+			"radius": {Kind: &Value_IntValue{IntValue: 2}},
+		},
+	}
+	require.ErrorIs(t, ValidateStep(step, blurSpecs()), ErrInvalidParameter)
+
+	step.Parameters["radius"] = &Value{Kind: &Value_DoubleValue{DoubleValue: 2}}
+	require.NoError(t, ValidateStep(step, blurSpecs()))
+	require.Equal(t, 2.0, ParameterValue(step, blurSpecs(), "radius").GetDoubleValue())
+
+	delete(step.Parameters, "radius")
+	require.Equal(t, 5.0, ParameterValue(step, blurSpecs(), "radius").GetDoubleValue())
+	require.Nil(t, ParameterValue(step, blurSpecs(), "steps"))
+}
+
+func Test_ParametersSchemaRoundTrip(t *testing.T) {
+	schema, err := ParametersSchema(blurSpecs())
+	require.NoError(t, err)
+
+	specs, err := ParametersFromSchema(schema)
+	require.NoError(t, err)
+
+	expected := blurSpecs()
+	require.Len(t, specs, len(expected))
+	for _, spec := range specs {
+		var expectedSpec *ParameterSpec
+		for _, e := range expected {
+			if e.Name == spec.Name {
+				expectedSpec = e
+			}
+		}
+		require.NotNil(t, expectedSpec, spec.Name)
+		require.True(t, proto.Equal(expectedSpec, spec), "%v : %v != %v", spec.Name, expectedSpec, spec)
+	}
+
+	noParameters, err := ParametersSchema(nil)
+	require.NoError(t, err)
+	require.Equal(t, `{"type":"object","properties":{},"additionalProperties":false}`, noParameters)
+}
+
+func Test_NegotiateFormat(t *testing.T) {
+	supported := []*OutputFormat{
+		{Container: "mpegts", VideoCodec: "h264", AudioCodec: "copy"},
+		{Container: "mpegts", VideoCodec: "h264", AudioCodec: "aac"},
+		{Container: "mpegts", VideoCodec: "hevc", AudioCodec: "copy"},
+	}
+
+	cases := []struct {
+		Name           string
+		GivenAccepted  []*OutputFormat
+		ExpectedFormat *OutputFormat
+		ExpectedErr    error
+	}{
+		{Name: "Default", ExpectedFormat: supported[0]},
+		{Name: "Wildcard", GivenAccepted: []*OutputFormat{{VideoCodec: "hevc"}}, ExpectedFormat: supported[2]},
+		{Name: "Preference order", GivenAccepted: []*OutputFormat{{AudioCodec: "opus"}, {AudioCodec: "aac"}, {}}, ExpectedFormat: supported[1]},
+		{Name: "Unsupported", GivenAccepted: []*OutputFormat{{Container: "mp4"}}, ExpectedErr: ErrUnsupportedFormat},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			format, err := NegotiateFormat(supported, tt.GivenAccepted)
+			require.ErrorIs(t, err, tt.ExpectedErr)
+			require.Equal(t, tt.ExpectedFormat, format)
+		})
+	}
+}
@@ -0,0 +1,901 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        v6.32.0
+// source: src/pkg/transformer/v2/transformer.proto
+
+package transformer
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ParameterType int32
+
+const (
+	ParameterType_PARAMETER_TYPE_UNSPECIFIED ParameterType = 0
+	ParameterType_PARAMETER_TYPE_INT         ParameterType = 1
+	ParameterType_PARAMETER_TYPE_DOUBLE      ParameterType = 2
+	ParameterType_PARAMETER_TYPE_BOOL        ParameterType = 3
+	ParameterType_PARAMETER_TYPE_STRING      ParameterType = 4
+)
+
+// Enum value maps for ParameterType.
+var (
+	ParameterType_name = map[int32]string{
+		0: "PARAMETER_TYPE_UNSPECIFIED",
+		1: "PARAMETER_TYPE_INT",
+		2: "PARAMETER_TYPE_DOUBLE",
+		3: "PARAMETER_TYPE_BOOL",
+		4: "PARAMETER_TYPE_STRING",
+	}
+	ParameterType_value = map[string]int32{
+		"PARAMETER_TYPE_UNSPECIFIED": 0,
+		"PARAMETER_TYPE_INT":         1,
+		"PARAMETER_TYPE_DOUBLE":      2,
+		"PARAMETER_TYPE_BOOL":        3,
+		"PARAMETER_TYPE_STRING":      4,
+	}
+)
+
+func (x ParameterType) Enum() *ParameterType {
+	p := new(ParameterType)
+	*p = x
+	return p
+}
+
+func (x ParameterType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ParameterType) Descriptor() protoreflect.EnumDescriptor {
+	return file_src_pkg_transformer_v2_transformer_proto_enumTypes[0].Descriptor()
+}
+
+func (ParameterType) Type() protoreflect.EnumType {
+	return &file_src_pkg_transformer_v2_transformer_proto_enumTypes[0]
+}
+
+func (x ParameterType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ParameterType.Descriptor instead.
+func (ParameterType) EnumDescriptor() ([]byte, []int) {
+	return file_src_pkg_transformer_v2_transformer_proto_rawDescGZIP(), []int{0}
+}
+
+// A typed parameter value.
+type Value struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Kind:
+	//
+	//	*Value_IntValue
+	//	*Value_DoubleValue
+	//	*Value_BoolValue
+	//	*Value_StringValue
+	Kind          isValue_Kind `protobuf_oneof:"kind"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Value) Reset() {
+	*x = Value{}
+	mi := &file_src_pkg_transformer_v2_transformer_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Value) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Value) ProtoMessage() {}
+
+func (x *Value) ProtoReflect() protoreflect.Message {
+	mi := &file_src_pkg_transformer_v2_transformer_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Value.ProtoReflect.Descriptor instead.
+func (*Value) Descriptor() ([]byte, []int) {
+	return file_src_pkg_transformer_v2_transformer_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Value) GetKind() isValue_Kind {
+	if x != nil {
+		return x.Kind
+	}
+	return nil
+}
+
+func (x *Value) GetIntValue() int64 {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_IntValue); ok {
+			return x.IntValue
+		}
+	}
+	return 0
+}
+
+func (x *Value) GetDoubleValue() float64 {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_DoubleValue); ok {
+			return x.DoubleValue
+		}
+	}
+	return 0
+}
+
+func (x *Value) GetBoolValue() bool {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_BoolValue); ok {
+			return x.BoolValue
+		}
+	}
+	return false
+}
+
+func (x *Value) GetStringValue() string {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_StringValue); ok {
+			return x.StringValue
+		}
+	}
+	return ""
+}
+
+type isValue_Kind interface {
+	isValue_Kind()
+}
+
+type Value_IntValue struct {
+	IntValue int64 `protobuf:"varint,1,opt,name=int_value,json=intValue,proto3,oneof"`
+}
+
+type Value_DoubleValue struct {
+	DoubleValue float64 `protobuf:"fixed64,2,opt,name=double_value,json=doubleValue,proto3,oneof"`
+}
+
+type Value_BoolValue struct {
+	BoolValue bool `protobuf:"varint,3,opt,name=bool_value,json=boolValue,proto3,oneof"`
+}
+
+type Value_StringValue struct {
+	StringValue string `protobuf:"bytes,4,opt,name=string_value,json=stringValue,proto3,oneof"`
+}
+
+func (*Value_IntValue) isValue_Kind() {}
+
+func (*Value_DoubleValue) isValue_Kind() {}
+
+func (*Value_BoolValue) isValue_Kind() {}
+
+func (*Value_StringValue) isValue_Kind() {}
+
+// A transformation of the chain.
+type Step struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The name of the transformer running the step.
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// The parameters of the step, the transformer defaults are used for the missing ones.
+	Parameters    map[string]*Value `protobuf:"bytes,2,rep,name=parameters,proto3" json:"parameters,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Step) Reset() {
+	*x = Step{}
+	mi := &file_src_pkg_transformer_v2_transformer_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Step) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Step) ProtoMessage() {}
+
+func (x *Step) ProtoReflect() protoreflect.Message {
+	mi := &file_src_pkg_transformer_v2_transformer_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Step.ProtoReflect.Descriptor instead.
+func (*Step) Descriptor() ([]byte, []int) {
+	return file_src_pkg_transformer_v2_transformer_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Step) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Step) GetParameters() map[string]*Value {
+	if x != nil {
+		return x.Parameters
+	}
+	return nil
+}
+
+type OutputFormat struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The container, e.g. mpegts.
+	Container string `protobuf:"bytes,1,opt,name=container,proto3" json:"container,omitempty"`
+	// The video codec, e.g. h264.
+	VideoCodec string `protobuf:"bytes,2,opt,name=video_codec,json=videoCodec,proto3" json:"video_codec,omitempty"`
+	// The audio codec, e.g. aac, or copy to keep the input audio.
+	AudioCodec    string `protobuf:"bytes,3,opt,name=audio_codec,json=audioCodec,proto3" json:"audio_codec,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OutputFormat) Reset() {
+	*x = OutputFormat{}
+	mi := &file_src_pkg_transformer_v2_transformer_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OutputFormat) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OutputFormat) ProtoMessage() {}
+
+func (x *OutputFormat) ProtoReflect() protoreflect.Message {
+	mi := &file_src_pkg_transformer_v2_transformer_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OutputFormat.ProtoReflect.Descriptor instead.
+func (*OutputFormat) Descriptor() ([]byte, []int) {
+	return file_src_pkg_transformer_v2_transformer_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *OutputFormat) GetContainer() string {
+	if x != nil {
+		return x.Container
+	}
+	return ""
+}
+
+func (x *OutputFormat) GetVideoCodec() string {
+	if x != nil {
+		return x.VideoCodec
+	}
+	return ""
+}
+
+func (x *OutputFormat) GetAudioCodec() string {
+	if x != nil {
+		return x.AudioCodec
+	}
+	return ""
+}
+
+type TransformVideoRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The path of the video on S3.
+	Videopath string `protobuf:"bytes,1,opt,name=videopath,proto3" json:"videopath,omitempty"`
+	// The steps, in the order they are applied.
+	Steps []*Step `protobuf:"bytes,2,rep,name=steps,proto3" json:"steps,omitempty"`
+	// The formats accepted for the output, by order of preference. Empty fields accept any value,
+	// no format accepts the default format of the transformer.
+	AcceptedFormats []*OutputFormat `protobuf:"bytes,3,rep,name=accepted_formats,json=acceptedFormats,proto3" json:"accepted_formats,omitempty"`
+	// The viewer the video is transformed for, used by the steps personalising the video.
+	Principal     *Principal `protobuf:"bytes,4,opt,name=principal,proto3" json:"principal,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransformVideoRequest) Reset() {
+	*x = TransformVideoRequest{}
+	mi := &file_src_pkg_transformer_v2_transformer_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransformVideoRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransformVideoRequest) ProtoMessage() {}
+
+func (x *TransformVideoRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_src_pkg_transformer_v2_transformer_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransformVideoRequest.ProtoReflect.Descriptor instead.
+func (*TransformVideoRequest) Descriptor() ([]byte, []int) {
+	return file_src_pkg_transformer_v2_transformer_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *TransformVideoRequest) GetVideopath() string {
+	if x != nil {
+		return x.Videopath
+	}
+	return ""
+}
+
+func (x *TransformVideoRequest) GetSteps() []*Step {
+	if x != nil {
+		return x.Steps
+	}
+	return nil
+}
+
+func (x *TransformVideoRequest) GetAcceptedFormats() []*OutputFormat {
+	if x != nil {
+		return x.AcceptedFormats
+	}
+	return nil
+}
+
+func (x *TransformVideoRequest) GetPrincipal() *Principal {
+	if x != nil {
+		return x.Principal
+	}
+	return nil
+}
+
+// The authenticated viewer of a stream, forwarded by the API.
+type Principal struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The identifier of the viewer, e.g. its user name.
+	ViewerId string `protobuf:"bytes,1,opt,name=viewer_id,json=viewerId,proto3" json:"viewer_id,omitempty"`
+	// The identifier of the playback session, empty if the stream is not played with a token.
+	SessionId string `protobuf:"bytes,2,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// The only video the principal may read, empty if it may read any.
+	VideoId       string `protobuf:"bytes,3,opt,name=video_id,json=videoId,proto3" json:"video_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Principal) Reset() {
+	*x = Principal{}
+	mi := &file_src_pkg_transformer_v2_transformer_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Principal) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Principal) ProtoMessage() {}
+
+func (x *Principal) ProtoReflect() protoreflect.Message {
+	mi := &file_src_pkg_transformer_v2_transformer_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Principal.ProtoReflect.Descriptor instead.
+func (*Principal) Descriptor() ([]byte, []int) {
+	return file_src_pkg_transformer_v2_transformer_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Principal) GetViewerId() string {
+	if x != nil {
+		return x.ViewerId
+	}
+	return ""
+}
+
+func (x *Principal) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *Principal) GetVideoId() string {
+	if x != nil {
+		return x.VideoId
+	}
+	return ""
+}
+
+type TransformVideoResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The video part, as byte array.
+	Chunk []byte `protobuf:"bytes,1,opt,name=chunk,proto3" json:"chunk,omitempty"`
+	// The format of the video part, sent with the first chunk only.
+	Format *OutputFormat `protobuf:"bytes,2,opt,name=format,proto3" json:"format,omitempty"`
+	// The video part depends on the principal of the request and must not be served to other
+	// viewers, sent with the first chunk only.
+	PerViewer     bool `protobuf:"varint,3,opt,name=per_viewer,json=perViewer,proto3" json:"per_viewer,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransformVideoResponse) Reset() {
+	*x = TransformVideoResponse{}
+	mi := &file_src_pkg_transformer_v2_transformer_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransformVideoResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransformVideoResponse) ProtoMessage() {}
+
+func (x *TransformVideoResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_src_pkg_transformer_v2_transformer_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransformVideoResponse.ProtoReflect.Descriptor instead.
+func (*TransformVideoResponse) Descriptor() ([]byte, []int) {
+	return file_src_pkg_transformer_v2_transformer_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *TransformVideoResponse) GetChunk() []byte {
+	if x != nil {
+		return x.Chunk
+	}
+	return nil
+}
+
+func (x *TransformVideoResponse) GetFormat() *OutputFormat {
+	if x != nil {
+		return x.Format
+	}
+	return nil
+}
+
+func (x *TransformVideoResponse) GetPerViewer() bool {
+	if x != nil {
+		return x.PerViewer
+	}
+	return false
+}
+
+type DescribeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The name of the transformer to describe, it may be omitted if the instance serves only one.
+	Name          string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DescribeRequest) Reset() {
+	*x = DescribeRequest{}
+	mi := &file_src_pkg_transformer_v2_transformer_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DescribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DescribeRequest) ProtoMessage() {}
+
+func (x *DescribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_src_pkg_transformer_v2_transformer_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DescribeRequest.ProtoReflect.Descriptor instead.
+func (*DescribeRequest) Descriptor() ([]byte, []int) {
+	return file_src_pkg_transformer_v2_transformer_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DescribeRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type ParameterSpec struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Name        string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Type        ParameterType          `protobuf:"varint,2,opt,name=type,proto3,enum=pkg.transformer.v2.ParameterType" json:"type,omitempty"`
+	Description string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	Required    bool                   `protobuf:"varint,4,opt,name=required,proto3" json:"required,omitempty"`
+	// The value used when the parameter is missing.
+	DefaultValue *Value `protobuf:"bytes,5,opt,name=default_value,json=defaultValue,proto3" json:"default_value,omitempty"`
+	// The bounds of numeric parameters.
+	Minimum *float64 `protobuf:"fixed64,6,opt,name=minimum,proto3,oneof" json:"minimum,omitempty"`
+	Maximum *float64 `protobuf:"fixed64,7,opt,name=maximum,proto3,oneof" json:"maximum,omitempty"`
+	// The values allowed for string parameters, any value when empty.
+	AllowedValues []string `protobuf:"bytes,8,rep,name=allowed_values,json=allowedValues,proto3" json:"allowed_values,omitempty"`
+	// What a string parameter refers to, e.g. video-id for the ID of another video.
+	Format        string `protobuf:"bytes,9,opt,name=format,proto3" json:"format,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ParameterSpec) Reset() {
+	*x = ParameterSpec{}
+	mi := &file_src_pkg_transformer_v2_transformer_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ParameterSpec) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ParameterSpec) ProtoMessage() {}
+
+func (x *ParameterSpec) ProtoReflect() protoreflect.Message {
+	mi := &file_src_pkg_transformer_v2_transformer_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ParameterSpec.ProtoReflect.Descriptor instead.
+func (*ParameterSpec) Descriptor() ([]byte, []int) {
+	return file_src_pkg_transformer_v2_transformer_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ParameterSpec) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ParameterSpec) GetType() ParameterType {
+	if x != nil {
+		return x.Type
+	}
+	return ParameterType_PARAMETER_TYPE_UNSPECIFIED
+}
+
+func (x *ParameterSpec) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *ParameterSpec) GetRequired() bool {
+	if x != nil {
+		return x.Required
+	}
+	return false
+}
+
+func (x *ParameterSpec) GetDefaultValue() *Value {
+	if x != nil {
+		return x.DefaultValue
+	}
+	return nil
+}
+
+func (x *ParameterSpec) GetMinimum() float64 {
+	if x != nil && x.Minimum != nil {
+		return *x.Minimum
+	}
+	return 0
+}
+
+func (x *ParameterSpec) GetMaximum() float64 {
+	if x != nil && x.Maximum != nil {
+		return *x.Maximum
+	}
+	return 0
+}
+
+func (x *ParameterSpec) GetAllowedValues() []string {
+	if x != nil {
+		return x.AllowedValues
+	}
+	return nil
+}
+
+func (x *ParameterSpec) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+type DescribeResponse struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Name        string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Version     string                 `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	Description string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	Parameters  []*ParameterSpec       `protobuf:"bytes,4,rep,name=parameters,proto3" json:"parameters,omitempty"`
+	// The supported output formats, the first one is the default.
+	OutputFormats []*OutputFormat `protobuf:"bytes,5,rep,name=output_formats,json=outputFormats,proto3" json:"output_formats,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DescribeResponse) Reset() {
+	*x = DescribeResponse{}
+	mi := &file_src_pkg_transformer_v2_transformer_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DescribeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DescribeResponse) ProtoMessage() {}
+
+func (x *DescribeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_src_pkg_transformer_v2_transformer_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DescribeResponse.ProtoReflect.Descriptor instead.
+func (*DescribeResponse) Descriptor() ([]byte, []int) {
+	return file_src_pkg_transformer_v2_transformer_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *DescribeResponse) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *DescribeResponse) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *DescribeResponse) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *DescribeResponse) GetParameters() []*ParameterSpec {
+	if x != nil {
+		return x.Parameters
+	}
+	return nil
+}
+
+func (x *DescribeResponse) GetOutputFormats() []*OutputFormat {
+	if x != nil {
+		return x.OutputFormats
+	}
+	return nil
+}
+
+var File_src_pkg_transformer_v2_transformer_proto protoreflect.FileDescriptor
+
+const file_src_pkg_transformer_v2_transformer_proto_rawDesc = "" +
+	"\n" +
+	"(src/pkg/transformer/v2/transformer.proto\x12\x12pkg.transformer.v2\"\x99\x01\n" +
+	"\x05Value\x12\x1d\n" +
+	"\tint_value\x18\x01 \x01(\x03H\x00R\bintValue\x12#\n" +
+	"\fdouble_value\x18\x02 \x01(\x01H\x00R\vdoubleValue\x12\x1f\n" +
+	"\n" +
+	"bool_value\x18\x03 \x01(\bH\x00R\tboolValue\x12#\n" +
+	"\fstring_value\x18\x04 \x01(\tH\x00R\vstringValueB\x06\n" +
+	"\x04kind\"\xbe\x01\n" +
+	"\x04Step\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12H\n" +
+	"\n" +
+	"parameters\x18\x02 \x03(\v2(.pkg.transformer.v2.Step.ParametersEntryR\n" +
+	"parameters\x1aX\n" +
+	"\x0fParametersEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12/\n" +
+	"\x05value\x18\x02 \x01(\v2\x19.pkg.transformer.v2.ValueR\x05value:\x028\x01\"n\n" +
+	"\fOutputFormat\x12\x1c\n" +
+	"\tcontainer\x18\x01 \x01(\tR\tcontainer\x12\x1f\n" +
+	"\vvideo_codec\x18\x02 \x01(\tR\n" +
+	"videoCodec\x12\x1f\n" +
+	"\vaudio_codec\x18\x03 \x01(\tR\n" +
+	"audioCodec\"\xef\x01\n" +
+	"\x15TransformVideoRequest\x12\x1c\n" +
+	"\tvideopath\x18\x01 \x01(\tR\tvideopath\x12.\n" +
+	"\x05steps\x18\x02 \x03(\v2\x18.pkg.transformer.v2.StepR\x05steps\x12K\n" +
+	"\x10accepted_formats\x18\x03 \x03(\v2 .pkg.transformer.v2.OutputFormatR\x0facceptedFormats\x12;\n" +
+	"\tprincipal\x18\x04 \x01(\v2\x1d.pkg.transformer.v2.PrincipalR\tprincipal\"b\n" +
+	"\tPrincipal\x12\x1b\n" +
+	"\tviewer_id\x18\x01 \x01(\tR\bviewerId\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x02 \x01(\tR\tsessionId\x12\x19\n" +
+	"\bvideo_id\x18\x03 \x01(\tR\avideoId\"\x87\x01\n" +
+	"\x16TransformVideoResponse\x12\x14\n" +
+	"\x05chunk\x18\x01 \x01(\fR\x05chunk\x128\n" +
+	"\x06format\x18\x02 \x01(\v2 .pkg.transformer.v2.OutputFormatR\x06format\x12\x1d\n" +
+	"\n" +
+	"per_viewer\x18\x03 \x01(\bR\tperViewer\"%\n" +
+	"\x0fDescribeRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"\xed\x02\n" +
+	"\rParameterSpec\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x125\n" +
+	"\x04type\x18\x02 \x01(\x0e2!.pkg.transformer.v2.ParameterTypeR\x04type\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\x12\x1a\n" +
+	"\brequired\x18\x04 \x01(\bR\brequired\x12>\n" +
+	"\rdefault_value\x18\x05 \x01(\v2\x19.pkg.transformer.v2.ValueR\fdefaultValue\x12\x1d\n" +
+	"\aminimum\x18\x06 \x01(\x01H\x00R\aminimum\x88\x01\x01\x12\x1d\n" +
+	"\amaximum\x18\a \x01(\x01H\x01R\amaximum\x88\x01\x01\x12%\n" +
+	"\x0eallowed_values\x18\b \x03(\tR\rallowedValues\x12\x16\n" +
+	"\x06format\x18\t \x01(\tR\x06formatB\n" +
+	"\n" +
+	"\b_minimumB\n" +
+	"\n" +
+	"\b_maximum\"\xee\x01\n" +
+	"\x10DescribeResponse\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\tR\aversion\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\x12A\n" +
+	"\n" +
+	"parameters\x18\x04 \x03(\v2!.pkg.transformer.v2.ParameterSpecR\n" +
+	"parameters\x12G\n" +
+	"\x0eoutput_formats\x18\x05 \x03(\v2 .pkg.transformer.v2.OutputFormatR\routputFormats*\x96\x01\n" +
+	"\rParameterType\x12\x1e\n" +
+	"\x1aPARAMETER_TYPE_UNSPECIFIED\x10\x00\x12\x16\n" +
+	"\x12PARAMETER_TYPE_INT\x10\x01\x12\x19\n" +
+	"\x15PARAMETER_TYPE_DOUBLE\x10\x02\x12\x17\n" +
+	"\x13PARAMETER_TYPE_BOOL\x10\x03\x12\x19\n" +
+	"\x15PARAMETER_TYPE_STRING\x10\x042\xda\x01\n" +
+	"\x12TransformerService\x12k\n" +
+	"\x0eTransformVideo\x12).pkg.transformer.v2.TransformVideoRequest\x1a*.pkg.transformer.v2.TransformVideoResponse\"\x000\x01\x12W\n" +
+	"\bDescribe\x12#.pkg.transformer.v2.DescribeRequest\x1a$.pkg.transformer.v2.DescribeResponse\"\x00BBZ@github.com/rishirishhh/vought/src/pkg/transformer/v2;transformerb\x06proto3"
+
+var (
+	file_src_pkg_transformer_v2_transformer_proto_rawDescOnce sync.Once
+	file_src_pkg_transformer_v2_transformer_proto_rawDescData []byte
+)
+
+func file_src_pkg_transformer_v2_transformer_proto_rawDescGZIP() []byte {
+	file_src_pkg_transformer_v2_transformer_proto_rawDescOnce.Do(func() {
+		file_src_pkg_transformer_v2_transformer_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_src_pkg_transformer_v2_transformer_proto_rawDesc), len(file_src_pkg_transformer_v2_transformer_proto_rawDesc)))
+	})
+	return file_src_pkg_transformer_v2_transformer_proto_rawDescData
+}
+
+var file_src_pkg_transformer_v2_transformer_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_src_pkg_transformer_v2_transformer_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_src_pkg_transformer_v2_transformer_proto_goTypes = []any{
+	(ParameterType)(0),             // 0: pkg.transformer.v2.ParameterType
+	(*Value)(nil),                  // 1: pkg.transformer.v2.Value
+	(*Step)(nil),                   // 2: pkg.transformer.v2.Step
+	(*OutputFormat)(nil),           // 3: pkg.transformer.v2.OutputFormat
+	(*TransformVideoRequest)(nil),  // 4: pkg.transformer.v2.TransformVideoRequest
+	(*Principal)(nil),              // 5: pkg.transformer.v2.Principal
+	(*TransformVideoResponse)(nil), // 6: pkg.transformer.v2.TransformVideoResponse
+	(*DescribeRequest)(nil),        // 7: pkg.transformer.v2.DescribeRequest
+	(*ParameterSpec)(nil),          // 8: pkg.transformer.v2.ParameterSpec
+	(*DescribeResponse)(nil),       // 9: pkg.transformer.v2.DescribeResponse
+	nil,                            // 10: pkg.transformer.v2.Step.ParametersEntry
+}
+var file_src_pkg_transformer_v2_transformer_proto_depIdxs = []int32{
+	10, // 0: pkg.transformer.v2.Step.parameters:type_name -> pkg.transformer.v2.Step.ParametersEntry
+	2,  // 1: pkg.transformer.v2.TransformVideoRequest.steps:type_name -> pkg.transformer.v2.Step
+	3,  // 2: pkg.transformer.v2.TransformVideoRequest.accepted_formats:type_name -> pkg.transformer.v2.OutputFormat
+	5,  // 3: pkg.transformer.v2.TransformVideoRequest.principal:type_name -> pkg.transformer.v2.Principal
+	3,  // 4: pkg.transformer.v2.TransformVideoResponse.format:type_name -> pkg.transformer.v2.OutputFormat
+	0,  // 5: pkg.transformer.v2.ParameterSpec.type:type_name -> pkg.transformer.v2.ParameterType
+	1,  // 6: pkg.transformer.v2.ParameterSpec.default_value:type_name -> pkg.transformer.v2.Value
+	8,  // 7: pkg.transformer.v2.DescribeResponse.parameters:type_name -> pkg.transformer.v2.ParameterSpec
+	3,  // 8: pkg.transformer.v2.DescribeResponse.output_formats:type_name -> pkg.transformer.v2.OutputFormat
+	1,  // 9: pkg.transformer.v2.Step.ParametersEntry.value:type_name -> pkg.transformer.v2.Value
+	4,  // 10: pkg.transformer.v2.TransformerService.TransformVideo:input_type -> pkg.transformer.v2.TransformVideoRequest
+	7,  // 11: pkg.transformer.v2.TransformerService.Describe:input_type -> pkg.transformer.v2.DescribeRequest
+	6,  // 12: pkg.transformer.v2.TransformerService.TransformVideo:output_type -> pkg.transformer.v2.TransformVideoResponse
+	9,  // 13: pkg.transformer.v2.TransformerService.Describe:output_type -> pkg.transformer.v2.DescribeResponse
+	12, // [12:14] is the sub-list for method output_type
+	10, // [10:12] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
+}
+
+func init() { file_src_pkg_transformer_v2_transformer_proto_init() }
+func file_src_pkg_transformer_v2_transformer_proto_init() {
+	if File_src_pkg_transformer_v2_transformer_proto != nil {
+		return
+	}
+	file_src_pkg_transformer_v2_transformer_proto_msgTypes[0].OneofWrappers = []any{
+		(*Value_IntValue)(nil),
+		(*Value_DoubleValue)(nil),
+		(*Value_BoolValue)(nil),
+		(*Value_StringValue)(nil),
+	}
+	file_src_pkg_transformer_v2_transformer_proto_msgTypes[7].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_src_pkg_transformer_v2_transformer_proto_rawDesc), len(file_src_pkg_transformer_v2_transformer_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_src_pkg_transformer_v2_transformer_proto_goTypes,
+		DependencyIndexes: file_src_pkg_transformer_v2_transformer_proto_depIdxs,
+		EnumInfos:         file_src_pkg_transformer_v2_transformer_proto_enumTypes,
+		MessageInfos:      file_src_pkg_transformer_v2_transformer_proto_msgTypes,
+	}.Build()
+	File_src_pkg_transformer_v2_transformer_proto = out.File
+	file_src_pkg_transformer_v2_transformer_proto_goTypes = nil
+	file_src_pkg_transformer_v2_transformer_proto_depIdxs = nil
+}